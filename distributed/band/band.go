@@ -0,0 +1,193 @@
+package band
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//How long to wait for a peer to send the edges of its band when the broker doesn't say
+const defaultPeerTimeout = 10 * time.Second
+
+//Worker keeps the band of the world the broker gave it with LoadSlice from one turn to the next,
+//getting the rows and cells along the edges of the bands around it straight from the workers keeping them.
+//The worker serves it as its WorkerOperations, and the broker's tests run their bands on it.
+type Worker struct {
+	mutex sync.Mutex
+	//Whether the broker has given the worker a band, which is kept apart from the band's rows as a band sent with
+	//no rows arrives with them nil
+	loaded      bool
+	band        util.Bitboard
+	rule        util.Rule
+	topology    util.Topology
+	start       int
+	worldHeight int
+	turn        int
+	//Only has the edges of the bands that the halos of this one are made from
+	frame       util.Bitboard
+	peers       []stubs.Peer
+	peerClients []*rpc.Client
+	peerTimeout time.Duration
+	batchTurns  int
+
+	//The edges of the band after the turn it has reached and after the turns it ran before those,
+	//as a peer can still be a step behind
+	boundaryMutex        sync.Mutex
+	boundary             *stubs.BoundaryResponse
+	previousBoundary     *stubs.BoundaryResponse
+	boundaryTurn         int
+	previousBoundaryTurn int
+}
+
+//Keeps a band of the world to run turns on, replacing any band the worker had before,
+//and connects to the peers it gets its halos from
+func (w *Worker) LoadSlice(req stubs.Request, resp *stubs.GenericMessage) (err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	peerTimeout := req.PeerTimeout
+	if peerTimeout <= 0 {
+		peerTimeout = defaultPeerTimeout
+	}
+	var peerClients []*rpc.Client
+	for _, peer := range req.Peers {
+		conn, derr := net.DialTimeout("tcp", peer.Address, peerTimeout)
+		if derr != nil {
+			for _, client := range peerClients {
+				client.Close()
+			}
+			return derr
+		}
+		peerClients = append(peerClients, rpc.NewClient(conn))
+	}
+	for _, client := range w.peerClients {
+		client.Close()
+	}
+	w.loaded, w.band, w.rule, w.topology = true, req.Slice, req.Rule, req.Topology
+	w.start, w.worldHeight, w.turn = req.SliceStart, req.WorldHeight, req.StartTurn
+	w.frame = util.NewBitboard(req.Slice.Width, req.WorldHeight)
+	w.peers, w.peerClients, w.peerTimeout = req.Peers, peerClients, peerTimeout
+	w.batchTurns = req.BatchTurns
+	if w.batchTurns < 1 {
+		w.batchTurns = 1
+	}
+	//The edges of the band the worker had before are no use to its new peers
+	w.boundaryMutex.Lock()
+	w.boundary = nil
+	w.boundaryMutex.Unlock()
+	w.keepBoundary()
+	return
+}
+
+//Runs turns on the band, building halos deep enough for all of them from the edges of the bands around it
+func (w *Worker) StepSlice(req stubs.StepRequest, resp *stubs.StepResponse) (err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if !w.loaded || req.Turn != w.turn {
+		//The worker has restarted or been given a different band, so the broker has to load it again
+		return errors.New("this worker doesn't have its band after turn " + strconv.Itoa(req.Turn))
+	}
+	turns := req.Turns
+	if turns < 1 {
+		turns = 1
+	}
+	if turns > w.batchTurns || (turns > 1 && !w.topology.CanStepTurns(w.rule)) {
+		return errors.New("this worker can't run " + strconv.Itoa(turns) + " turns at once")
+	}
+	err = w.fetchBoundaries()
+	if err != nil {
+		return
+	}
+	depth := w.rule.Range * turns
+	slice, edges := w.topology.Slice(w.frame, w.start, w.start+len(w.band.Rows), depth)
+	top, bottom := slice.Rows[:depth], slice.Rows[len(slice.Rows)-depth:]
+	if turns == 1 {
+		w.band = w.band.StepBand(w.rule, top, bottom, edges)
+	} else {
+		w.band = w.band.StepBandTurns(w.rule, w.topology, top, bottom, w.start, w.worldHeight, turns)
+	}
+	w.turn += turns
+	w.keepBoundary()
+	resp.AliveCount = w.band.AliveCount()
+	if req.Hash {
+		resp.Hash = w.band.RowsHash(w.start, w.worldHeight)
+	}
+	return
+}
+
+//Puts the edges of the band and of its peers' bands after the turn the band has reached into the frame,
+//asking every peer at once and giving up if any of them fail or don't answer in time
+func (w *Worker) fetchBoundaries() error {
+	depth := w.rule.Range
+	w.frame.SetBandEdges(w.start, w.boundary.Top, w.boundary.Bottom, w.boundary.West, w.boundary.East, depth)
+	calls := make([]*rpc.Call, len(w.peerClients))
+	for i, client := range w.peerClients {
+		req := stubs.BoundaryRequest{Turn: w.turn}
+		calls[i] = client.Go(stubs.GetBoundary, req, new(stubs.BoundaryResponse), make(chan *rpc.Call, 1))
+	}
+	timeout := time.After(w.peerTimeout)
+	for i, call := range calls {
+		peer := w.peers[i]
+		select {
+		case <-call.Done:
+			if call.Error != nil {
+				return errors.New("worker on " + peer.Address + " failed to send its edges - " + call.Error.Error())
+			}
+			boundary := call.Reply.(*stubs.BoundaryResponse)
+			if len(boundary.West) != peer.End-peer.Start {
+				return errors.New("worker on " + peer.Address + " doesn't have the band it was expected to")
+			}
+			w.frame.SetBandEdges(peer.Start, boundary.Top, boundary.Bottom, boundary.West, boundary.East, depth)
+		case <-timeout:
+			return errors.New("worker on " + peer.Address + " didn't send its edges in time")
+		}
+	}
+	return nil
+}
+
+//Keeps the edges of the band as it is now for its peers, along with the edges it had before its last turns
+func (w *Worker) keepBoundary() {
+	depth := w.rule.Range
+	rows := depth * w.batchTurns
+	if rows > len(w.band.Rows) {
+		rows = len(w.band.Rows)
+	}
+	boundary := &stubs.BoundaryResponse{Top: w.band.Rows[:rows], Bottom: w.band.Rows[len(w.band.Rows)-rows:]}
+	boundary.West, boundary.East = w.band.EdgeCells(depth)
+	w.boundaryMutex.Lock()
+	w.previousBoundary, w.previousBoundaryTurn = w.boundary, w.boundaryTurn
+	w.boundary, w.boundaryTurn = boundary, w.turn
+	w.boundaryMutex.Unlock()
+}
+
+//Returns the edges of the band after the given turn to a peer, which must be the turn the band has reached
+//or the one it had reached before its last turns
+func (w *Worker) GetBoundary(req stubs.BoundaryRequest, resp *stubs.BoundaryResponse) (err error) {
+	w.boundaryMutex.Lock()
+	defer w.boundaryMutex.Unlock()
+	boundary := w.boundary
+	if req.Turn != w.boundaryTurn {
+		boundary = nil
+		if req.Turn == w.previousBoundaryTurn {
+			boundary = w.previousBoundary
+		}
+	}
+	if boundary == nil {
+		return errors.New("this worker doesn't have the edges of its band after turn " + strconv.Itoa(req.Turn))
+	}
+	*resp = *boundary
+	return
+}
+
+//Returns the whole band, for the broker to put the world back together when it needs all of it
+func (w *Worker) CollectSlice(req stubs.GenericMessage, resp *stubs.Response) (err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	resp.NextSlice = w.band
+	return
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"strconv"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//Each worker keeps its own band of the world between turns, getting the rows and cells along the edges of the bands
+//around it straight from the workers keeping them. The broker only tells the workers when to run each turn,
+//and collects the whole world from them when it is needed.
+//The workers can run several turns between calls from halos as many turns deep, working out the halos along with
+//their bands, which costs some repeated work on the rows either side of each band but saves waiting for the calls.
+
+//The first row of each worker's band, with the last band ending at the bottom of the world
+var bandStarts []int
+var bandsWidth int
+var bandsHeight int
+var bandsRule util.Rule
+var bandsTopology util.Topology
+var bandsTurn int
+var bandsAliveCount int
+var bandsHash uint64
+var hashBands bool
+
+//The most turns the workers run at once on the bands of this world
+var bandsBatch int
+
+//The most turns the workers run at once when the rule and topology let them, set with -batch
+var maxBatchTurns = defaultBatchTurns
+
+//How many turns the workers run at once unless the broker is told otherwise, which saves 7 in every 8 waits for the
+//edges of the other bands for a few rows of repeated work either side of each band
+const defaultBatchTurns = 8
+
+//The latest world collected from the workers, which the bands are rebuilt from if a worker fails
+//It is the world the bands started from, or the last one collected for a checkpoint, an 's' key press or the
+//statistics, so with checkpoints on a failure replays the turns from the last checkpoint
+var snapshot util.Bitboard
+var snapshotTurn int
+
+//How many turns the bands can get ahead of the snapshot before it is collected just to keep it recent, set with
+//-snapshot, which is then the most turns a worker failing can cost, or 0 to only collect it when it is needed anyway
+var snapshotTurns = defaultSnapshotTurns
+
+//How often the world is collected unless the broker is told otherwise
+//Each collection moves every row of the world over the network rather than the few halo rows of a turn, so doing it
+//once every 100 turns costs a long run little, while keeping a failure from replaying more than 100 turns
+const defaultSnapshotTurns = 100
+
+//How many times in a row the bands are rebuilt after the workers fail before the broker gives up on the request
+const maxRecoveries = 5
+
+//Starts the workers on the bands of a world that has completed the given number of turns
+func startBands(currentWorld util.Bitboard, rule util.Rule, topology util.Topology, turn int, hash bool) error {
+	bandsRule, bandsTopology, hashBands = rule, topology, hash
+	bandsBatch = 1
+	if maxBatchTurns > 1 && topology.CanStepTurns(rule) {
+		bandsBatch = maxBatchTurns
+	}
+	snapshot, snapshotTurn = currentWorld, turn
+	bandsAliveCount = currentWorld.AliveCount()
+	if hash {
+		bandsHash = currentWorld.Hash()
+	}
+	if len(workerClients) == 0 {
+		connectWorkers()
+	}
+	if !distributeWorkers(currentWorld, turn) {
+		return recoverBands()
+	}
+	return nil
+}
+
+//Splits the world into a band for each worker to keep, telling each one which others it gets its halos from
+//A world with fewer rows than there are workers is split between as many workers as it has rows, leaving the rest idle
+//Returns whether every worker given a band took it
+func distributeWorkers(currentWorld util.Bitboard, turn int) bool {
+	height := currentWorld.Height()
+	bands := len(workerClients)
+	if bands > height {
+		bands = height
+	}
+	rowsPerWorker := height / bands
+	remainders := height % bands
+	bandStarts = make([]int, bands)
+	start := 0
+	for i := range bandStarts {
+		bandStarts[i] = start
+		start += rowsPerWorker
+		//Adding an extra row to the first bands if the world doesn't split between the workers without remainders
+		if i < remainders {
+			start++
+		}
+	}
+	bandsWidth, bandsHeight, bandsTurn = currentWorld.Width, height, turn
+	return callWorkers(func(i int, client *rpc.Client) error {
+		start, end := bandRows(i)
+		band := util.Bitboard{Width: currentWorld.Width, Rows: currentWorld.Rows[start:end]}
+		if currentWorld.Decay != nil {
+			band.Decay = currentWorld.Decay[start:end]
+		}
+		req := stubs.Request{
+			Slice:       band,
+			Rule:        bandsRule,
+			Topology:    bandsTopology,
+			SliceStart:  start,
+			WorldHeight: height,
+			StartTurn:   turn,
+			Peers:       bandPeers(i),
+			PeerTimeout: livenessTimeout,
+			BatchTurns:  bandsBatch,
+		}
+		return client.Call(stubs.LoadSlice, req, new(stubs.GenericMessage))
+	})
+}
+
+//The rows start to end-1 of the world that are in a worker's band
+func bandRows(i int) (int, int) {
+	end := bandsHeight
+	if i+1 < len(bandStarts) {
+		end = bandStarts[i+1]
+	}
+	return bandStarts[i], end
+}
+
+//The other workers with rows of the world that the halos of a worker's band come from,
+//which are usually just the workers with the bands either side of it, unless the halos are deep enough to reach further
+func bandPeers(i int) []stubs.Peer {
+	start, end := bandRows(i)
+	var peers []stubs.Peer
+	//The rows come in order, so the rows of each band are together
+	previous := i
+	for _, y := range bandsTopology.HaloRows(bandsWidth, bandsHeight, start, end, bandsRule.Range*bandsBatch) {
+		if j := bandOf(y); j != i && j != previous {
+			peerStart, peerEnd := bandRows(j)
+			peers = append(peers, stubs.Peer{Address: connectedWorkers[j], Start: peerStart, End: peerEnd})
+			previous = j
+		}
+	}
+	return peers
+}
+
+//The worker whose band has row y of the world in it
+func bandOf(y int) int {
+	i := len(bandStarts) - 1
+	for bandStarts[i] > y {
+		i--
+	}
+	return i
+}
+
+//Calls every worker with a band at once, waiting for all of the calls to return
+//Returns whether they all succeeded
+func callWorkers(call func(i int, client *rpc.Client) error) bool {
+	clients := workerClients[:len(bandStarts)]
+	errs := make(chan error, len(clients))
+	for i := range clients {
+		go func(i int) {
+			errs <- call(i, clients[i])
+		}(i)
+	}
+	ok := true
+	for range clients {
+		if err := <-errs; err != nil {
+			fmt.Println("Worker call failed - ", err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+//Runs turns on every band, which is the barrier between them: none of the workers start the next turns until they
+//have all finished these, so the edges they get from each other are never more than a call old
+//Returns whether every worker ran the turns
+func stepBands(turns int) bool {
+	steps := make([]stubs.StepResponse, len(bandStarts))
+	ok := callWorkers(func(i int, client *rpc.Client) error {
+		req := stubs.StepRequest{Turn: bandsTurn, Turns: turns, Hash: hashBands}
+		return client.Call(stubs.StepSlice, req, &steps[i])
+	})
+	if !ok {
+		return false
+	}
+	bandsAliveCount, bandsHash = 0, 0
+	for _, step := range steps {
+		bandsAliveCount += step.AliveCount
+		bandsHash += step.Hash
+	}
+	bandsTurn += turns
+	return true
+}
+
+//Runs turns on the workers, which must be no more than bandsBatch, first splitting the world between them again
+//if any have joined or left and rebuilding the bands if a worker fails
+func stepWorkers(turns int) error {
+	//Workers that joined or left since the last turn are picked up between turns
+	workersMutex.Lock()
+	changed := workersChanged
+	workersMutex.Unlock()
+	if changed {
+		currentWorld, err := collectWorld()
+		if err != nil {
+			return err
+		}
+		rebalanceWorkers()
+		if len(workerClients) == 0 {
+			connectWorkers()
+		}
+		if !distributeWorkers(currentWorld, bandsTurn) {
+			if err := recoverBands(); err != nil {
+				return err
+			}
+		}
+	}
+	err := retryBands(func() bool {
+		return stepBands(turns)
+	})
+	if err == nil && snapshotTurns > 0 && bandsTurn-snapshotTurn >= snapshotTurns {
+		_, err = collectWorld()
+	}
+	return err
+}
+
+//Collects the bands from the workers and puts the world back together
+//Returns whether every worker returned its band
+func collectBands() (util.Bitboard, bool) {
+	bands := make([]stubs.Response, len(bandStarts))
+	ok := callWorkers(func(i int, client *rpc.Client) error {
+		return client.Call(stubs.CollectSlice, stubs.GenericMessage{}, &bands[i])
+	})
+	currentWorld := util.Bitboard{Width: bandsWidth}
+	for _, band := range bands {
+		currentWorld.Rows = append(currentWorld.Rows, band.NextSlice.Rows...)
+		currentWorld.Decay = append(currentWorld.Decay, band.NextSlice.Decay...)
+	}
+	return currentWorld, ok
+}
+
+//Returns the world the bands have reached, collecting it from the workers unless it hasn't changed since it last was
+//It is kept as the snapshot to rebuild the bands from
+func collectWorld() (util.Bitboard, error) {
+	if snapshotTurn == bandsTurn {
+		return snapshot, nil
+	}
+	var currentWorld util.Bitboard
+	err := retryBands(func() (ok bool) {
+		currentWorld, ok = collectBands()
+		return
+	})
+	if err != nil {
+		return util.Bitboard{}, err
+	}
+	snapshot, snapshotTurn = currentWorld, bandsTurn
+	return currentWorld, nil
+}
+
+//Moves the bands on by turns without running them, which must be whole cycles of the world,
+//loading the same bands onto the workers again as having completed those turns
+func skipBands(turns int) error {
+	currentWorld, err := collectWorld()
+	if err != nil {
+		return err
+	}
+	bandsTurn += turns
+	snapshotTurn = bandsTurn
+	if !distributeWorkers(currentWorld, bandsTurn) {
+		return recoverBands()
+	}
+	return nil
+}
+
+//Rebuilds the bands from the snapshot after a worker fails, on whichever workers are left,
+//and runs them back up to the turn they had reached
+//Returns an error if the workers still fail after maxRecoveries attempts
+func recoverBands() error {
+	turn := bandsTurn
+	for attempt := 0; attempt < maxRecoveries; attempt++ {
+		fmt.Println("Fault detected, rebuilding the bands from turn", snapshotTurn)
+		connectWorkers()
+		ok := distributeWorkers(snapshot, snapshotTurn)
+		for ok && bandsTurn < turn {
+			turns := turn - bandsTurn
+			if turns > bandsBatch {
+				turns = bandsBatch
+			}
+			ok = stepBands(turns)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return errors.New("the workers failed to rebuild their bands from turn " + strconv.Itoa(snapshotTurn) +
+		" after " + strconv.Itoa(maxRecoveries) + " attempts")
+}
+
+//Calls the workers with call, which returns whether they all succeeded, rebuilding the bands and calling them again
+//each time one of them fails
+//Returns an error if the workers still fail after the bands have been rebuilt maxRecoveries times
+func retryBands(call func() bool) error {
+	for recoveries := 0; !call(); recoveries++ {
+		if recoveries == maxRecoveries {
+			return errors.New("the workers failed after turn " + strconv.Itoa(bandsTurn) +
+				" even after rebuilding their bands " + strconv.Itoa(maxRecoveries) + " times")
+		}
+		if err := recoverBands(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/rpc"
 	"sync"
+	"time"
 
 	"uk.ac.bris.cs/gameoflife/stubs"
 	"uk.ac.bris.cs/gameoflife/util"
@@ -14,8 +16,12 @@ import (
 var listener net.Listener
 var workers []string
 var workerClients []*rpc.Client
-var clientChannels []chan [][]uint8
-var safetyChannels []chan bool
+var connectedWorkers []string
+var workerHeartbeats map[string]time.Time
+var workersMutex sync.Mutex
+var workersChanged bool
+var runningRequest bool
+var livenessTimeout time.Duration
 
 var requestingPGM bool
 var PGMChannel chan [][]uint8
@@ -23,6 +29,9 @@ var PGMChannel chan [][]uint8
 var paused bool
 var pauseChannel chan bool
 var turnChannel chan int
+//Whether a pause is waiting for the turns to reach it
+var requestingPause bool
+var pauseMutex sync.Mutex
 
 var pgmMutex sync.Mutex
 var killMutex sync.Mutex
@@ -33,20 +42,152 @@ var stopCallChannel chan bool
 
 var aliveCellsToSend int
 var turnToSend int
+//The cycle found in the world so far, sent along with the alive cells
+var cycleToSend stubs.AliveCellsResponse
+//Whether the workers are running a batch of turns, when an alive count waits for the batch to finish
+//so that it is of the world after the turns it was asked for during
+var batchingTurns bool
+var aliveCellsRequests []chan stubs.AliveCellsResponse
 var tickerMutex sync.Mutex
 
+var pendingCheckpoint *stubs.PGMResponse
+var checkpointWritten chan bool
+var checkpointMutex sync.Mutex
+
+var pendingStats []util.Stats
+var statsMutex sync.Mutex
+
+//Held while a request is processed, as the workers can only keep the bands of one world at a time
+var requestMutex sync.Mutex
+
+//How long to wait for the controller to write a checkpoint before carrying on without it
+const checkpointWriteTimeout = time.Minute
+
+//How long a worker can go without a heartbeat before it is removed, unless set with -timeout
+const defaultLivenessTimeout = 10 * time.Second
+
+//How long to wait before looking for workers again when none are connected
+const workerRetryInterval = time.Second
+
+//How often a worker that is unsubscribing checks whether the broker has stopped giving it work
+const releaseCheckInterval = 10 * time.Millisecond
+
+//The value of Request.Engine that asks the broker to run HashLife, the same as gol.HashLife
+const hashLifeEngine = "hashlife"
+
 type BrokerOperations struct{}
 
 func (b *BrokerOperations) SubscribeWorker(req stubs.SubscriptionRequest, resp *stubs.GenericMessage) (err error) {
-	workers = append(workers, req.IP)
 	fmt.Println("Received subscription request from worker on " + req.IP)
+	addWorker(req.IP)
 	return
 }
 
+//Removes a worker that is shutting down cleanly, so it isn't dialled again
+//During a run the worker finishes its slice first, returning once the next turn has been split between the workers
+//left, or after the liveness timeout
+func (b *BrokerOperations) UnsubscribeWorker(req stubs.SubscriptionRequest, resp *stubs.GenericMessage) (err error) {
+	fmt.Println("Received unsubscription request from worker on " + req.IP)
+	workersMutex.Lock()
+	forgetWorker(req.IP)
+	deadline := time.Now().Add(livenessTimeout)
+	for runningRequest && isConnected(req.IP) && time.Now().Before(deadline) {
+		workersMutex.Unlock()
+		time.Sleep(releaseCheckInterval)
+		workersMutex.Lock()
+	}
+	removeWorker(req.IP)
+	workersMutex.Unlock()
+	return
+}
+
+//Keeps a worker alive, subscribing it again if it had been removed after missing heartbeats
+func (b *BrokerOperations) Heartbeat(req stubs.SubscriptionRequest, resp *stubs.GenericMessage) (err error) {
+	if addWorker(req.IP) {
+		fmt.Println("Worker on " + req.IP + " rejoined")
+	}
+	return
+}
+
+//Records a heartbeat from a worker, adding it to the workers if it isn't one already
+//Returns whether the worker was added
+func addWorker(ip string) bool {
+	workersMutex.Lock()
+	defer workersMutex.Unlock()
+	_, known := workerHeartbeats[ip]
+	if !known {
+		workers = append(workers, ip)
+		workersChanged = true
+	}
+	workerHeartbeats[ip] = time.Now()
+	return !known
+}
+
+//Forgets a worker and closes any connection to it, which fails a call in progress so that its turn is retried
+//without it. workersMutex must be held.
+func removeWorker(ip string) {
+	forgetWorker(ip)
+	for i := range connectedWorkers {
+		if connectedWorkers[i] == ip {
+			workerClients[i].Close()
+		}
+	}
+}
+
+//Stops a worker being dialled, leaving any connection to it to be dropped at the next turn.
+//workersMutex must be held.
+func forgetWorker(ip string) {
+	delete(workerHeartbeats, ip)
+	for i := range workers {
+		if workers[i] == ip {
+			workers = append(workers[:i], workers[i+1:]...)
+			workersChanged = true
+			break
+		}
+	}
+}
+
+//Returns whether the broker is connected to a worker. workersMutex must be held.
+func isConnected(ip string) bool {
+	for i := range connectedWorkers {
+		if connectedWorkers[i] == ip {
+			return true
+		}
+	}
+	return false
+}
+
+//Removes the workers that haven't sent a heartbeat within the liveness timeout
+func removeDeadWorkers(now time.Time) {
+	workersMutex.Lock()
+	defer workersMutex.Unlock()
+	for ip, lastHeartbeat := range workerHeartbeats {
+		if now.Sub(lastHeartbeat) > livenessTimeout {
+			fmt.Println("Worker on " + ip + " missed its heartbeats, removing it")
+			removeWorker(ip)
+		}
+	}
+}
+
+//Checks for dead workers for as long as the broker runs
+func monitorWorkers() {
+	ticker := time.NewTicker(livenessTimeout / 2)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		removeDeadWorkers(now)
+	}
+}
+
 func (b *BrokerOperations) TogglePause(req stubs.GenericMessage, resp *stubs.PauseResponse) (err error) {
 	paused = !paused
 	if paused {
+		pauseMutex.Lock()
+		requestingPause = true
+		pauseMutex.Unlock()
 		pauseChannel <- true
+		pauseMutex.Lock()
+		requestingPause = false
+		pauseMutex.Unlock()
 		resp.Resuming = false
 		resp.Turn = <-turnChannel
 	} else {
@@ -84,97 +225,304 @@ func (b *BrokerOperations) KeyPressPGM(req stubs.GenericMessage, resp *stubs.PGM
 
 func (b *BrokerOperations) GetAliveCells(req stubs.GenericMessage, resp *stubs.AliveCellsResponse) (err error) {
 	tickerMutex.Lock()
+	if !batchingTurns {
+		*resp = latestAliveCells()
+		tickerMutex.Unlock()
+		return
+	}
+	answer := make(chan stubs.AliveCellsResponse, 1)
+	aliveCellsRequests = append(aliveCellsRequests, answer)
+	tickerMutex.Unlock()
+	*resp = <-answer
+	return
+}
+
+//The alive cells counted after the latest turn, along with the cycle found so far
+//The ticker mutex must be held
+func latestAliveCells() stubs.AliveCellsResponse {
+	resp := cycleToSend
 	resp.Cells = aliveCellsToSend
 	resp.TurnsCompleted = turnToSend
+	return resp
+}
+
+//Answers the alive counts waiting for the batch of turns the workers were running to finish
+//The ticker mutex must be held
+func answerAliveCells() {
+	for _, answer := range aliveCellsRequests {
+		answer <- latestAliveCells()
+	}
+	aliveCellsRequests = nil
+}
+
+//Whether a pause, snapshot or alive count is waiting for the turns to reach it,
+//in which case the workers only run the next turn so that it is answered then rather than after a whole batch
+func requestPending() bool {
+	pgmMutex.Lock()
+	pending := requestingPGM
+	pgmMutex.Unlock()
+	pauseMutex.Lock()
+	pending = pending || requestingPause
+	pauseMutex.Unlock()
+	tickerMutex.Lock()
+	pending = pending || len(aliveCellsRequests) > 0
 	tickerMutex.Unlock()
+	return pending
+}
+
+//Returns the checkpoint waiting to be written, or an empty world if there isn't one
+//The controller says which checkpoint it has written when it asks again, which lets the turns carry on
+func (b *BrokerOperations) GetCheckpoint(req stubs.CheckpointRequest, resp *stubs.PGMResponse) (err error) {
+	checkpointMutex.Lock()
+	defer checkpointMutex.Unlock()
+	if pendingCheckpoint != nil && req.WrittenTurn == pendingCheckpoint.Turns {
+		pendingCheckpoint = nil
+		checkpointWritten <- true
+	}
+	if pendingCheckpoint != nil {
+		resp.World = pendingCheckpoint.World
+		resp.Turns = pendingCheckpoint.Turns
+	}
+	return
+}
+
+//Returns the statistics of the turns completed since the controller last asked for them
+func (b *BrokerOperations) GetStats(req stubs.GenericMessage, resp *stubs.StatsResponse) (err error) {
+	statsMutex.Lock()
+	resp.Stats, pendingStats = pendingStats, nil
+	statsMutex.Unlock()
 	return
 }
 
+//Stops the controller's request after the turn it is on, which then returns the world as it is
+//A stop that is already waiting is enough, so the controller isn't held up if the request has just finished
 func (b *BrokerOperations) DisconnectController(req stubs.GenericMessage, resp *stubs.GenericMessage) (err error) {
-	stopCallChannel <- true
+	select {
+	case stopCallChannel <- true:
+	default:
+	}
 	return
 }
 
 //Establishes connection to worker nodes and channels to communicate with them via
+//Dialling gives up after the liveness timeout, so a machine that has gone away can't stall the turns for long
 func attemptConnectWorkers() {
-	if len(workerClients) > 0 {
-		for i := range workerClients {
-			workerClients[i].Close()
-		}
+	workersMutex.Lock()
+	for i := range workerClients {
+		workerClients[i].Close()
 	}
 	workerClients = []*rpc.Client{}
-	clientChannels = []chan [][]uint8{}
-	safetyChannels = []chan bool{}
-	for i := range workers {
-		fmt.Println("Attempting to connect to worker on ", workers[i])
-		newClient, derr := rpc.Dial("tcp", workers[i])
-		if derr != nil {
-			fmt.Println("Broker dialing error on ", workers[i], " - ", derr.Error())
-		} else {
-			fmt.Println("Broker connected to worker on ", workers[i])
-			workerClients = append(workerClients, newClient)
-			clientChannels = append(clientChannels, make(chan [][]byte))
-			safetyChannels = append(safetyChannels, make(chan bool))
-		}
+	connectedWorkers = []string{}
+	subscribed := append([]string{}, workers...)
+	workersChanged = false
+	workersMutex.Unlock()
+	dialWorkers(subscribed, nil)
+}
+
+//Connects to the workers that subscribed since the last turn and stops using the ones that left,
+//keeping the connections to the rest, so the next turn splits the world between the workers there are now
+func rebalanceWorkers() {
+	workersMutex.Lock()
+	subscribed := append([]string{}, workers...)
+	existing := make(map[string]*rpc.Client)
+	for i := range connectedWorkers {
+		existing[connectedWorkers[i]] = workerClients[i]
 	}
+	workersChanged = false
+	workersMutex.Unlock()
+	dialWorkers(subscribed, existing)
+	fmt.Println("Rebalancing the world across", len(workerClients), "workers")
 }
 
-//Checks that all worker calls returned correctly, rolls back and redistributes if not
-func checkFaults(currentWorld [][]uint8) {
-	fault := false
-	for i := range safetyChannels {
-		if <-safetyChannels[i] == false {
-			fault = true
+//Dials each subscribed worker that doesn't already have a connection in existing,
+//closing the existing connections to workers that are no longer subscribed
+func dialWorkers(subscribed []string, existing map[string]*rpc.Client) {
+	var newClients []*rpc.Client
+	var newWorkers []string
+	for i := range subscribed {
+		client, connected := existing[subscribed[i]]
+		if connected {
+			delete(existing, subscribed[i])
+		} else {
+			fmt.Println("Attempting to connect to worker on ", subscribed[i])
+			conn, derr := net.DialTimeout("tcp", subscribed[i], livenessTimeout)
+			if derr != nil {
+				fmt.Println("Broker dialing error on ", subscribed[i], " - ", derr.Error())
+				continue
+			}
+			fmt.Println("Broker connected to worker on ", subscribed[i])
+			client = rpc.NewClient(conn)
 		}
+		newClients = append(newClients, client)
+		newWorkers = append(newWorkers, subscribed[i])
 	}
-	if fault {
-		fmt.Println("Fault detected, retrying turn")
-		//Cleaning successful channels
-		for i := range clientChannels {
-			<-clientChannels[i]
-		}
+	for _, client := range existing {
+		client.Close()
+	}
+	workersMutex.Lock()
+	workerClients, connectedWorkers = newClients, newWorkers
+	workersMutex.Unlock()
+}
+
+//Connects to the workers, waiting for one to subscribe if none can be reached
+func connectWorkers() {
+	attemptConnectWorkers()
+	for len(workerClients) == 0 {
+		fmt.Println("No workers connected, waiting for one to subscribe")
+		time.Sleep(workerRetryInterval)
 		attemptConnectWorkers()
-		distributeWorkers(currentWorld)
-		checkFaults(currentWorld)
 	}
 }
 
 //Processes all turns of GOL
 func (b *BrokerOperations) BrokerRequest(req stubs.Request, resp *stubs.Response) (err error) {
+	//A controller that has just quit can leave its request running as the next one arrives
+	requestMutex.Lock()
+	defer requestMutex.Unlock()
+	workersMutex.Lock()
+	runningRequest = true
+	workersMutex.Unlock()
+	defer func() {
+		workersMutex.Lock()
+		runningRequest = false
+		workersMutex.Unlock()
+		//A stop sent as the request finished isn't left to stop the next one
+		select {
+		case <-stopCallChannel:
+		default:
+		}
+	}()
 	attemptConnectWorkers()
 
-	currentWorld := req.CurrentWorld
+	rule := req.Rule
+	//grey levels are kept as decay states under a Generations rule
+	currentWorld := util.PackWorldStates(req.CurrentWorld, rule)
 	turns := req.Turns
+	var life *util.HashLife
+	if req.Engine == hashLifeEngine {
+		life, err = util.NewHashLife(currentWorld, rule)
+		if err != nil {
+			return
+		}
+	}
+	//an unbounded world is also run by the broker, with currentWorld holding the part of it that started in the image
+	var universe *util.Unbounded
+	if req.Unbounded {
+		if err = util.CheckUnboundedRule(rule); err != nil {
+			return
+		}
+		//Checkpoints only hold the part of the world in the image
+		if req.CheckpointTurns > 0 || req.CheckpointInterval > 0 {
+			return errors.New("checkpoints only keep worlds with edges")
+		}
+		universe = util.NewUnbounded(currentWorld)
+	}
+	//the worlds are hashed to spot them repeating if asked to, starting with the one sent
+	var cycles *util.CycleDetector
+	if req.CycleHistory > 0 {
+		cycles = util.NewCycleDetector(req.CycleHistory)
+		cycles.Observe(worldHash(currentWorld, universe), req.StartTurn)
+	}
+	statsMutex.Lock()
+	pendingStats = nil
+	statsMutex.Unlock()
+	tickerMutex.Lock()
+	cycleToSend = stubs.AliveCellsResponse{}
+	tickerMutex.Unlock()
+	//Unless the broker runs the world itself, the workers keep their bands of it between turns,
+	//and currentWorld is only brought up to date with them when the whole world is needed
+	usingBands := life == nil && universe == nil
+	if usingBands {
+		err = startBands(currentWorld, rule, req.Topology, req.StartTurn, cycles != nil)
+		if err != nil {
+			return
+		}
+	}
+	//The request gives up with an error if the workers keep failing while the world is collected from them
+	latestWorld := func() (util.Bitboard, error) {
+		if usingBands {
+			world, err := collectWorld()
+			if err != nil {
+				return currentWorld, err
+			}
+			currentWorld = world
+		}
+		return currentWorld, nil
+	}
+	var previousUniverse *util.Unbounded
 	breakLoop := false
-	for turn := 0; turn < turns; turn++ {
+	killed := false
+	lastCheckpoint := time.Now()
+	resp.CompletedTurns = req.StartTurn
+	for turn := req.StartTurn; turn < turns; turn++ {
 		tickerMutex.Lock()
-		aliveCellsToSend = getAliveCellsCount(currentWorld)
+		if universe != nil {
+			aliveCellsToSend = universe.AliveCount()
+		} else if usingBands {
+			aliveCellsToSend = bandsAliveCount
+		} else {
+			aliveCellsToSend = currentWorld.AliveCount()
+		}
 		turnToSend = turn
+		batchingTurns = false
+		answerAliveCells()
 		tickerMutex.Unlock()
-		var nextWorld [][]byte
-		//Splitting up world and distributing to channels
-		distributeWorkers(currentWorld)
-		//Checking for faults
-		checkFaults(currentWorld)
-		//Reconstructing image from worker channels
-		for i := range clientChannels {
-			nextSlice := <-clientChannels[i]
-			for j := range nextSlice {
-				nextWorld = append(nextWorld, nextSlice[j])
+		//Waiting for the controller to write a checkpoint,
+		//giving up if it isn't written in time so a missing controller can't stall the turns forever
+		checkpointDue := req.CheckpointTurns > 0 && turn%req.CheckpointTurns == 0
+		checkpointDue = checkpointDue || (req.CheckpointInterval > 0 && time.Since(lastCheckpoint) >= req.CheckpointInterval)
+		if checkpointDue && turn > req.StartTurn {
+			var checkpointWorld util.Bitboard
+			checkpointWorld, err = latestWorld()
+			if err != nil {
+				break
+			}
+			checkpointMutex.Lock()
+			pendingCheckpoint = &stubs.PGMResponse{World: checkpointWorld.Unpack(), Turns: turn}
+			checkpointMutex.Unlock()
+			select {
+			case <-checkpointWritten:
+			case <-stopCallChannel:
+				breakLoop = true
+			case <-time.After(checkpointWriteTimeout):
+				fmt.Println("Checkpoint after turn", turn, "was not written")
+			}
+			checkpointMutex.Lock()
+			if pendingCheckpoint == nil {
+				//Written just as the wait ended
+				select {
+				case <-checkpointWritten:
+				default:
+				}
+			}
+			pendingCheckpoint = nil
+			checkpointMutex.Unlock()
+			lastCheckpoint = time.Now()
+			if breakLoop {
+				break
 			}
 		}
-		//Event handling
+		//Event handling, before the turn is run so the world is still the one after turn turns
 		pgmMutex.Lock()
 		if requestingPGM {
-			PGMChannel <- currentWorld
+			//The snapshot is still answered with the last world there is if the workers fail
+			var pgmWorld util.Bitboard
+			pgmWorld, err = latestWorld()
+			PGMChannel <- pgmWorld.Unpack()
 			turnChannel <- turn
 			requestingPGM = false
+			if err != nil {
+				pgmMutex.Unlock()
+				break
+			}
 		}
 		pgmMutex.Unlock()
 		killMutex.Lock()
 		if requestingShutdown {
 			shutdownChannel <- true
 			requestingShutdown = false
+			killed = true
+			killMutex.Unlock()
 			break
 		}
 		killMutex.Unlock()
@@ -184,9 +532,74 @@ func (b *BrokerOperations) BrokerRequest(req stubs.Request, resp *stubs.Response
 			pauseChannel <- true
 		default:
 		}
+		nextWorld := util.Bitboard{Width: currentWorld.Width}
+		//HashLife jumps as many turns as it can at once instead of using the workers
+		completedTurns := 1
+		if life != nil {
+			completedTurns = maxTurnsAtOnce(req, turn)
+			life.Step(completedTurns)
+			nextWorld = life.World()
+		} else if universe != nil {
+			previousUniverse = universe
+			universe = universe.Step(rule)
+			nextWorld = universe.Window(0, 0, currentWorld.Width, currentWorld.Height())
+		} else {
+			//Each worker runs the turns on its own band, getting the edges of the bands around it from their workers.
+			//The statistics and the search for cycles need every turn, but otherwise a batch of turns is run at once,
+			//and pauses, snapshots and alive counts are taken between batches, when the world is a whole turn on.
+			//The batch is cut down to the next turn when one of those is already waiting.
+			if !req.Stats && cycles == nil {
+				completedTurns = batchTurns(req, turn)
+			}
+			tickerMutex.Lock()
+			batchingTurns = completedTurns > 1
+			tickerMutex.Unlock()
+			err = stepWorkers(completedTurns)
+			if err != nil {
+				break
+			}
+			nextWorld = currentWorld
+			//The statistics need the whole world every turn
+			if req.Stats {
+				nextWorld, err = collectWorld()
+				if err != nil {
+					break
+				}
+			}
+		}
 		if req.Turns > 0 {
+			if req.Stats {
+				recordStats(currentWorld, nextWorld, previousUniverse, universe, turn+completedTurns, req.StatsSlices)
+			}
 			currentWorld = nextWorld
+			turn += completedTurns - 1
+		}
+		if cycles != nil {
+			hash := worldHash(currentWorld, universe)
+			if usingBands {
+				hash = bandsHash
+			}
+			if start, period, found := cycles.Observe(hash, turn+1); found {
+				resp.CycleTurn, resp.CycleStart, resp.CyclePeriod = turn+1, start, period
+				//The controller is told about the cycle the next time it asks for the alive cells
+				tickerMutex.Lock()
+				cycleToSend = stubs.AliveCellsResponse{CycleTurn: turn + 1, CycleStart: start, CyclePeriod: period}
+				tickerMutex.Unlock()
+				cycles = nil
+				//The world is the same after every whole cycle, so they can be skipped
+				if req.StopOnCycle {
+					skipped := (turns - turn - 1) / period * period
+					turn += skipped
+					if usingBands {
+						err = skipBands(skipped)
+						if err != nil {
+							break
+						}
+					}
+				}
+			}
 		}
+		resp.CompletedTurns = turn + 1
 		select {
 		case <-stopCallChannel:
 			breakLoop = true
@@ -196,89 +609,93 @@ func (b *BrokerOperations) BrokerRequest(req stubs.Request, resp *stubs.Response
 			break
 		}
 	}
-	resp.NextWorld = currentWorld
-
-	//calculate the alive cells
-	aliveCells := make([]util.Cell, 0, len(currentWorld)*len(currentWorld[0]))
-	for i, _ := range currentWorld {
-		for j, _ := range currentWorld[i] {
-			if currentWorld[i][j] == 0xFF {
-				newCell := util.Cell{X: j, Y: i}
-				aliveCells = append(aliveCells, newCell)
-			}
+	//An alive count can only be waiting if the last turns were a batch, which the workers have finished
+	tickerMutex.Lock()
+	if batchingTurns {
+		aliveCellsToSend, turnToSend = bandsAliveCount, bandsTurn
+	}
+	batchingTurns = false
+	answerAliveCells()
+	tickerMutex.Unlock()
+	//The workers failing ends the request
+	if err != nil {
+		return
+	}
+	//The controller has gone once the broker is killed, so the world isn't collected for it
+	if !killed {
+		_, err = latestWorld()
+		if err != nil {
+			return
 		}
 	}
-
-	resp.AliveCells = aliveCells
+	resp.NextWorld = currentWorld.Unpack()
+	resp.AliveCells = currentWorld.AliveCells()
+	if universe != nil {
+		resp.AliveCells = universe.AliveCells()
+	}
 	return
 }
 
-func distributeWorkers(currentWorld [][]byte) {
-	columnsPerChannel := len(currentWorld) / len(workerClients)
-	remainders := len(currentWorld) % len(workerClients)
-	offset := 0
-	for sliceNum := 0; sliceNum < len(workerClients); sliceNum++ {
-		go callWorker(clientChannels[sliceNum], workerClients[sliceNum], safetyChannels[sliceNum])
-		currentSlice := sliceWorld(sliceNum, columnsPerChannel, currentWorld, &remainders, &offset)
-		clientChannels[sliceNum] <- currentSlice
+//Keeps the statistics of a turn for the controller to fetch, with the given number of slices
+//They are of the unbounded world if there is one, with the slices of the part of it in the image
+func recordStats(previousWorld, nextWorld util.Bitboard, previousUniverse, universe *util.Unbounded, turns, slices int) {
+	if slices < 1 {
+		slices = 1
+	}
+	var stats util.Stats
+	if universe != nil {
+		stats = util.NewUnboundedStats(previousUniverse, universe, nextWorld, slices)
+	} else {
+		stats = util.NewStats(previousWorld, nextWorld, slices)
 	}
+	stats.CompletedTurns = turns
+	statsMutex.Lock()
+	pendingStats = append(pendingStats, stats)
+	statsMutex.Unlock()
 }
 
-func sliceWorld(sliceNum int, columnsPerChannel int, currentWorld [][]byte, remainderThreads *int, offset *int) [][]uint8 {
-	var currentSlice [][]uint8
-	//Adding extra column to back of slice to avoid lines of cells that aren't processed
-	extraBackColumnIndex := boundNumber(sliceNum*columnsPerChannel-1+*offset, len(currentWorld))
-	currentSlice = append(currentSlice, currentWorld[extraBackColumnIndex])
-	for i := 0; i < columnsPerChannel; i++ {
-		currentSlice = append(currentSlice, currentWorld[boundNumber(sliceNum*columnsPerChannel+i+*offset, len(currentWorld))])
-	}
-	//Adding extra column to this thread if the world doesn't split into each thread without remainders
-	if *remainderThreads > 0 {
-		*remainderThreads -= 1
-		currentSlice = append(currentSlice,
-			currentWorld[boundNumber(sliceNum*columnsPerChannel+columnsPerChannel+*offset, len(currentWorld))])
-		*offset += 1
-	}
-	//Adding extra column to front of slice to avoid lines of cells that aren't processed
-	extraFrontColumnIndex := boundNumber(sliceNum*columnsPerChannel+columnsPerChannel+*offset, len(currentWorld))
-	currentSlice = append(currentSlice, currentWorld[extraFrontColumnIndex])
-	return currentSlice
+//Hashes the whole world, which is the unbounded one if there is one rather than the part of it in the image
+func worldHash(currentWorld util.Bitboard, universe *util.Unbounded) uint64 {
+	if universe != nil {
+		return universe.Hash()
+	}
+	return currentWorld.Hash()
 }
 
-//calculates the number of alive cells given the current world
-func getAliveCellsCount(currentWorld [][]byte) int {
-	counter := 0
-	for i, _ := range currentWorld {
-		for j, _ := range currentWorld[i] {
-			if currentWorld[i][j] == 0xFF {
-				counter++
-			}
+//The turns left before the last turn or the next checkpoint, which the world has to be stopped at
+func turnsUntilStop(req stubs.Request, turn int) int {
+	maxTurns := req.Turns - turn
+	if req.CheckpointTurns > 0 {
+		untilCheckpoint := req.CheckpointTurns - turn%req.CheckpointTurns
+		if untilCheckpoint < maxTurns {
+			maxTurns = untilCheckpoint
 		}
 	}
-	return counter
+	return maxTurns
 }
 
-func callWorker(channel chan [][]uint8, workerClient *rpc.Client, safetyChannel chan bool) {
-	req := stubs.Request{CurrentWorld: <-channel}
-	resp := new(stubs.Response)
-	err := workerClient.Call(stubs.ProcessSlice, req, resp)
-	if err != nil {
-		safetyChannel <- false
-		channel <- nil
-	} else {
-		safetyChannel <- true
-		channel <- resp.NextWorld
+//The most turns HashLife can jump at once without going past the last turn or a checkpoint.
+//Jumps are powers of two so that the results of earlier jumps can be reused.
+func maxTurnsAtOnce(req stubs.Request, turn int) int {
+	maxTurns := turnsUntilStop(req, turn)
+	jump := 1
+	for jump*2 <= maxTurns {
+		jump *= 2
 	}
+	return jump
 }
 
-func boundNumber(num int, worldLen int) int {
-	if num < 0 {
-		return num + worldLen
-	} else if num > worldLen-1 {
-		return num - worldLen
-	} else {
-		return num
+//The most turns the workers can run at once without going past the last turn or a checkpoint,
+//or keeping a pause, snapshot or alive count waiting
+func batchTurns(req stubs.Request, turn int) int {
+	turns := turnsUntilStop(req, turn)
+	if requestPending() {
+		return 1
+	}
+	if turns > bandsBatch {
+		turns = bandsBatch
 	}
+	return turns
 }
 
 func main() {
@@ -291,16 +708,31 @@ func main() {
 	pauseChannel = make(chan bool)
 	shutdownChannel = make(chan bool)
 	turnChannel = make(chan int)
-	stopCallChannel = make(chan bool)
+	stopCallChannel = make(chan bool, 1)
+	checkpointWritten = make(chan bool, 1)
 	tickerMutex = sync.Mutex{}
 	turnToSend = 0
 	aliveCellsToSend = 0
+	workerHeartbeats = make(map[string]time.Time)
 	err := rpc.Register(&BrokerOperations{})
 	if err != nil {
 		fmt.Println(err)
 	}
 	port := flag.String("port", "8040", "Port broker will listen on")
+	flag.DurationVar(&livenessTimeout, "timeout", defaultLivenessTimeout,
+		"How long a worker can go without a heartbeat before it is removed")
+	flag.IntVar(&maxBatchTurns, "batch", defaultBatchTurns,
+		"How many turns the workers run at once from deeper halos, if the rule and topology let them")
+	flag.IntVar(&snapshotTurns, "snapshot", defaultSnapshotTurns,
+		"How many turns between collecting the whole world to recover from if a worker fails, which moves the whole world over the network. 0 only recovers from the last world collected for a checkpoint or snapshot, replaying every turn since")
 	flag.Parse()
+	if livenessTimeout <= 0 {
+		livenessTimeout = defaultLivenessTimeout
+	}
+	if maxBatchTurns < 1 {
+		maxBatchTurns = defaultBatchTurns
+	}
+	go monitorWorkers()
 	listener, err = net.Listen("tcp", ":"+*port)
 	if err != nil {
 		fmt.Println("Broker listening error: ", err.Error())
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"reflect"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/band"
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//startWorker serves the broker's operations and the bands the real workers keep on a free localhost port,
+//returning the listener on it
+func startWorker(t *testing.T) net.Listener {
+	server := rpc.NewServer()
+	server.Register(&BrokerOperations{})
+	server.RegisterName("WorkerOperations", &band.Worker{})
+	workerListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(workerListener)
+	return workerListener
+}
+
+//resetWorkers forgets every worker, as if the broker had just started
+func resetWorkers(timeout time.Duration) {
+	workers = nil
+	workerClients = nil
+	connectedWorkers = nil
+	workerHeartbeats = make(map[string]time.Time)
+	livenessTimeout = timeout
+}
+
+// TestHeartbeats checks that workers missing their heartbeats are removed, and that a heartbeat subscribes them again.
+func TestHeartbeats(t *testing.T) {
+	resetWorkers(time.Second)
+	b := &BrokerOperations{}
+	for _, ip := range []string{"a:1", "b:2", "c:3"} {
+		b.SubscribeWorker(stubs.SubscriptionRequest{IP: ip}, new(stubs.GenericMessage))
+	}
+	//subscribing twice doesn't give a worker two slices
+	b.SubscribeWorker(stubs.SubscriptionRequest{IP: "a:1"}, new(stubs.GenericMessage))
+
+	workerHeartbeats["b:2"] = time.Now().Add(-2 * time.Second)
+	removeDeadWorkers(time.Now())
+	if expected := []string{"a:1", "c:3"}; !reflect.DeepEqual(workers, expected) {
+		t.Errorf("Expected workers %v after b:2 missed its heartbeats, got %v", expected, workers)
+	}
+
+	b.Heartbeat(stubs.SubscriptionRequest{IP: "b:2"}, new(stubs.GenericMessage))
+	removeDeadWorkers(time.Now())
+	if expected := []string{"a:1", "c:3", "b:2"}; !reflect.DeepEqual(workers, expected) {
+		t.Errorf("Expected workers %v after b:2 sent a heartbeat, got %v", expected, workers)
+	}
+
+	removeDeadWorkers(time.Now().Add(2 * time.Second))
+	if len(workers) != 0 {
+		t.Errorf("Expected no workers after they all missed their heartbeats, got %v", workers)
+	}
+}
+
+// TestUnsubscribe checks that a worker leaving is no longer dialled, and that its connection is closed.
+func TestUnsubscribe(t *testing.T) {
+	resetWorkers(time.Second)
+	b := &BrokerOperations{}
+	workerListener := startWorker(t)
+	defer workerListener.Close()
+	ip := workerListener.Addr().String()
+	b.SubscribeWorker(stubs.SubscriptionRequest{IP: ip}, new(stubs.GenericMessage))
+	attemptConnectWorkers()
+	if len(workerClients) != 1 {
+		t.Fatalf("Expected to connect to 1 worker, connected to %v", len(workerClients))
+	}
+	client := workerClients[0]
+
+	b.UnsubscribeWorker(stubs.SubscriptionRequest{IP: ip}, new(stubs.GenericMessage))
+	if len(workers) != 0 {
+		t.Errorf("Expected no workers after unsubscribing, got %v", workers)
+	}
+	if err := client.Call(stubs.GetStats, stubs.GenericMessage{}, new(stubs.StatsResponse)); err != rpc.ErrShutdown {
+		t.Errorf("Expected the connection to the worker to be closed, got %v", err)
+	}
+	attemptConnectWorkers()
+	if len(workerClients) != 0 {
+		t.Errorf("Expected to connect to no workers after unsubscribing, connected to %v", len(workerClients))
+	}
+}
+
+// TestRebalance checks that workers joining and leaving between turns get a share of the world without the turns going
+// wrong, and that the connections to the workers that stay are kept.
+func TestRebalance(t *testing.T) {
+	resetWorkers(time.Second)
+	rule, _ := util.ParseRule("B3/S23")
+	topology, _ := util.ParseTopology("")
+	world := util.NewBitboard(16, 16)
+	for _, cell := range []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}} {
+		world.Set(cell.X, cell.Y, true)
+	}
+
+	b := &BrokerOperations{}
+	var addresses []string
+	for i := 0; i < 3; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		addresses = append(addresses, workerListener.Addr().String())
+	}
+	first, second, third := addresses[0], addresses[1], addresses[2]
+	b.SubscribeWorker(stubs.SubscriptionRequest{IP: first}, new(stubs.GenericMessage))
+	attemptConnectWorkers()
+	firstClient := workerClients[0]
+	startBands(world, rule, topology, 0, true)
+	stepWorkers(1)
+
+	//each change in the workers is picked up before the next turn
+	changes := []struct {
+		method  string
+		ip      string
+		workers int
+	}{
+		{stubs.SubscribeWorker, second, 2},
+		{stubs.SubscribeWorker, third, 3},
+		{stubs.UnsubscribeWorker, second, 2},
+	}
+	for _, change := range changes {
+		if change.method == stubs.SubscribeWorker {
+			b.SubscribeWorker(stubs.SubscriptionRequest{IP: change.ip}, new(stubs.GenericMessage))
+		} else {
+			//A worker leaving during a run is only forgotten until the next turn, which drops its connection
+			workersMutex.Lock()
+			forgetWorker(change.ip)
+			workersMutex.Unlock()
+		}
+		if !workersChanged {
+			t.Fatalf("Expected the workers to have changed after %v %v", change.method, change.ip)
+		}
+		stepWorkers(1)
+		if len(workerClients) != change.workers {
+			t.Fatalf("Expected %v workers after %v %v, got %v", change.workers, change.method, change.ip, len(workerClients))
+		}
+		if workerClients[0] != firstClient {
+			t.Errorf("Expected the connection to %v to be kept after %v %v", first, change.method, change.ip)
+		}
+	}
+	world, err := collectWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []util.Cell{{X: 2, Y: 1}, {X: 3, Y: 2}, {X: 1, Y: 3}, {X: 2, Y: 3}, {X: 3, Y: 3}}
+	if alive := world.AliveCells(); !reflect.DeepEqual(alive, expected) {
+		t.Errorf("Expected the glider to be at %v after 4 turns, got %v", expected, alive)
+	}
+	if bandsAliveCount != len(expected) || bandsHash != world.Hash() {
+		t.Errorf("Expected the bands to add up to %v alive cells and a hash of %x, got %v and %x",
+			len(expected), world.Hash(), bandsAliveCount, bandsHash)
+	}
+}
+
+// TestBandPeers checks that each band is given the workers with the bands its halos come from, which for a torus are
+// the bands either side of it, and which can be further away when the edges are twisted or the bands are thin.
+func TestBandPeers(t *testing.T) {
+	connectedWorkers = []string{"a:1", "b:2", "c:3", "d:4"}
+	bandStarts, bandsWidth, bandsHeight, bandsBatch = []int{0, 4, 8, 12}, 16, 16, 1
+	tests := []struct {
+		topology string
+		rule     string
+		expected [][]string
+	}{
+		{"", util.ConwayRule, [][]string{{"b:2", "d:4"}, {"a:1", "c:3"}, {"b:2", "d:4"}, {"a:1", "c:3"}}},
+		{"dead", util.ConwayRule, [][]string{{"b:2"}, {"a:1", "c:3"}, {"b:2", "d:4"}, {"c:3"}}},
+		//the cells past the west and east edges of each row come from the row mirrored top to bottom
+		{"projective", util.ConwayRule, [][]string{{"b:2", "c:3", "d:4"}, {"a:1", "c:3", "d:4"}, {"a:1", "b:2", "d:4"},
+			{"a:1", "b:2", "c:3"}}},
+		{"dead", "R5,C0,M0,S2..3,B3..3,NM", [][]string{{"b:2", "c:3"}, {"a:1", "c:3", "d:4"},
+			{"a:1", "b:2", "d:4"}, {"b:2", "c:3"}}},
+	}
+	for _, test := range tests {
+		bandsTopology, _ = util.ParseTopology(test.topology)
+		bandsRule, _ = util.ParseRule(test.rule)
+		for i, expected := range test.expected {
+			var given []string
+			for _, peer := range bandPeers(i) {
+				given = append(given, peer.Address)
+			}
+			if !reflect.DeepEqual(given, expected) {
+				t.Errorf("%v %v: expected band %d to get its halos from %v, got %v", test.topology, test.rule, i,
+					expected, given)
+			}
+		}
+	}
+}
+
+// TestBatchTurns checks that the workers run several turns at once only when the rule and topology let them,
+// stopping at the last turn and at checkpoints, and that the turns come out the same as running them one at a time.
+func TestBatchTurns(t *testing.T) {
+	resetWorkers(time.Second)
+	defer func() { maxBatchTurns = defaultBatchTurns }()
+	maxBatchTurns = 4
+	b := &BrokerOperations{}
+	for i := 0; i < 3; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		b.SubscribeWorker(stubs.SubscriptionRequest{IP: workerListener.Addr().String()}, new(stubs.GenericMessage))
+	}
+	attemptConnectWorkers()
+
+	batches := []struct {
+		rule     string
+		topology string
+		batch    int
+	}{
+		{util.ConwayRule, "", 4},
+		{util.ConwayRule, "dead", 4},
+		{util.ConwayRule, "klein", 1},
+		{"B2/S/C3", "", 1},
+	}
+	for _, test := range batches {
+		rule, _ := util.ParseRule(test.rule)
+		topology, _ := util.ParseTopology(test.topology)
+		startBands(util.NewBitboard(16, 16), rule, topology, 0, false)
+		if bandsBatch != test.batch {
+			t.Errorf("%v %v: expected batches of %d turns, got %d", test.rule, test.topology, test.batch, bandsBatch)
+		}
+	}
+
+	rule, _ := util.ParseRule(util.ConwayRule)
+	for _, name := range []string{"", "dead"} {
+		topology, _ := util.ParseTopology(name)
+		world := util.NewBitboard(16, 16)
+		for _, cell := range []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}} {
+			world.Set(cell.X, cell.Y, true)
+		}
+		startBands(world, rule, topology, 0, true)
+		req := stubs.Request{Turns: 30}
+		for turn := 0; turn < req.Turns; turn = bandsTurn {
+			stepWorkers(batchTurns(req, turn))
+		}
+		expected := world
+		for turn := 0; turn < req.Turns; turn++ {
+			slice, edges := topology.Slice(expected, 0, expected.Height(), 1)
+			expected = slice.Step(rule, edges)
+		}
+		given, err := collectWorld()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(given.AliveCells(), expected.AliveCells()) {
+			t.Errorf("%v: expected %v after 30 turns in batches, got %v", name, expected.AliveCells(), given.AliveCells())
+		}
+		if bandsHash != given.Hash() {
+			t.Errorf("%v: expected the bands to add up to a hash of %x, got %x", name, given.Hash(), bandsHash)
+		}
+	}
+
+	//the batches end at checkpoints
+	req := stubs.Request{Turns: 30, CheckpointTurns: 10}
+	var given []int
+	for turn := 0; turn < req.Turns; turn += given[len(given)-1] {
+		given = append(given, batchTurns(req, turn))
+	}
+	if expected := []int{4, 4, 2, 4, 4, 2, 4, 4, 2}; !reflect.DeepEqual(given, expected) {
+		t.Errorf("Expected batches of %v turns, got %v", expected, given)
+	}
+
+	//a batch is cut down to the next turn while a snapshot, pause or alive count is waiting for it
+	requests := map[string]func(pending bool){
+		"snapshot": func(pending bool) { requestingPGM = pending },
+		"pause":    func(pending bool) { requestingPause = pending },
+		"alive count": func(pending bool) {
+			aliveCellsRequests = nil
+			if pending {
+				aliveCellsRequests = append(aliveCellsRequests, make(chan stubs.AliveCellsResponse, 1))
+			}
+		},
+	}
+	for name, request := range requests {
+		request(true)
+		if turns := batchTurns(req, 0); turns != 1 {
+			t.Errorf("Expected a batch of 1 turn while a %v is waiting, got %d", name, turns)
+		}
+		request(false)
+	}
+}
+
+// TestSkipBands checks that the workers carry on from the turn the bands skip to after a cycle, rather than the bands
+// having to be rebuilt as if a worker had failed.
+func TestSkipBands(t *testing.T) {
+	resetWorkers(time.Second)
+	b := &BrokerOperations{}
+	for i := 0; i < 3; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		b.SubscribeWorker(stubs.SubscriptionRequest{IP: workerListener.Addr().String()}, new(stubs.GenericMessage))
+	}
+	attemptConnectWorkers()
+	rule, _ := util.ParseRule(util.ConwayRule)
+	topology, _ := util.ParseTopology("")
+	world := util.NewBitboard(16, 16)
+	for _, cell := range []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}} {
+		world.Set(cell.X, cell.Y, true)
+	}
+	startBands(world, rule, topology, 0, true)
+	for turn := 0; turn < 4; turn++ {
+		stepWorkers(1)
+	}
+
+	//the glider comes back to where it was every 64 turns on a 16x16 torus
+	skipBands(64)
+	if !stepBands(1) {
+		t.Fatal("Expected the workers to run the turn after the skipped cycle without rebuilding the bands")
+	}
+	if bandsTurn != 69 {
+		t.Errorf("Expected the bands to have completed 69 turns, got %v", bandsTurn)
+	}
+	expected := world
+	for turn := 0; turn < 5; turn++ {
+		slice, edges := topology.Slice(expected, 0, expected.Height(), 1)
+		expected = slice.Step(rule, edges)
+	}
+	given, err := collectWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(given.AliveCells(), expected.AliveCells()) {
+		t.Errorf("Expected %v after skipping a cycle, got %v", expected.AliveCells(), given.AliveCells())
+	}
+}
+
+// TestSnapshotTurns checks that the world is only collected from the workers every so many turns,
+// as otherwise the turns only move the halo rows between them, and that it isn't collected at all when turned off.
+func TestSnapshotTurns(t *testing.T) {
+	resetWorkers(time.Second)
+	defer func() { snapshotTurns = defaultSnapshotTurns }()
+	snapshotTurns = 0
+	b := &BrokerOperations{}
+	for i := 0; i < 3; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		b.SubscribeWorker(stubs.SubscriptionRequest{IP: workerListener.Addr().String()}, new(stubs.GenericMessage))
+	}
+	attemptConnectWorkers()
+	rule, _ := util.ParseRule(util.ConwayRule)
+	topology, _ := util.ParseTopology("")
+	world := util.NewBitboard(16, 16)
+	for _, cell := range []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}} {
+		world.Set(cell.X, cell.Y, true)
+	}
+	startBands(world, rule, topology, 0, false)
+	for turn := 0; turn < 12; turn++ {
+		stepWorkers(1)
+	}
+	if snapshotTurn != 0 {
+		t.Errorf("Expected the world not to be collected with snapshots off, got a snapshot after turn %v", snapshotTurn)
+	}
+
+	snapshotTurns = 5
+	for turn := 12; turn < 24; turn++ {
+		stepWorkers(1)
+	}
+	//the first snapshot is taken as soon as the bands are 5 turns past the one they started from
+	if snapshotTurn != 23 {
+		t.Errorf("Expected the world to be collected after turns 13, 18 and 23, got a snapshot after turn %v",
+			snapshotTurn)
+	}
+}
+
+// TestBatchRequests checks that a snapshot, a pause and the alive counts taken while the workers run batches of turns
+// are each of the world after the turn they say.
+func TestBatchRequests(t *testing.T) {
+	resetWorkers(time.Second)
+	PGMChannel = make(chan [][]uint8, 1)
+	pauseChannel = make(chan bool)
+	turnChannel = make(chan int)
+	stopCallChannel = make(chan bool, 1)
+	b := &BrokerOperations{}
+	for i := 0; i < 3; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		b.SubscribeWorker(stubs.SubscriptionRequest{IP: workerListener.Addr().String()}, new(stubs.GenericMessage))
+	}
+	rule, _ := util.ParseRule(util.ConwayRule)
+	topology, _ := util.ParseTopology("")
+	//the r-pentomino's population changes nearly every turn for over a thousand turns
+	world := util.NewBitboard(64, 64)
+	for _, cell := range []util.Cell{{X: 31, Y: 30}, {X: 32, Y: 30}, {X: 30, Y: 31}, {X: 31, Y: 31}, {X: 31, Y: 32}} {
+		world.Set(cell.X, cell.Y, true)
+	}
+	worlds := []util.Bitboard{world}
+	after := func(turn int) util.Bitboard {
+		for len(worlds) <= turn {
+			slice, edges := topology.Slice(worlds[len(worlds)-1], 0, world.Height(), 1)
+			worlds = append(worlds, slice.Step(rule, edges))
+		}
+		return worlds[turn]
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		req := stubs.Request{CurrentWorld: world.Unpack(), Turns: 1 << 30, Rule: rule, Topology: topology}
+		done <- b.BrokerRequest(req, new(stubs.Response))
+	}()
+	checkAliveCells := func(when string) {
+		alive := new(stubs.AliveCellsResponse)
+		b.GetAliveCells(stubs.GenericMessage{}, alive)
+		//nothing has been counted until the first turn is run
+		for alive.TurnsCompleted == 0 {
+			time.Sleep(time.Millisecond)
+			b.GetAliveCells(stubs.GenericMessage{}, alive)
+		}
+		if expected := after(alive.TurnsCompleted).AliveCount(); alive.Cells != expected {
+			t.Errorf("%v: expected %v alive cells after turn %v, got %v", when, expected, alive.TurnsCompleted, alive.Cells)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		checkAliveCells("running")
+	}
+	pgm := new(stubs.PGMResponse)
+	b.KeyPressPGM(stubs.GenericMessage{}, pgm)
+	given, expected := util.PackWorldStates(pgm.World, rule).AliveCells(), after(pgm.Turns).AliveCells()
+	if !reflect.DeepEqual(given, expected) {
+		t.Errorf("Expected the snapshot after turn %v to be %v, got %v", pgm.Turns, expected, given)
+	}
+	pause := new(stubs.PauseResponse)
+	b.TogglePause(stubs.GenericMessage{}, pause)
+	checkAliveCells("paused")
+	alive := new(stubs.AliveCellsResponse)
+	b.GetAliveCells(stubs.GenericMessage{}, alive)
+	if alive.TurnsCompleted != pause.Turn {
+		t.Errorf("Expected the alive cells after turn %v while paused, got them after turn %v", pause.Turn,
+			alive.TurnsCompleted)
+	}
+	b.TogglePause(stubs.GenericMessage{}, pause)
+	if bandsBatch <= 1 {
+		t.Errorf("Expected the workers to run batches of turns, got batches of %v", bandsBatch)
+	}
+	b.DisconnectController(stubs.GenericMessage{}, new(stubs.GenericMessage))
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+// TestThinWorlds checks that a world with fewer rows than there are workers is split between as many of them as it
+// has rows, rather than giving the rest bands with no rows that they can't run.
+func TestThinWorlds(t *testing.T) {
+	resetWorkers(time.Second)
+	b := &BrokerOperations{}
+	for i := 0; i < 3; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		b.SubscribeWorker(stubs.SubscriptionRequest{IP: workerListener.Addr().String()}, new(stubs.GenericMessage))
+	}
+	attemptConnectWorkers()
+	rule, _ := util.ParseRule(util.ConwayRule)
+	for _, name := range []string{"", "dead"} {
+		topology, _ := util.ParseTopology(name)
+		for height := 1; height <= 2; height++ {
+			world := util.NewBitboard(8, height)
+			for _, cell := range []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 0}, {X: 3, Y: 0}, {X: 2, Y: height - 1}} {
+				world.Set(cell.X, cell.Y, true)
+			}
+			if err := startBands(world, rule, topology, 0, false); err != nil {
+				t.Fatal(err)
+			}
+			if len(bandStarts) != height {
+				t.Errorf("%v 8x%d: expected %d bands, got %d", name, height, height, len(bandStarts))
+			}
+			req := stubs.Request{Turns: 10}
+			for turn := 0; turn < req.Turns; turn = bandsTurn {
+				if err := stepWorkers(batchTurns(req, turn)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected := world
+			for turn := 0; turn < req.Turns; turn++ {
+				slice, edges := topology.Slice(expected, 0, expected.Height(), 1)
+				expected = slice.Step(rule, edges)
+			}
+			given, err := collectWorld()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(given.AliveCells(), expected.AliveCells()) {
+				t.Errorf("%v 8x%d: expected %v after 10 turns, got %v", name, height, expected.AliveCells(),
+					given.AliveCells())
+			}
+		}
+	}
+}
+
+//failingWorker takes the bands it is given but fails to run any turns on them
+type failingWorker struct{}
+
+func (w *failingWorker) LoadSlice(req stubs.Request, resp *stubs.GenericMessage) (err error) {
+	return
+}
+
+func (w *failingWorker) StepSlice(req stubs.StepRequest, resp *stubs.StepResponse) (err error) {
+	return errors.New("this worker can't run turns")
+}
+
+// TestRecoveryGivesUp checks that the broker stops rebuilding the bands and returns an error when the workers keep
+// failing, rather than trying again forever.
+func TestRecoveryGivesUp(t *testing.T) {
+	resetWorkers(time.Second)
+	server := rpc.NewServer()
+	server.RegisterName("WorkerOperations", &failingWorker{})
+	workerListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer workerListener.Close()
+	go server.Accept(workerListener)
+	b := &BrokerOperations{}
+	b.SubscribeWorker(stubs.SubscriptionRequest{IP: workerListener.Addr().String()}, new(stubs.GenericMessage))
+	attemptConnectWorkers()
+
+	rule, _ := util.ParseRule(util.ConwayRule)
+	topology, _ := util.ParseTopology("")
+	if err := startBands(util.NewBitboard(16, 16), rule, topology, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- stepWorkers(1)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected an error from a worker that fails every turn")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the broker to give up on a worker that fails every turn")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCheckpointResume writes a checkpoint every 25 turns of a 100 turn run on a 64x64 image,
+// then resumes from the checkpoint after 50 turns and checks it finishes with the same world.
+func TestCheckpointResume(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{
+		Turns:           100,
+		Threads:         4,
+		ImageWidth:      64,
+		ImageHeight:     64,
+		OutputFile:      dir,
+		CheckpointTurns: 25,
+		CheckpointFile:  filepath.Join(dir, "checkpoint{turns}.pgm"),
+	}
+	runFinalCells(p, nil)
+	for _, turns := range []int{25, 50, 75} {
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("checkpoint%d.pgm", turns))); err != nil {
+			t.Errorf("expected a checkpoint after %d turns: %v", turns, err)
+		}
+	}
+
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	resumed := gol.Params{
+		Turns:      100,
+		Threads:    3,
+		OutputFile: dir,
+		Resume:     filepath.Join(dir, "checkpoint50.pgm"),
+	}
+	cells := runFinalCells(resumed, nil)
+	assertEqualBoard(t, cells, expectedAlive, p)
+}
+
+// TestCheckpointRule checks that resuming uses the rule recorded in the checkpoint rather than the params.
+func TestCheckpointRule(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{
+		Turns:           20,
+		Threads:         2,
+		ImageWidth:      64,
+		ImageHeight:     64,
+		Rule:            "B36/S23",
+		OutputFile:      dir,
+		CheckpointTurns: 10,
+		CheckpointFile:  filepath.Join(dir, "checkpoint.pgm"),
+	}
+	expected := runFinalCells(p, nil)
+
+	resumed := gol.Params{
+		Turns:      20,
+		Threads:    2,
+		OutputFile: dir,
+		Resume:     filepath.Join(dir, "checkpoint.pgm"),
+	}
+	cells := runFinalCells(resumed, nil)
+	assertEqualBoard(t, cells, expected, p)
+}
@@ -0,0 +1,68 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// readCells parses a plaintext (.cells) pattern. Lines starting with '!' are comments, '.' is a dead cell
+// and 'O' is an alive cell. Rows may leave out their trailing dead cells.
+func readCells(r io.Reader) ([][]byte, error) {
+	reader := bufio.NewReader(r)
+	var rows []string
+	width := 0
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimRight(line, " \t\r\n")
+		if !strings.HasPrefix(line, "!") && (line != "" || err == nil) {
+			rows = append(rows, line)
+			if len(line) > width {
+				width = len(line)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	//Blank lines at the end of the file are not part of the pattern
+	for len(rows) > 0 && rows[len(rows)-1] == "" {
+		rows = rows[:len(rows)-1]
+	}
+
+	pattern := newPattern(width, len(rows))
+	for y, row := range rows {
+		for x := 0; x < len(row); x++ {
+			switch row[x] {
+			case '.':
+			case 'O', 'o', '*':
+				pattern[y][x] = 0xFF
+			default:
+				return nil, errors.New("invalid cell in plaintext pattern: " + row)
+			}
+		}
+	}
+	return pattern, nil
+}
+
+// writeCells writes the world as a plaintext (.cells) pattern.
+// Every row is written in full so that the pattern keeps the size of the world.
+func writeCells(w io.Writer, world [][]byte, rule string) error {
+	writer := bufio.NewWriter(w)
+	_, _ = writer.WriteString("!Rule: " + rule + "\n")
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 0xFF {
+				_ = writer.WriteByte('O')
+			} else {
+				_ = writer.WriteByte('.')
+			}
+		}
+		_ = writer.WriteByte('\n')
+	}
+	return writer.Flush()
+}
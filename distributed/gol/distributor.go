@@ -5,11 +5,11 @@ import (
 	"fmt"
 	"net/rpc"
 	"os"
-	"strconv"
 	"sync"
 	"time"
 
 	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 type distributorChannels struct {
@@ -19,41 +19,75 @@ type distributorChannels struct {
 	ioFilename chan<- string
 	ioOutput   chan<- uint8
 	ioInput    <-chan uint8
+	ioErrors   <-chan error
+	ioTurns    chan<- int
 	keyPresses <-chan rune
 }
 
-var eventsRoutineKilled bool
 var killLock sync.Mutex
-var killChannel chan bool
 var channelClosedLock sync.Mutex
 var eventsChannelClosed bool
+var outputFailed bool
+var failedTurns int
+var userQuit bool
+var brokerKilled bool
+var ioLock sync.Mutex
+
+//How often the broker is asked for a checkpoint to write
+const checkpointPollInterval = 50 * time.Millisecond
+
+//How often the broker is asked for the statistics of the turns it has completed
+const statsPollInterval = 100 * time.Millisecond
 
 // distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
-	eventsRoutineKilled = false
+// startTurn is the number of turns already completed by the world being read in, which is non-zero when resuming.
+func distributor(p Params, c distributorChannels, startTurn int) {
 	eventsChannelClosed = false
+	outputFailed = false
+	userQuit = false
+	brokerKilled = false
 	killLock = sync.Mutex{}
 	channelClosedLock = sync.Mutex{}
-	killChannel = make(chan bool)
-	var brokerIp string
-	readConfigFile(&brokerIp)
-
-	//Create a 2D slice to store the world
-	currentWorld := make([][]byte, p.ImageWidth)
-	for i := 0; i < p.ImageWidth; i++ {
-		currentWorld[i] = make([]byte, p.ImageHeight)
-	}
-
-	//read in initial state of GOL using io.go
-	width := strconv.Itoa(p.ImageWidth)
-	filename := width + "x" + width
-	fmt.Println(filename)
-	c.ioCommand <- ioInput
-	c.ioFilename <- filename
-	//read file into current world
-	for j, _ := range currentWorld {
-		for k, _ := range currentWorld[j] {
-			currentWorld[j][k] = <-c.ioInput
+	brokerIp, err := readConfigFile()
+	if err != nil {
+		quitWithError(c.events, configFile, err, 0)
+		return
+	}
+	//an empty rule means Conway's Game of Life, and an empty topology a torus
+	rule, err := ruleFromParams(p)
+	if err != nil {
+		quitWithError(c.events, "", err, startTurn)
+		return
+	}
+	topology, err := util.ParseTopology(p.Topology)
+	if err != nil {
+		quitWithError(c.events, "", err, startTurn)
+		return
+	}
+
+	//Create a 2D slice to store the world, indexed by row (y) then column (x)
+	currentWorld := make([][]byte, p.ImageHeight)
+	for i := 0; i < p.ImageHeight; i++ {
+		currentWorld[i] = make([]byte, p.ImageWidth)
+	}
+
+	//read in initial state of GOL using io.go, unless it starts as a random soup
+	if isRandom(p) {
+		currentWorld = randomWorld(p)
+	} else {
+		filename := inputFilename(p)
+		fmt.Println(filename)
+		c.ioCommand <- inputCommand(p)
+		c.ioFilename <- filename
+		if err := <-c.ioErrors; err != nil {
+			quitWithError(c.events, filename, err, startTurn)
+			return
+		}
+		//read file into current world
+		for y, _ := range currentWorld {
+			for x, _ := range currentWorld[y] {
+				currentWorld[y][x] = <-c.ioInput
+			}
 		}
 	}
 	//Execute all turns of the Game of Life.
@@ -62,56 +96,161 @@ func distributor(p Params, c distributorChannels) {
 	client, err := rpc.Dial("tcp", string(brokerIp))
 	if err != nil {
 		fmt.Println("Distributor dialing error: ", err.Error())
+		quitWithError(c.events, configFile, fmt.Errorf("dialing broker %v: %v", brokerIp, err), 0)
+		return
 	}
 	defer client.Close()
-	ticker := time.NewTicker(2 * time.Second)
-	go eventsRoutine(client, p, c, ticker)
-	req := stubs.Request{CurrentWorld: currentWorld, Turns: p.Turns}
+	//The statistics of every turn are written to a file if asked for
+	var stats *statsWriter
+	if p.StatsFile != "" {
+		if stats, err = createStatsFile(p.StatsFile); err != nil {
+			quitWithError(c.events, p.StatsFile, err, startTurn)
+			return
+		}
+	}
+	ticker := time.NewTicker(aliveCellsInterval(p))
+	stopEvents := make(chan bool)
+	eventsDone := make(chan bool)
+	cycleReported := false
+	go eventsRoutine(client, p, c, ticker, stopEvents, eventsDone, &cycleReported)
+	stopFetching := make(chan bool)
+	fetchingDone := make(chan bool)
+	if p.CheckpointTurns > 0 || p.CheckpointInterval > 0 {
+		go fetchCheckpoints(client, p, c, stopFetching, fetchingDone)
+	} else {
+		close(fetchingDone)
+	}
+	stopStats := make(chan bool)
+	statsDone := make(chan bool)
+	if stats != nil {
+		go pollStats(client, stats, p, c, stopStats, statsDone)
+	} else {
+		close(statsDone)
+	}
+	req := stubs.Request{
+		CurrentWorld:       currentWorld,
+		Turns:              p.Turns,
+		Rule:               rule,
+		StartTurn:          startTurn,
+		CheckpointTurns:    p.CheckpointTurns,
+		CheckpointInterval: p.CheckpointInterval,
+		Engine:             p.Engine,
+		Unbounded:          p.Unbounded,
+		Topology:           topology,
+		CycleHistory:       cycleHistory(p),
+		StopOnCycle:        p.StopOnCycle,
+		Stats:              stats != nil,
+		StatsSlices:        p.Threads,
+	}
 	resp := new(stubs.Response)
 	err = client.Call(stubs.BrokerRequest, req, resp)
 	ticker.Stop()
+	close(stopFetching)
+	<-fetchingDone
+	close(stopStats)
+	<-statsDone
+	close(stopEvents)
+	<-eventsDone
 	killLock.Lock()
-	if !eventsRoutineKilled {
-		killChannel <- true
-		<-killChannel
-	}
+	failed, quit, killed := outputFailed, userQuit, brokerKilled
 	killLock.Unlock()
 
+	//The world was written before the broker was killed, and the broker can go before it answers
+	if killed {
+		if stats != nil {
+			stats.close()
+		}
+		c.events <- StateChange{startTurn, Quitting}
+		closeEvents(c)
+		return
+	}
+
+	//Without an answer from the broker there is no final world to write
+	if err != nil && !failed {
+		c.events <- ErrorOccurred{CompletedTurns: startTurn, Err: fmt.Errorf("running turns on broker %v: %v", brokerIp, err)}
+		failed, failedTurns = true, startTurn
+	}
+	//Quitting ends the run at the turn the broker had reached
+	if err == nil && quit {
+		turns = resp.CompletedTurns
+	}
+
+	//The rest of the statistics are fetched now the broker has finished
+	if stats != nil {
+		if !failed {
+			if statsTurns, err := fetchStats(client, stats, p, c); err != nil {
+				failed, failedTurns = true, statsTurns
+			}
+		}
+		if err := stats.close(); err != nil && !failed {
+			c.events <- ErrorOccurred{CompletedTurns: turns, Filename: p.StatsFile, Err: err}
+			failed, failedTurns = true, turns
+		}
+	}
+
+	//The error has already been reported, so quit without a final turn
+	if failed {
+		c.events <- StateChange{failedTurns, Quitting}
+		closeEvents(c)
+		return
+	}
+
+	//A cycle found after the broker was last asked for the alive cells is only reported once it has finished
+	if resp.CyclePeriod > 0 && !cycleReported && !p.SkipEvents.Skips(SkipCycleDetected) {
+		c.events <- CycleDetected{CompletedTurns: resp.CycleTurn, StartTurn: resp.CycleStart, Period: resp.CyclePeriod}
+	}
+
+	//The final world is written before the final turn is reported, so that failing to write it is reported instead
+	if err := writeFile(p, c, resp.NextWorld, turns); err != nil {
+		c.events <- StateChange{turns, Quitting}
+		closeEvents(c)
+		return
+	}
+
 	//Report the final state using FinalTurnCompleteEvent.
 	c.events <- FinalTurnComplete{
 		CompletedTurns: turns,
 		Alive:          resp.AliveCells}
-	writeFile(p, c, resp.NextWorld, turns)
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
 
 	c.events <- StateChange{turns, Quitting}
+	closeEvents(c)
+}
 
-	// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+// Close the channel to stop the SDL goroutine gracefully. Removing may cause deadlock.
+func closeEvents(c distributorChannels) {
 	channelClosedLock.Lock()
 	eventsChannelClosed = true
 	channelClosedLock.Unlock()
 	close(c.events)
 }
 
-//Reads the broker's ip from gol/config and set brokerIp to it
-func readConfigFile(brokerIp *string) {
-	file, rerr := os.Open("gol/config")
-	if rerr != nil {
-		fmt.Println("Error reading config file: " + rerr.Error())
+//configFile contains the broker's ip
+const configFile = "gol/config"
+
+//Reads the broker's ip from the config file
+func readConfigFile() (string, error) {
+	file, err := os.Open(configFile)
+	if err != nil {
+		fmt.Println("Error reading config file: " + err.Error())
+		return "", err
 	}
+	defer file.Close()
 	reader := bufio.NewScanner(file)
 	reader.Scan()
-	*brokerIp = reader.Text()
-	err := file.Close()
-	if err != nil {
-		fmt.Println(err)
+	if err := reader.Err(); err != nil {
+		return "", err
 	}
+	return reader.Text(), nil
 }
 
 //goroutine for event handling
-func eventsRoutine(broker *rpc.Client, p Params, c distributorChannels, ticker *time.Ticker) {
+//cycleReported is set once a CycleDetected event has been sent, and can be read once done is closed
+func eventsRoutine(broker *rpc.Client, p Params, c distributorChannels, ticker *time.Ticker, stop <-chan bool,
+	done chan<- bool, cycleReported *bool) {
+	defer close(done)
 	breakloop := false
 	paused := false
 	for {
@@ -120,12 +259,15 @@ func eventsRoutine(broker *rpc.Client, p Params, c distributorChannels, ticker *
 			switch command {
 			case 's':
 				fmt.Println("s")
-				getPGMFromServer(broker, p, c)
+				if turns, err := getPGMFromServer(broker, p, c); err != nil {
+					stopAfterError(broker, turns)
+					breakloop = true
+				}
 			case 'k':
 				fmt.Println("k")
 				getPGMFromServer(broker, p, c)
 				killLock.Lock()
-				eventsRoutineKilled = true
+				brokerKilled = true
 				killLock.Unlock()
 				req := new(stubs.GenericMessage)
 				resp := new(stubs.GenericMessage)
@@ -136,18 +278,9 @@ func eventsRoutine(broker *rpc.Client, p Params, c distributorChannels, ticker *
 			case 'q':
 				fmt.Println("q")
 				killLock.Lock()
-				eventsRoutineKilled = true
+				userQuit = true
 				killLock.Unlock()
-				req := new(stubs.GenericMessage)
-				resp := new(stubs.GenericMessage)
-				err := broker.Call(stubs.DisconnectController, req, resp)
-				if err != nil {
-					fmt.Println(err)
-				}
-				err = broker.Close()
-				if err != nil {
-					fmt.Println(err)
-				}
+				disconnectController(broker)
 				breakloop = true
 			case 'p':
 				fmt.Println("p")
@@ -166,7 +299,10 @@ func eventsRoutine(broker *rpc.Client, p Params, c distributorChannels, ticker *
 				}
 			}
 		case <-ticker.C:
-			if !paused {
+			//The broker sends any cycle it has found with the alive cells, so it is reported as soon as it is found
+			sendAlive := !p.SkipEvents.Skips(SkipAliveCellsCount)
+			sendCycle := cycleHistory(p) > 0 && !*cycleReported && !p.SkipEvents.Skips(SkipCycleDetected)
+			if !paused && (sendAlive || sendCycle) {
 				req := stubs.GenericMessage{}
 				resp := new(stubs.AliveCellsResponse)
 				err := broker.Call(stubs.GetAliveCells, req, resp)
@@ -175,37 +311,157 @@ func eventsRoutine(broker *rpc.Client, p Params, c distributorChannels, ticker *
 				}
 				channelClosedLock.Lock()
 				if !eventsChannelClosed {
-					c.events <- AliveCellsCount{resp.TurnsCompleted, resp.Cells}
+					if sendAlive {
+						c.events <- AliveCellsCount{resp.TurnsCompleted, resp.Cells}
+					}
+					if sendCycle && resp.CyclePeriod > 0 {
+						c.events <- CycleDetected{CompletedTurns: resp.CycleTurn, StartTurn: resp.CycleStart,
+							Period: resp.CyclePeriod}
+						*cycleReported = true
+					}
 				}
 				channelClosedLock.Unlock()
 			}
-		case <-killChannel:
+		case <-stop:
 			breakloop = true
 		default:
 		}
 		if breakloop {
-			if !eventsRoutineKilled {
-				killChannel <- true
-			}
 			break
 		}
 	}
 }
 
-func getPGMFromServer(broker *rpc.Client, p Params, c distributorChannels) {
+//Writes the checkpoints the broker makes until told to stop, then closes done
+//The broker waits for each checkpoint to be written, which it is told about by the next call to GetCheckpoint
+func fetchCheckpoints(broker *rpc.Client, p Params, c distributorChannels, stop <-chan bool, done chan<- bool) {
+	defer close(done)
+	ticker := time.NewTicker(checkpointPollInterval)
+	defer ticker.Stop()
+	req := stubs.CheckpointRequest{WrittenTurn: -1}
+	for {
+		resp := new(stubs.PGMResponse)
+		if err := broker.Call(stubs.GetCheckpoint, req, resp); err != nil {
+			return
+		}
+		if resp.World != nil && resp.Turns != req.WrittenTurn {
+			if err := writeCheckpoint(p, c, resp.World, resp.Turns); err != nil {
+				stopAfterError(broker, resp.Turns)
+				return
+			}
+			//Ask again straight away to tell the broker it has been written
+			req.WrittenTurn = resp.Turns
+			continue
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+//Writes the statistics the broker keeps of every turn until told to stop, then closes done
+func pollStats(broker *rpc.Client, stats *statsWriter, p Params, c distributorChannels, stop <-chan bool, done chan<- bool) {
+	defer close(done)
+	ticker := time.NewTicker(statsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if turns, err := fetchStats(broker, stats, p, c); err != nil {
+				stopAfterError(broker, turns)
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+//Writes the statistics of the turns the broker has completed since they were last fetched
+//Returns the turn they couldn't be written after and the error, which has already been sent as an event
+func fetchStats(broker *rpc.Client, stats *statsWriter, p Params, c distributorChannels) (int, error) {
+	req := new(stubs.GenericMessage)
+	resp := new(stubs.StatsResponse)
+	if err := broker.Call(stubs.GetStats, req, resp); err != nil {
+		fmt.Println(err)
+		return 0, nil
+	}
+	for _, turnStats := range resp.Stats {
+		if err := stats.write(turnStats); err != nil {
+			c.events <- ErrorOccurred{CompletedTurns: turnStats.CompletedTurns, Filename: p.StatsFile, Err: err}
+			return turnStats.CompletedTurns, err
+		}
+	}
+	return 0, nil
+}
+
+//Records that a file couldn't be written and stops the broker so the distributor can quit, the error has already been sent as an event
+func stopAfterError(broker *rpc.Client, turns int) {
+	killLock.Lock()
+	outputFailed = true
+	failedTurns = turns
+	killLock.Unlock()
+	disconnectController(broker)
+}
+
+//Tells the broker to stop processing turns after the one it is on
+//The connection is left open for the broker to answer the request with the world it stopped at
+func disconnectController(broker *rpc.Client) {
+	req := new(stubs.GenericMessage)
+	resp := new(stubs.GenericMessage)
+	err := broker.Call(stubs.DisconnectController, req, resp)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+//Gets the current world from the broker and writes it as both a pgm image and an rle pattern
+//Returns the turn of the world and any error writing it
+func getPGMFromServer(broker *rpc.Client, p Params, c distributorChannels) (int, error) {
 	req := new(stubs.GenericMessage)
 	resp := new(stubs.PGMResponse)
 	err := broker.Call(stubs.KeyPressPGM, req, resp)
 	if err != nil {
 		fmt.Println(err)
 	}
-	writeFile(p, c, resp.World, resp.Turns)
+	err = writeFile(p, c, resp.World, resp.Turns)
+	if err == nil {
+		err = writeRleFile(p, c, resp.World, resp.Turns)
+	}
+	return resp.Turns, err
+}
+
+func writeFile(p Params, c distributorChannels, currentWorld [][]byte, turns int) error {
+	outFile := outputFilename(p, turns)
+	return writeWorld(p, c, outputCommand(outFile), outFile, currentWorld, turns)
+}
+
+//writes the world as an rle pattern next to the output file, unless the output file already is one
+func writeRleFile(p Params, c distributorChannels, currentWorld [][]byte, turns int) error {
+	outFile := outputFilename(p, turns)
+	if rleFile := rleFilename(outFile); rleFile != outFile {
+		return writeWorld(p, c, ioOutputPattern, rleFile, currentWorld, turns)
+	}
+	return nil
+}
+
+//writes a checkpoint that the run can be resumed from
+func writeCheckpoint(p Params, c distributorChannels, currentWorld [][]byte, turns int) error {
+	return writeWorld(p, c, ioOutputCheckpoint, checkpointFilename(p, turns), currentWorld, turns)
 }
 
-func writeFile(p Params, c distributorChannels, currentWorld [][]byte, turns int) {
-	outFile := strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(turns)
-	c.ioCommand <- ioOutput
+//sends the world to the io goroutine to be written using the given command
+//if the file can't be written an ErrorOccurred event is sent and the error is returned
+func writeWorld(p Params, c distributorChannels, command ioCommand, outFile string, currentWorld [][]byte, turns int) error {
+	//checkpoints are written at the same time as the events routine saves the world
+	ioLock.Lock()
+	c.ioCommand <- command
 	c.ioFilename <- outFile
+	if command == ioOutputCheckpoint {
+		c.ioTurns <- turns
+	}
 
 	//write file bit by bit.
 	for i := range currentWorld {
@@ -213,11 +469,23 @@ func writeFile(p Params, c distributorChannels, currentWorld [][]byte, turns int
 			c.ioOutput <- currentWorld[i][j]
 		}
 	}
-	// Make sure that the Io has finished any output before exiting.
-	c.ioCommand <- ioCheckIdle
-	<-c.ioIdle
-	c.events <- ImageOutputComplete{
-		CompletedTurns: turns,
-		Filename:       outFile,
+	err := <-c.ioErrors
+	if err == nil {
+		// Make sure that the Io has finished any output before exiting.
+		c.ioCommand <- ioCheckIdle
+		<-c.ioIdle
+	}
+	//The events are sent without the lock, as a run that has been left behind can wait on them forever
+	ioLock.Unlock()
+	if err != nil {
+		c.events <- ErrorOccurred{CompletedTurns: turns, Filename: outFile, Err: err}
+		return err
+	}
+	if !p.SkipEvents.Skips(SkipImageOutputComplete) {
+		c.events <- ImageOutputComplete{
+			CompletedTurns: turns,
+			Filename:       outFile,
+		}
 	}
+	return nil
 }
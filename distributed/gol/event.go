@@ -1,7 +1,10 @@
 package gol
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -47,14 +50,24 @@ type StateChange struct { // implements Event
 // CellFlipped is an Event notifying the GUI about a change of state of a single cell.
 // This even should be sent every time a cell changes state.
 // Make sure to send this event for all cells that are alive when the image is loaded in.
+// In an unbounded world the cell can be outside the image, including at negative coordinates.
 type CellFlipped struct { // implements Event
 	CompletedTurns int
 	Cell           util.Cell
 }
 
+// CellDecayed is an Event notifying the GUI that a cell has moved to a different decay state under a Generations rule.
+// Grey is the grey level of its new state, which is 0x00 once it has finished decaying.
+// A cell that dies is sent a CellFlipped event first, followed by this Event once it starts decaying.
+type CellDecayed struct { // implements Event
+	CompletedTurns int
+	Cell           util.Cell
+	Grey           uint8
+}
+
 // TurnComplete is an Event notifying the GUI about turn completion.
 // SDL will render a frame when this event is sent.
-// All CellFlipped events must be sent *before* TurnComplete.
+// All CellFlipped and CellDecayed events must be sent *before* TurnComplete.
 type TurnComplete struct { // implements Event
 	CompletedTurns int
 }
@@ -67,6 +80,79 @@ type FinalTurnComplete struct {
 	Alive          []util.Cell
 }
 
+// CycleDetected is an Event notifying the user that the world has returned to the state it was in after StartTurn
+// completed turns, so from then on it repeats every Period turns. It is sent once, when the repeat is first spotted.
+type CycleDetected struct { // implements Event
+	CompletedTurns int
+	StartTurn      int
+	Period         int
+}
+
+// ErrorOccurred is an Event notifying the user that a file could not be read or written, or that the world it
+// describes can't be run. Filename is empty when the error isn't about a file, such as params the engine can't run.
+// Execution stops after this Event is sent, so it is followed by a StateChange to Quitting.
+type ErrorOccurred struct { // implements Event
+	CompletedTurns int
+	Filename       string
+	Err            error
+}
+
+// EventFilter is a set of the types of Event not to send, so that runs without a window can skip the work of sending
+// an Event for every cell that changes. The zero value sends every type. FinalTurnComplete, StateChange and
+// ErrorOccurred are always sent.
+type EventFilter uint
+
+const (
+	SkipCellFlipped EventFilter = 1 << iota
+	SkipCellDecayed
+	SkipTurnComplete
+	SkipAliveCellsCount
+	SkipImageOutputComplete
+	SkipCycleDetected
+)
+
+// eventFilterNames are the names of the types of Event that can be skipped, in the same order as their filters.
+var eventFilterNames = []string{"CellFlipped", "CellDecayed", "TurnComplete", "AliveCellsCount", "ImageOutputComplete",
+	"CycleDetected"}
+
+// ParseEventFilter returns the filter skipping a comma separated list of the names of types of Event,
+// such as "CellFlipped,TurnComplete", ignoring case. An empty list skips nothing.
+func ParseEventFilter(names string) (EventFilter, error) {
+	var filter EventFilter
+	if names == "" {
+		return filter, nil
+	}
+	for _, name := range strings.Split(names, ",") {
+		found := false
+		for i, eventName := range eventFilterNames {
+			if strings.EqualFold(strings.TrimSpace(name), eventName) {
+				filter |= 1 << uint(i)
+				found = true
+			}
+		}
+		if !found {
+			return filter, errors.New("unknown event " + name + ", expected one of " +
+				strings.Join(eventFilterNames, ", "))
+		}
+	}
+	return filter, nil
+}
+
+// Skips returns whether any of the types of Event in other are skipped.
+func (f EventFilter) Skips(other EventFilter) bool {
+	return f&other != 0
+}
+
+func (f EventFilter) String() string {
+	var names []string
+	for i, name := range eventFilterNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
 // String methods allow the different types of Events and States to be printed.
 
 func (state State) String() string {
@@ -114,6 +200,14 @@ func (event CellFlipped) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CellDecayed) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event CellDecayed) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event TurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -130,6 +224,25 @@ func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CycleDetected) String() string {
+	return fmt.Sprintf("Cycle of period %v from turn %v", event.Period, event.StartTurn)
+}
+
+func (event CycleDetected) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
+func (event ErrorOccurred) String() string {
+	if event.Filename == "" {
+		return fmt.Sprintf("Failed: %v", event.Err)
+	}
+	return fmt.Sprintf("File %v failed: %v", event.Filename, event.Err)
+}
+
+func (event ErrorOccurred) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 // This might all seem like weird syntax to you...
 // You have however seen something similar to it before in first year.
 
@@ -1,9 +1,11 @@
 package gol
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"uk.ac.bris.cs/gameoflife/util"
@@ -16,6 +18,8 @@ type ioChannels struct {
 	filename <-chan string
 	output   <-chan uint8
 	input    chan<- uint8
+	errors   chan<- error
+	turns    <-chan int
 }
 
 // ioState is the internal ioState of the io goroutine.
@@ -25,6 +29,8 @@ type ioState struct {
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
+// After the filename for an input command the io goroutine sends an error (nil on success) before any data,
+// and after all the data for an output command it sends an error once the file has been written.
 type ioCommand uint8
 
 // This is a way of creating enums in Go.
@@ -32,97 +38,239 @@ type ioCommand uint8
 //		ioOutput 	= 0
 //		ioInput 	= 1
 //		ioCheckIdle = 2
+//		ioOutputPattern = 3
+//		ioInputPattern 	= 4
+//		ioOutputCheckpoint = 5
+//		ioInputScenario = 6
 const (
 	ioOutput ioCommand = iota
 	ioInput
 	ioCheckIdle
+	ioOutputPattern
+	ioInputPattern
+	ioOutputCheckpoint
+	ioInputScenario
 )
 
 // writePgmImage receives an array of bytes and writes it to a pgm file.
 func (io *ioState) writePgmImage() {
-	_ = os.Mkdir("out", os.ModePerm)
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
+
+	world := io.receiveWorld()
+
+	ioError := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if ioError == nil {
+		ioError = writePgmFile(filename, world, soupComments(io.params)...)
+	}
+	io.reportOutput(filename, ioError)
+}
+
+// readPgmImage opens a pgm file and sends its data as an array of bytes.
+func (io *ioState) readPgmImage() {
 
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	file, ioError := os.Create("out/" + filename + ".pgm")
-	util.Check(ioError)
-	defer file.Close()
+	world, ioError := readPgmFile(filename, io.params.ImageWidth, io.params.ImageHeight, keepsGreyLevels(io.params))
+	io.sendWorld(filename, world, ioError)
+}
 
-	_, _ = file.WriteString("P5\n")
-	//_, _ = file.WriteString("# PGM file writer by pnmmodules (https://github.com/owainkenwayucl/pnmmodules).\n")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageWidth))
-	_, _ = file.WriteString(" ")
-	_, _ = file.WriteString(strconv.Itoa(io.params.ImageHeight))
-	_, _ = file.WriteString("\n")
-	_, _ = file.WriteString(strconv.Itoa(255))
-	_, _ = file.WriteString("\n")
+// readScenarioWorld composes the world from the patterns listed in a scenario file and sends it as an array of bytes.
+func (io *ioState) readScenarioWorld() {
 
-	world := make([][]byte, io.params.ImageHeight)
-	for i := range world {
-		world[i] = make([]byte, io.params.ImageWidth)
-	}
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			val := <-io.channels.output
-			//if val != 0 {
-			//	fmt.Println(x, y)
-			//}
-			world[y][x] = val
-		}
-	}
+	world, ioError := readScenarioFile(filename, keepsGreyLevels(io.params))
+	io.sendWorld(filename, world, ioError)
+}
 
-	for y := 0; y < io.params.ImageHeight; y++ {
-		for x := 0; x < io.params.ImageWidth; x++ {
-			_, ioError = file.Write([]byte{world[y][x]})
-			util.Check(ioError)
-		}
+//the grey levels of a Generations rule are decay states, so they are kept when reading pgm images
+func keepsGreyLevels(p Params) bool {
+	ruleString := p.Rule
+	if ruleString == "" {
+		ruleString = util.ConwayRule
 	}
+	rule, _ := util.ParseRule(ruleString)
+	return rule.States > 2
+}
+
+// writePattern receives an array of bytes and writes it to a pattern file in the format given by its extension.
+func (io *ioState) writePattern() {
+	// Request a filename from the distributor.
+	filename := <-io.channels.filename
 
-	ioError = file.Sync()
-	util.Check(ioError)
+	world := io.receiveWorld()
 
-	fmt.Println("File", filename, "output done!")
+	rule := io.params.Rule
+	if rule == "" {
+		rule = util.ConwayRule
+	}
+	ioError := os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	if ioError == nil {
+		ioError = writePatternFile(filename, world, rule)
+	}
+	io.reportOutput(filename, ioError)
 }
 
-// readPgmImage opens a pgm file and sends its data as an array of bytes.
-func (io *ioState) readPgmImage() {
+// readPattern opens a pattern file in the format given by its extension and sends a world
+// containing the pattern as an array of bytes.
+func (io *ioState) readPattern() {
 
 	// Request a filename from the distributor.
 	filename := <-io.channels.filename
 
-	data, ioError := ioutil.ReadFile("images/" + filename + ".pgm")
-	util.Check(ioError)
+	pattern, x, y, ioError := readPatternFile(filename)
+	var world [][]byte
+	if ioError == nil {
+		world, ioError = placePattern(io.params, pattern, x, y)
+	}
+	io.sendWorld(filename, world, ioError)
+}
+
+// writeCheckpoint receives the completed turns and an array of bytes and writes them to a checkpoint
+// along with the rule and topology, so that the run can be resumed from it.
+func (io *ioState) writeCheckpoint() {
+	// Request a filename and the completed turns from the distributor.
+	filename := <-io.channels.filename
+	turns := <-io.channels.turns
 
-	fields := strings.Fields(string(data))
+	world := io.receiveWorld()
 
-	if fields[0] != "P5" {
-		panic("Not a pgm file")
+	rule := io.params.Rule
+	if rule == "" {
+		rule = util.ConwayRule
+	}
+	parsed, ioError := util.ParseRule(rule)
+	if ioError == nil {
+		ioError = os.MkdirAll(filepath.Dir(filename), os.ModePerm)
+	}
+	if ioError == nil {
+		comments := append(topologyComments(io.params, parsed), soupComments(io.params)...)
+		ioError = writeCheckpointFile(filename, world, turns, rule, comments...)
 	}
+	io.reportOutput(filename, ioError)
+}
+
+// receiveWorld receives a world the size of the image from the distributor as an array of bytes.
+func (io *ioState) receiveWorld() [][]byte {
+	world := make([][]byte, io.params.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, io.params.ImageWidth)
+		for x := range world[y] {
+			world[y][x] = <-io.channels.output
+		}
+	}
+	return world
+}
 
-	width, _ := strconv.Atoi(fields[1])
-	if width != io.params.ImageWidth {
-		panic("Incorrect width")
+// sendWorld first tells the distributor whether the input could be read.
+// If it could, the world is then sent as an array of bytes.
+func (io *ioState) sendWorld(filename string, world [][]byte, ioError error) {
+	io.channels.errors <- ioError
+	if ioError != nil {
+		return
 	}
 
-	height, _ := strconv.Atoi(fields[2])
-	if height != io.params.ImageHeight {
-		panic("Incorrect height")
+	for y := range world {
+		for _, b := range world[y] {
+			io.channels.input <- b
+		}
 	}
 
-	maxval, _ := strconv.Atoi(fields[3])
-	if maxval != 255 {
-		panic("Incorrect maxval/bit depth")
+	fmt.Println("File", filename, "input done!")
+}
+
+// reportOutput tells the distributor whether the output could be written.
+func (io *ioState) reportOutput(filename string, ioError error) {
+	io.channels.errors <- ioError
+	if ioError == nil {
+		fmt.Println("File", filename, "output done!")
 	}
+}
 
-	image := []byte(fields[4])
+// defaultOutputFile is the output filename template used when Params.OutputFile is empty.
+const defaultOutputFile = "out/{w}x{h}x{turns}.pgm"
 
-	for _, b := range image {
-		io.channels.input <- b
+// inputFilename returns the file the initial world should be read from.
+// When resuming this is the checkpoint.
+func inputFilename(p Params) string {
+	if p.Resume != "" {
+		return p.Resume
+	}
+	if p.InputFile != "" {
+		return p.InputFile
 	}
+	return "images/" + strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageHeight) + ".pgm"
+}
 
-	fmt.Println("File", filename, "input done!")
+// outputFilename fills in the output filename template for a world after the given number of turns.
+// If the template is a directory the default filename is used inside it.
+func outputFilename(p Params, turns int) string {
+	template := p.OutputFile
+	defaultTemplate := defaultOutputFile
+	if p.Random > 0 {
+		defaultTemplate = defaultRandomOutputFile
+	}
+	if template == "" {
+		template = defaultTemplate
+	} else if info, err := os.Stat(template); strings.HasSuffix(template, "/") || (err == nil && info.IsDir()) {
+		template = filepath.Join(template, filepath.Base(defaultTemplate))
+	}
+	return fillFilename(template, p, turns)
+}
+
+// fillFilename substitutes {w}, {h}, {turns} and the {seed} of a random soup in a filename template.
+func fillFilename(template string, p Params, turns int) string {
+	replacer := strings.NewReplacer(
+		"{w}", strconv.Itoa(p.ImageWidth),
+		"{h}", strconv.Itoa(p.ImageHeight),
+		"{turns}", strconv.Itoa(turns),
+		"{seed}", strconv.FormatInt(p.Seed, 10))
+	return replacer.Replace(template)
+}
+
+// WorldDimensions returns the size of the world described by the params.
+// A pgm input file, scenario or checkpoint defines the size of the world, whereas a pattern file (rle, cells or
+// life 1.06) is placed into a world of the requested size, or one the size of the pattern if no size was requested.
+func WorldDimensions(p Params) (width, height int, err error) {
+	if p.InputFile == "" && p.Resume == "" || isRandom(p) {
+		return p.ImageWidth, p.ImageHeight, nil
+	}
+	filename := inputFilename(p)
+	if isScenario(filename) {
+		s, err := readScenario(filename)
+		if err != nil {
+			return 0, 0, err
+		}
+		return s.Width, s.Height, nil
+	}
+	if isPattern(filename) {
+		if p.ImageWidth > 0 && p.ImageHeight > 0 {
+			return p.ImageWidth, p.ImageHeight, nil
+		}
+		pattern, _, _, err := readPatternFile(filename)
+		if err != nil {
+			return 0, 0, err
+		}
+		if len(pattern) == 0 {
+			return 0, 0, errors.New(filename + " is an empty pattern")
+		}
+		return len(pattern[0]), len(pattern), nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	header, err := readPgmHeader(bufio.NewReader(file))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%v: %v", filename, err)
+	}
+	return header.width, header.height, nil
 }
 
 // startIo should be the entrypoint of the io goroutine.
@@ -143,6 +291,14 @@ func startIo(p Params, c ioChannels) {
 				io.writePgmImage()
 			case ioCheckIdle:
 				io.channels.idle <- true
+			case ioInputPattern:
+				io.readPattern()
+			case ioOutputPattern:
+				io.writePattern()
+			case ioOutputCheckpoint:
+				io.writeCheckpoint()
+			case ioInputScenario:
+				io.readScenarioWorld()
 			}
 		}
 	}
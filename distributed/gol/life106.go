@@ -0,0 +1,88 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// life106Header is the first line of every Life 1.06 file.
+const life106Header = "#Life 1.06"
+
+// readLife106 parses a Life 1.06 pattern, which lists the x and y coordinates of each alive cell.
+// The pattern only covers the cells, wherever they are, and the coordinates of its top left cell are returned with it.
+func readLife106(r io.Reader) ([][]byte, int, int, error) {
+	scanner := bufio.NewScanner(r)
+	var cells [][2]int
+	sawHeader := false
+	var minX, minY, maxX, maxY int
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !sawHeader {
+			if line != life106Header {
+				return nil, 0, 0, errors.New("life 1.06 file is missing its " + life106Header + " header")
+			}
+			sawHeader = true
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		var x, y int
+		if _, err := fmt.Sscan(line, &x, &y); err != nil {
+			return nil, 0, 0, errors.New("invalid life 1.06 coordinates: " + line)
+		}
+		//the bounds start at the first cell, as the pattern can be anywhere
+		if len(cells) == 0 {
+			minX, minY, maxX, maxY = x, y, x, y
+		}
+		cells = append(cells, [2]int{x, y})
+		if x < minX {
+			minX = x
+		}
+		if y < minY {
+			minY = y
+		}
+		if x > maxX {
+			maxX = x
+		}
+		if y > maxY {
+			maxY = y
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+	if !sawHeader {
+		return nil, 0, 0, errors.New("life 1.06 file is missing its " + life106Header + " header")
+	}
+
+	if len(cells) == 0 {
+		return newPattern(0, 0), 0, 0, nil
+	}
+	pattern := newPattern(maxX-minX+1, maxY-minY+1)
+	for _, cell := range cells {
+		pattern[cell[1]-minY][cell[0]-minX] = 0xFF
+	}
+	return pattern, minX, minY, nil
+}
+
+// writeLife106 writes the coordinates of each alive cell in the world as a Life 1.06 pattern.
+// The format has no way of storing the rule, so it is left out.
+func writeLife106(w io.Writer, world [][]byte, rule string) error {
+	writer := bufio.NewWriter(w)
+	_, _ = writer.WriteString(life106Header + "\n")
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 0xFF {
+				_, _ = fmt.Fprintf(writer, "%d %d\n", x, y)
+			}
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,138 @@
+package gol
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patternFormat reads and writes one of the text based pattern file formats.
+// Patterns are read along with the coordinates of their top left cell, which are 0 unless the format gives them.
+type patternFormat struct {
+	read  func(r io.Reader) ([][]byte, int, int, error)
+	write func(w io.Writer, world [][]byte, rule string) error
+}
+
+// patternFormats maps file extensions to the pattern format they are read and written with.
+// Any other extension is treated as a pgm image.
+var patternFormats = map[string]patternFormat{
+	".rle":   {atOrigin(readRle), writeRle},
+	".cells": {atOrigin(readCells), writeCells},
+	".lif":   {readLife106, writeLife106},
+	".life":  {readLife106, writeLife106},
+}
+
+// atOrigin reads a pattern in a format without coordinates, whose top left cell is at the origin.
+func atOrigin(read func(r io.Reader) ([][]byte, error)) func(r io.Reader) ([][]byte, int, int, error) {
+	return func(r io.Reader) ([][]byte, int, int, error) {
+		pattern, err := read(r)
+		return pattern, 0, 0, err
+	}
+}
+
+// isPattern returns whether a file should be read or written as a pattern rather than a pgm image.
+func isPattern(filename string) bool {
+	_, ok := patternFormats[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// readPatternFile reads a pattern using the format given by the file's extension,
+// along with the coordinates of its top left cell.
+func readPatternFile(filename string) ([][]byte, int, int, error) {
+	format, ok := patternFormats[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil, 0, 0, errors.New(filename + " is not a pattern file")
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer file.Close()
+	return format.read(file)
+}
+
+// writePatternFile writes the world using the pattern format given by the file's extension.
+func writePatternFile(filename string, world [][]byte, rule string) error {
+	format, ok := patternFormats[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return errors.New(filename + " is not a pattern file")
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = format.write(file, world, rule)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// placePattern places a pattern into a world of the requested size, either centred or with its top left cell at
+// its own coordinates moved by the requested offset. Offsets wrap around the edges of the world.
+func placePattern(p Params, pattern [][]byte, x, y int) ([][]byte, error) {
+	patternHeight := len(pattern)
+	patternWidth := 0
+	if patternHeight > 0 {
+		patternWidth = len(pattern[0])
+	}
+	if patternWidth > p.ImageWidth || patternHeight > p.ImageHeight {
+		return nil, fmt.Errorf("%dx%d pattern does not fit in a %dx%d world",
+			patternWidth, patternHeight, p.ImageWidth, p.ImageHeight)
+	}
+
+	offsetX, offsetY := p.OffsetX+x, p.OffsetY+y
+	if p.Centre {
+		offsetX = (p.ImageWidth - patternWidth) / 2
+		offsetY = (p.ImageHeight - patternHeight) / 2
+	}
+
+	world := make([][]byte, p.ImageHeight)
+	for y := range world {
+		world[y] = make([]byte, p.ImageWidth)
+	}
+	for y := range pattern {
+		for x := range pattern[y] {
+			worldY := ((y+offsetY)%p.ImageHeight + p.ImageHeight) % p.ImageHeight
+			worldX := ((x+offsetX)%p.ImageWidth + p.ImageWidth) % p.ImageWidth
+			world[worldY][worldX] = pattern[y][x]
+		}
+	}
+	return world, nil
+}
+
+// inputCommand returns the command that makes the io goroutine read the input file's format.
+func inputCommand(p Params) ioCommand {
+	if isPattern(inputFilename(p)) {
+		return ioInputPattern
+	}
+	if isScenario(inputFilename(p)) {
+		return ioInputScenario
+	}
+	return ioInput
+}
+
+// outputCommand returns the command that makes the io goroutine write the output file's format.
+func outputCommand(filename string) ioCommand {
+	if isPattern(filename) {
+		return ioOutputPattern
+	}
+	return ioOutput
+}
+
+// rleFilename returns the filename with its extension replaced by .rle.
+func rleFilename(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".rle"
+}
+
+// newPattern allocates an empty pattern of the given size.
+func newPattern(width, height int) [][]byte {
+	pattern := make([][]byte, height)
+	for i := range pattern {
+		pattern[i] = make([]byte, width)
+	}
+	return pattern
+}
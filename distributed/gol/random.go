@@ -0,0 +1,52 @@
+package gol
+
+import (
+	"math/rand"
+	"strconv"
+)
+
+// defaultRandomOutputFile and defaultRandomCheckpointFile replace the default filename templates for random soups,
+// so that the seed needed to generate the soup again is in the name of everything written from it.
+const (
+	defaultRandomOutputFile     = "out/{w}x{h}x{turns}-seed{seed}.pgm"
+	defaultRandomCheckpointFile = "out/{w}x{h}-seed{seed}.checkpoint.pgm"
+)
+
+// Pgm images written from a random soup have these comments recording how to generate it again.
+const (
+	randomComment = "random:"
+	seedComment   = "seed:"
+)
+
+// isRandom returns whether the world starts as a random soup instead of being read from a file.
+// Resuming reads the world from the checkpoint even if it started as a soup.
+func isRandom(p Params) bool {
+	return p.Random > 0 && p.Resume == ""
+}
+
+// randomWorld generates a soup the size of the image where each cell is alive with a probability of p.Random.
+// The cells are worked out in order from p.Seed, so the same seed and density always give the same soup.
+func randomWorld(p Params) [][]byte {
+	random := rand.New(rand.NewSource(p.Seed))
+	world := newPattern(p.ImageWidth, p.ImageHeight)
+	for y := range world {
+		for x := range world[y] {
+			if random.Float64() < p.Random {
+				world[y][x] = 0xFF
+			}
+		}
+	}
+	return world
+}
+
+// soupComments returns the pgm comments recording the density and seed of a random soup,
+// or none if the world didn't start as one.
+func soupComments(p Params) []string {
+	if p.Random <= 0 {
+		return nil
+	}
+	return []string{
+		randomComment + " " + strconv.FormatFloat(p.Random, 'g', -1, 64),
+		seedComment + " " + strconv.FormatInt(p.Seed, 10),
+	}
+}
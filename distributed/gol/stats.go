@@ -0,0 +1,108 @@
+package gol
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// statsJsonlExtension is the extension of statistics files written as a JSON object per line instead of csv.
+const statsJsonlExtension = ".jsonl"
+
+// statsLine is a line of a jsonl statistics file, with the same names as the columns of a csv one.
+// The bounding box is left out when there are no alive cells.
+type statsLine struct {
+	CompletedTurns int       `json:"completed_turns"`
+	AliveCells     int       `json:"alive_cells"`
+	Births         int       `json:"births"`
+	Deaths         int       `json:"deaths"`
+	MinX           *int      `json:"min_x,omitempty"`
+	MinY           *int      `json:"min_y,omitempty"`
+	MaxX           *int      `json:"max_x,omitempty"`
+	MaxY           *int      `json:"max_y,omitempty"`
+	SliceDensity   []float64 `json:"slice_density"`
+}
+
+// statsWriter writes the statistics of every turn to a csv file, which starts with the same completed_turns and
+// alive_cells columns as check/alive/*.csv so it can be read in the same way, or to a jsonl file.
+type statsWriter struct {
+	file    *os.File
+	buffer  *bufio.Writer
+	csv     *csv.Writer
+	json    *json.Encoder
+	written bool
+}
+
+// createStatsFile creates a statistics file in the format given by its extension.
+func createStatsFile(filename string) (*statsWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &statsWriter{file: file, buffer: bufio.NewWriter(file)}
+	if strings.ToLower(filepath.Ext(filename)) == statsJsonlExtension {
+		s.json = json.NewEncoder(s.buffer)
+	} else {
+		s.csv = csv.NewWriter(s.buffer)
+	}
+	return s, nil
+}
+
+// write adds the statistics of a turn to the file, the first of which decide how many slice columns a csv file has.
+func (s *statsWriter) write(stats util.Stats) error {
+	if s.json != nil {
+		line := statsLine{CompletedTurns: stats.CompletedTurns, AliveCells: stats.Alive, Births: stats.Births,
+			Deaths: stats.Deaths, SliceDensity: stats.SliceDensity}
+		if stats.Alive > 0 {
+			line.MinX, line.MinY = &stats.TopLeft.X, &stats.TopLeft.Y
+			line.MaxX, line.MaxY = &stats.BottomRight.X, &stats.BottomRight.Y
+		}
+		return s.json.Encode(line)
+	}
+
+	if !s.written {
+		header := []string{"completed_turns", "alive_cells", "births", "deaths", "min_x", "min_y", "max_x", "max_y"}
+		for i := range stats.SliceDensity {
+			header = append(header, "slice_"+strconv.Itoa(i))
+		}
+		if err := s.csv.Write(header); err != nil {
+			return err
+		}
+		s.written = true
+	}
+	record := []string{strconv.Itoa(stats.CompletedTurns), strconv.Itoa(stats.Alive), strconv.Itoa(stats.Births),
+		strconv.Itoa(stats.Deaths), "", "", "", ""}
+	if stats.Alive > 0 {
+		record[4], record[5] = strconv.Itoa(stats.TopLeft.X), strconv.Itoa(stats.TopLeft.Y)
+		record[6], record[7] = strconv.Itoa(stats.BottomRight.X), strconv.Itoa(stats.BottomRight.Y)
+	}
+	for _, density := range stats.SliceDensity {
+		record = append(record, strconv.FormatFloat(density, 'g', -1, 64))
+	}
+	return s.csv.Write(record)
+}
+
+// close writes anything still buffered and closes the file.
+func (s *statsWriter) close() error {
+	var err error
+	if s.csv != nil {
+		s.csv.Flush()
+		err = s.csv.Error()
+	}
+	if flushErr := s.buffer.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strconv"
 	"strings"
 	"testing"
@@ -11,12 +12,14 @@ import (
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
-// TestGol tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using 1-16 worker threads.
+// TestGol tests 16x16, 64x64, 512x512, 64x16 and 16x64 images on 0, 1 and 100 turns using 1-16 worker threads.
 func TestGol(t *testing.T) {
 	tests := []gol.Params{
 		{ImageWidth: 16, ImageHeight: 16},
 		{ImageWidth: 64, ImageHeight: 64},
 		{ImageWidth: 512, ImageHeight: 512},
+		{ImageWidth: 64, ImageHeight: 16},
+		{ImageWidth: 16, ImageHeight: 64},
 	}
 	for _, p := range tests {
 		for _, turns := range []int{0, 1, 100} {
@@ -127,3 +130,13 @@ func readAliveCells(path string, width, height int) []util.Cell {
 	}
 	return cells
 }
+
+// tempDir makes a directory for the files of a test, returning it and a function removing it for the test to defer.
+func tempDir(t testing.TB) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
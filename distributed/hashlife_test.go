@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestHashLife tests 16x16, 64x64, 512x512, 64x16 and 16x64 images on 0, 1 and 100 turns using the HashLife engine.
+func TestHashLife(t *testing.T) {
+	tests := []gol.Params{
+		{ImageWidth: 16, ImageHeight: 16},
+		{ImageWidth: 64, ImageHeight: 64},
+		{ImageWidth: 512, ImageHeight: 512},
+		{ImageWidth: 64, ImageHeight: 16},
+		{ImageWidth: 16, ImageHeight: 64},
+	}
+	for _, p := range tests {
+		for _, turns := range []int{0, 1, 100} {
+			p.Turns = turns
+			p.Engine = gol.HashLife
+			expectedAlive := readAliveCells(
+				"check/images/"+fmt.Sprintf("%vx%vx%v.pgm", p.ImageWidth, p.ImageHeight, turns),
+				p.ImageWidth,
+				p.ImageHeight,
+			)
+			t.Run(fmt.Sprintf("%dx%dx%d", p.ImageWidth, p.ImageHeight, p.Turns), func(t *testing.T) {
+				assertEqualBoard(t, runFinalCells(p, nil), expectedAlive, p)
+			})
+		}
+	}
+}
+
+// TestHashLifeJump checks that HashLife jumps ten billion turns of the 512x512 image, which settles into
+// still lifes and blinkers, ending up where the brute force engine is after an even number of turns.
+func TestHashLifeJump(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{Turns: 10000000000, Threads: 8, ImageWidth: 512, ImageHeight: 512, OutputFile: dir, Engine: gol.HashLife}
+	events := make(chan gol.Event, 1000)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	lastTurn := 0
+	for event := range events {
+		switch e := event.(type) {
+		case gol.TurnComplete:
+			if e.CompletedTurns <= lastTurn {
+				t.Fatalf("turn %d completed after turn %d", e.CompletedTurns, lastTurn)
+			}
+			lastTurn = e.CompletedTurns
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+			if e.CompletedTurns != p.Turns {
+				t.Errorf("expected the final turn to be %d, got %d", p.Turns, e.CompletedTurns)
+			}
+		}
+	}
+
+	p.Turns, p.Engine = 6000, gol.BruteForce
+	assertEqualBoard(t, cells, runFinalCells(p, nil), p)
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"runtime"
+	"time"
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -36,6 +39,137 @@ func main() {
 		10000000000,
 		"Specify the number of turns to process. Defaults to 10000000000.")
 
+	flag.StringVar(
+		&params.Rule,
+		"rule",
+		util.ConwayRule,
+		"Specify the rule in B/S notation, e.g. B36/S23 for HighLife, or B/S/C notation for a Generations rule, e.g. B2/S/C3 for Brian's Brain. A V or H at the end uses the von Neumann or hexagonal neighbourhood, and Larger than Life rules use Golly's notation, e.g. R5,C0,M1,S33..57,B34..45,NM for Bosco's Rule. Defaults to B3/S23.")
+
+	flag.StringVar(
+		&params.InputFile,
+		"in",
+		"",
+		"Specify a pgm, rle, cells or life 1.06 (.lif) file, or a .json scenario composing the world from several of them, to load instead of images/<w>x<h>.pgm. The dimensions of a pgm or scenario are read from the file.")
+
+	flag.IntVar(
+		&params.OffsetX,
+		"x",
+		0,
+		"Specify the x offset to place a pattern at. Defaults to 0.")
+
+	flag.IntVar(
+		&params.OffsetY,
+		"y",
+		0,
+		"Specify the y offset to place a pattern at. Defaults to 0.")
+
+	flag.BoolVar(
+		&params.Centre,
+		"centre",
+		false,
+		"Places a pattern in the centre of the world instead of at its offset.")
+
+	flag.StringVar(
+		&params.OutputFile,
+		"out",
+		"",
+		"Specify an output directory or filename template using {w}, {h} and {turns}, whose extension selects the format. Defaults to out/{w}x{h}x{turns}.pgm.")
+
+	flag.IntVar(
+		&params.CheckpointTurns,
+		"checkpointTurns",
+		0,
+		"Specify the number of turns between checkpoints. Defaults to 0, which disables them.")
+
+	flag.DurationVar(
+		&params.CheckpointInterval,
+		"checkpointInterval",
+		0,
+		"Specify the time between checkpoints, e.g. 10m. Defaults to 0, which disables them.")
+
+	flag.StringVar(
+		&params.CheckpointFile,
+		"checkpoint",
+		"",
+		"Specify the checkpoint filename template using {w}, {h} and {turns}. Defaults to out/{w}x{h}.checkpoint.pgm.")
+
+	flag.StringVar(
+		&params.Resume,
+		"resume",
+		"",
+		"Specify a checkpoint to resume from. Its world, completed turns, rule and topology are used instead of -in, -rule, -neighbourhood, -range and -topology.")
+
+	flag.StringVar(
+		&params.Engine,
+		"engine",
+		gol.BruteForce,
+		"Specify the engine, bruteforce or hashlife. HashLife can jump billions of turns but needs the width and height to be powers of two. Defaults to bruteforce.")
+
+	flag.BoolVar(
+		&params.Unbounded,
+		"unbounded",
+		false,
+		"Runs the world without edges instead of wrapping around, so patterns can grow past the image. Images hold the part of the world the size of the image that it started in.")
+
+	flag.StringVar(
+		&params.Topology,
+		"topology",
+		"",
+		"Specify how the edges of the world are joined: torus, dead, reflect, klein or projective. Defaults to the topology of a .json scenario input file, or torus.")
+
+	flag.StringVar(
+		&params.Neighbourhood,
+		"neighbourhood",
+		"",
+		"Specify the neighbourhood to use instead of the rule's: moore, vonneumann or hex. Defaults to the rule's neighbourhood.")
+
+	flag.IntVar(
+		&params.Range,
+		"range",
+		0,
+		"Specify the range of the neighbourhood to use instead of the rule's, for Larger than Life rules. Defaults to the rule's range.")
+
+	flag.Float64Var(
+		&params.Random,
+		"random",
+		0,
+		"Specify the density of a random soup to start from instead of reading an image, e.g. 0.3. Defaults to 0, which reads the image.")
+
+	flag.Int64Var(
+		&params.Seed,
+		"seed",
+		0,
+		"Specify the seed of the random soup, which is recorded in the output filenames so the soup can be generated again. Defaults to a seed from the current time.")
+
+	flag.IntVar(
+		&params.CycleHistory,
+		"cycles",
+		0,
+		"Specify how many of the latest worlds to hash to spot the world repeating, reporting the period when it does. Defaults to 0, which doesn't look for repeats.")
+
+	flag.BoolVar(
+		&params.StopOnCycle,
+		"stopOnCycle",
+		false,
+		"Skips the remaining whole cycles once the world repeats, so the final world is worked out without running every turn. Hashes the latest 64 worlds if -cycles isn't set.")
+
+	flag.StringVar(
+		&params.StatsFile,
+		"stats",
+		"",
+		"Specify a csv file, or jsonl if it ends in .jsonl, to write the population, births, deaths, bounding box and density of each slice to after every turn. Defaults to none.")
+
+	flag.DurationVar(
+		&params.AliveCellsInterval,
+		"aliveInterval",
+		0,
+		"Specify how often to report the number of alive cells, e.g. 500ms. Defaults to 2s.")
+
+	skipEvents := flag.String(
+		"skipEvents",
+		"",
+		"Specify a comma separated list of the types of event not to send: CellFlipped, CellDecayed, TurnComplete, AliveCellsCount, ImageOutputComplete or CycleDetected. Defaults to none, or CellFlipped,CellDecayed,TurnComplete with -noVis.")
+
 	noVis := flag.Bool(
 		"noVis",
 		false,
@@ -43,9 +177,79 @@ func main() {
 
 	flag.Parse()
 
+	if params.Random < 0 || params.Random > 1 {
+		log.Fatalf("invalid density: %v is not between 0 and 1", params.Random)
+	}
+	seedSet, skipEventsSet := false, false
+	flag.Visit(func(f *flag.Flag) {
+		seedSet = seedSet || f.Name == "seed"
+		skipEventsSet = skipEventsSet || f.Name == "skipEvents"
+	})
+	if params.Random > 0 && !seedSet {
+		params.Seed = time.Now().UnixNano()
+	}
+
+	//Without a window nothing needs to know about every cell that changes
+	if *noVis && !skipEventsSet {
+		*skipEvents = "CellFlipped,CellDecayed,TurnComplete"
+	}
+	skip, err := gol.ParseEventFilter(*skipEvents)
+	if err != nil {
+		log.Fatalf("invalid events: %v", err)
+	}
+	params.SkipEvents = skip
+
+	if params.CycleHistory < 0 {
+		log.Fatalf("invalid cycle history: %v is negative", params.CycleHistory)
+	}
+
+	if _, err := util.ParseRule(params.Rule); err != nil {
+		log.Fatalf("invalid rule: %v", err)
+	}
+
+	if _, err := util.ParseTopology(params.Topology); err != nil {
+		log.Fatalf("invalid topology: %v", err)
+	}
+
+	if _, err := util.ParseNeighbourhood(params.Neighbourhood); err != nil {
+		log.Fatalf("invalid neighbourhood: %v", err)
+	}
+
+	width, height, err := gol.WorldDimensions(params)
+	if err != nil {
+		log.Fatalf("failed to read input file: %v", err)
+	}
+	params.ImageWidth, params.ImageHeight = width, height
+	params.Topology = gol.WorldTopology(params)
+
+	//The rule of a checkpoint is only read by Run, which reports it as an error if the engine can't run it
+	if params.Resume == "" {
+		if err := gol.CheckEngine(params); err != nil {
+			log.Fatalf("invalid engine: %v", err)
+		}
+	}
+
 	fmt.Println("Threads:", params.Threads)
 	fmt.Println("Width:", params.ImageWidth)
 	fmt.Println("Height:", params.ImageHeight)
+	fmt.Println("Rule:", params.Rule)
+	fmt.Println("Engine:", params.Engine)
+	fmt.Println("Topology:", params.Topology)
+	if params.Neighbourhood != "" || params.Range != 0 {
+		fmt.Println("Neighbourhood:", params.Neighbourhood, "Range:", params.Range)
+	}
+	if params.Random > 0 {
+		fmt.Println("Random:", params.Random, "Seed:", params.Seed)
+	}
+	if params.CycleHistory > 0 || params.StopOnCycle {
+		fmt.Println("Cycle history:", params.CycleHistory, "Stop on cycle:", params.StopOnCycle)
+	}
+	if params.SkipEvents != 0 {
+		fmt.Println("Skipping events:", params.SkipEvents)
+	}
+	if params.Resume != "" {
+		fmt.Println("Resuming from:", params.Resume)
+	}
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
@@ -54,12 +258,11 @@ func main() {
 	if !(*noVis) {
 		sdl.Run(params, events, keyPresses)
 	} else {
-		complete := false
-		for !complete {
-			event := <-events
+		//The events channel is closed once the final image has been written or an error has stopped execution
+		for event := range events {
 			switch event.(type) {
-			case gol.FinalTurnComplete:
-				complete = true
+			case gol.ErrorOccurred, gol.CycleDetected:
+				fmt.Println(event)
 			}
 		}
 	}
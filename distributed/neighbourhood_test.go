@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestNeighbourhoodParams checks that setting the neighbourhood and range in the params runs the same as
+// writing them into the rule, and that resuming from a checkpoint keeps them.
+func TestNeighbourhoodParams(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	tests := []struct {
+		rule          string
+		neighbourhood string
+		radius        int
+		expected      string
+	}{
+		{"B1/S01234", "vonneumann", 0, "B1/S01234V"},
+		{"B2/S34", "hex", 0, "B2/S34H"},
+		{"B3/S23", "", 2, "R2,C0,M0,S2..3,B3..3,NM"},
+		{"R2,C0,M0,S2..3,B3..3,NM", "moore", 1, "B3/S23"},
+	}
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			p := gol.Params{Turns: 20, Threads: 4, ImageWidth: 64, ImageHeight: 64, Rule: test.rule,
+				Neighbourhood: test.neighbourhood, Range: test.radius, OutputFile: dir,
+				CheckpointTurns: 10, CheckpointFile: filepath.Join(dir, "checkpoint.pgm")}
+			cells := runFinalCells(p, nil)
+
+			written := p
+			written.Rule, written.Neighbourhood, written.Range, written.CheckpointTurns = test.expected, "", 0, 0
+			assertEqualBoard(t, cells, runFinalCells(written, nil), written)
+
+			resumed := gol.Params{Turns: 20, Threads: 2, OutputFile: dir, Resume: filepath.Join(dir, "checkpoint.pgm")}
+			assertEqualBoard(t, runFinalCells(resumed, nil), cells, resumed)
+		})
+	}
+}
+
+// TestBosco runs a soup under Bosco's Rule, a Larger than Life rule with a range of 5, in a 32x32 world and in the same
+// world tiled four times, checking that the neighbours of cells near the edges and between bands wrap around.
+func TestBosco(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	random := rand.New(rand.NewSource(1))
+	var soup, tiled []util.Cell
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if random.Intn(2) == 0 {
+				soup = append(soup, util.Cell{X: x, Y: y})
+				tiled = append(tiled, util.Cell{X: x, Y: y}, util.Cell{X: x + 32, Y: y},
+					util.Cell{X: x, Y: y + 32}, util.Cell{X: x + 32, Y: y + 32})
+			}
+		}
+	}
+	filename, tiledFilename := filepath.Join(dir, "soup.cells"), filepath.Join(dir, "tiled.cells")
+	if err := writeCellsFile(filename, soup, 32, 32); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCellsFile(tiledFilename, tiled, 64, 64); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, threads := range []int{1, 4} {
+		t.Run(fmt.Sprint(threads), func(t *testing.T) {
+			p := gol.Params{Turns: 30, Threads: threads, ImageWidth: 32, ImageHeight: 32,
+				Rule: "R5,C0,M1,S33..57,B34..45,NM", InputFile: filename, OutputFile: dir}
+			cells := runFinalCells(p, nil)
+			if len(cells) == 0 || fmt.Sprint(cells) == fmt.Sprint(soup) {
+				t.Fatalf("expected the soup to change without dying out, got %v", cells)
+			}
+			whole := p
+			whole.ImageWidth, whole.ImageHeight, whole.InputFile = 64, 64, tiledFilename
+			assertEqualBoard(t, cells, cropCells(runFinalCells(whole, nil), 32, 32), p)
+		})
+	}
+}
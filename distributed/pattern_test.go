@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCellsFixtures runs the 16x16 plaintext fixtures for 0, 1 and 100 turns and checks they match the pgm fixtures.
+func TestCellsFixtures(t *testing.T) {
+	for _, turns := range []int{0, 1, 100} {
+		p := gol.Params{Turns: turns, Threads: 4, InputFile: "check/images/16x16x0.cells", OutputFile: os.TempDir()}
+		t.Run(fmt.Sprintf("16x16x%d", turns), func(t *testing.T) {
+			expectedAlive := readAliveCells(fmt.Sprintf("check/images/16x16x%d.pgm", turns), 16, 16)
+			fixture := gol.Params{InputFile: fmt.Sprintf("check/images/16x16x%d.cells", turns), OutputFile: os.TempDir()}
+			assertEqualBoard(t, runFinalCells(fixture, nil), expectedAlive, fixture)
+			p.ImageWidth, p.ImageHeight = 16, 16
+			assertEqualBoard(t, runFinalCells(p, nil), expectedAlive, p)
+		})
+	}
+}
+
+// TestPatternOutput writes the 16x16 world after 1 turn in each pattern format and reads it back.
+func TestPatternOutput(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	expectedAlive := readAliveCells("check/images/16x16x1.pgm", 16, 16)
+	for _, extension := range []string{".cells", ".lif", ".rle"} {
+		t.Run(extension, func(t *testing.T) {
+			p := gol.Params{Turns: 1, Threads: 2, ImageWidth: 16, ImageHeight: 16,
+				OutputFile: filepath.Join(dir, "{w}x{h}x{turns}"+extension)}
+			runFinalCells(p, nil)
+			filename := filepath.Join(dir, "16x16x1"+extension)
+			fromFile := gol.Params{ImageWidth: 16, ImageHeight: 16, InputFile: filename, OutputFile: dir}
+			assertEqualBoard(t, runFinalCells(fromFile, nil), expectedAlive, p)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const gliderRle = `#N Glider
+#C A comment line
+x = 3, y = 3, rule = B3/S23
+bo$2bo$3o!
+`
+
+// runs the game of life and returns the alive cells from the FinalTurnComplete event
+func runFinalCells(p gol.Params, keyPresses <-chan rune) []util.Cell {
+	events := make(chan gol.Event)
+	go gol.Run(p, events, keyPresses)
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	return cells
+}
+
+func offsetCells(cells []util.Cell, dx, dy int) []util.Cell {
+	var offset []util.Cell
+	for _, cell := range cells {
+		offset = append(offset, util.Cell{X: cell.X + dx, Y: cell.Y + dy})
+	}
+	return offset
+}
+
+// TestRleInput loads a glider from an rle file at an offset and in the centre of a 16x16 world and runs it for 4 turns.
+func TestRleInput(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "glider.rle")
+	if err := ioutil.WriteFile(filename, []byte(gliderRle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	glider := []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+	tests := map[string]struct {
+		p      gol.Params
+		dx, dy int
+	}{
+		"offset":  {gol.Params{OffsetX: 5, OffsetY: 2}, 5, 2},
+		"wrapped": {gol.Params{OffsetX: 14, OffsetY: -1}, -2, -1},
+		"centre":  {gol.Params{Centre: true}, 6, 6},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := test.p
+			p.Turns, p.Threads, p.ImageWidth, p.ImageHeight = 4, 2, 16, 16
+			p.InputFile = filename
+			p.OutputFile = dir
+			var expected []util.Cell
+			for _, cell := range offsetCells(glider, test.dx+1, test.dy+1) {
+				expected = append(expected, util.Cell{X: (cell.X + 16) % 16, Y: (cell.Y + 16) % 16})
+			}
+			cells := runFinalCells(p, nil)
+			assertEqualBoard(t, cells, expected, p)
+		})
+	}
+}
+
+// TestRleOutput saves the world as an rle file with the 's' key and checks it matches the pgm saved at the same time.
+func TestRleOutput(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	p := gol.Params{Turns: 100000000, Threads: 4, ImageWidth: 64, ImageHeight: 64, OutputFile: dir}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 2)
+	go gol.Run(p, events, keyPresses)
+	keyPresses <- 's'
+	var rleFile, pgmFile string
+	for event := range events {
+		switch e := event.(type) {
+		case gol.ImageOutputComplete:
+			if strings.HasSuffix(e.Filename, ".rle") {
+				rleFile = e.Filename
+				keyPresses <- 'q'
+			} else if rleFile == "" {
+				pgmFile = e.Filename
+			}
+		}
+	}
+	if rleFile == "" || pgmFile == "" {
+		t.Fatal("pressing 's' did not output both a pgm and an rle file")
+	}
+	if rleFilename := strings.TrimSuffix(pgmFile, ".pgm") + ".rle"; rleFile != rleFilename {
+		t.Fatalf("expected rle file %v, got %v", rleFilename, rleFile)
+	}
+	expected := readAliveCells(pgmFile, 64, 64)
+	cells := runFinalCells(gol.Params{Threads: 1, ImageWidth: 64, ImageHeight: 64, InputFile: rleFile, OutputFile: dir}, nil)
+	assertEqualBoard(t, cells, expected, p)
+}
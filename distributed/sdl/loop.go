@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+	//an unbounded world can grow past the window, so its alive cells are kept to be redrawn each frame
+	alive := make(map[util.Cell]bool)
 
 sdlLoop:
 	for {
@@ -35,8 +38,21 @@ sdlLoop:
 			}
 			switch e := event.(type) {
 			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
+				if p.Unbounded {
+					if alive[e.Cell] {
+						delete(alive, e.Cell)
+					} else {
+						alive[e.Cell] = true
+					}
+				} else {
+					w.FlipPixel(e.Cell.X, e.Cell.Y)
+				}
+			case gol.CellDecayed:
+				w.SetGrey(e.Cell.X, e.Cell.Y, e.Grey)
 			case gol.TurnComplete:
+				if p.Unbounded {
+					drawBoundingBox(w, alive)
+				}
 				w.RenderFrame()
 			case gol.FinalTurnComplete:
 				w.Destroy()
@@ -52,3 +68,40 @@ sdlLoop:
 	}
 
 }
+
+//draws the alive cells so that the window follows the smallest rectangle holding them all,
+//scaled down if it is bigger than the window and centred if it is smaller
+func drawBoundingBox(w *Window, alive map[util.Cell]bool) {
+	w.ClearPixels()
+	if len(alive) == 0 {
+		return
+	}
+	first := true
+	var topLeft, bottomRight util.Cell
+	for cell := range alive {
+		if first || cell.X < topLeft.X {
+			topLeft.X = cell.X
+		}
+		if first || cell.Y < topLeft.Y {
+			topLeft.Y = cell.Y
+		}
+		if first || cell.X > bottomRight.X {
+			bottomRight.X = cell.X
+		}
+		if first || cell.Y > bottomRight.Y {
+			bottomRight.Y = cell.Y
+		}
+		first = false
+	}
+	width, height := bottomRight.X-topLeft.X+1, bottomRight.Y-topLeft.Y+1
+	//the number of cells across and down that each pixel shows
+	scale := 1
+	for width > scale*int(w.Width) || height > scale*int(w.Height) {
+		scale++
+	}
+	offsetX := (int(w.Width) - (width+scale-1)/scale) / 2
+	offsetY := (int(w.Height) - (height+scale-1)/scale) / 2
+	for cell := range alive {
+		w.SetPixel(offsetX+(cell.X-topLeft.X)/scale, offsetY+(cell.Y-topLeft.Y)/scale)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestStatsCsv writes the statistics the broker keeps of every turn of 64x64 to a csv file, checking that its first
+// columns match check/alive, and that it has a slice for each thread asked for however many workers are connected.
+func TestStatsCsv(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	expected := readAliveCounts(64, 64)
+	for _, threads := range []int{1, 3, 5} {
+		t.Run(fmt.Sprint(threads), func(t *testing.T) {
+			p := gol.Params{Turns: 1000, Threads: threads, ImageWidth: 64, ImageHeight: 64,
+				OutputFile: dir, StatsFile: filepath.Join(dir, "stats.csv")}
+			runFinalCells(p, nil)
+
+			file, err := os.Open(p.StatsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			table, err := csv.NewReader(file).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(table) != p.Turns+1 || len(table[0]) != 8+threads {
+				t.Fatalf("expected a header and %d turns with %d slices, got %d rows and header %v",
+					p.Turns, threads, len(table), table[0])
+			}
+			for turn, row := range table[1:] {
+				alive, err := strconv.Atoi(row[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if alive != expected[turn+1] {
+					t.Fatalf("expected %d alive cells after %d turns, got %v", expected[turn+1], turn+1, row)
+				}
+			}
+		})
+	}
+}
+
+// TestStatsJsonl writes the statistics of every turn of a glider to a jsonl file with two threads, checking that the
+// density is of the two halves of the world rather than of the bands each worker keeps.
+func TestStatsJsonl(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "glider.rle")
+	if err := ioutil.WriteFile(filename, []byte(gliderRle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 8, Threads: 2, ImageWidth: 8, ImageHeight: 8, InputFile: filename, OffsetX: 4,
+		OutputFile: dir, StatsFile: filepath.Join(dir, "stats.jsonl")}
+	runFinalCells(p, nil)
+	file, err := os.Open(p.StatsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var densities [][]float64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var l struct {
+			SliceDensity []float64 `json:"slice_density"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			t.Fatal(err)
+		}
+		densities = append(densities, l.SliceDensity)
+	}
+	if len(densities) != 8 {
+		t.Fatalf("expected 8 lines, got %d", len(densities))
+	}
+	//the glider is in rows 1 to 3 after 4 turns, and rows 2 to 4 after 8, with 3 of its cells in row 4
+	expected := map[int][]float64{4: {5.0 / 32, 0}, 8: {2.0 / 32, 3.0 / 32}}
+	for turns, e := range expected {
+		if d := densities[turns-1]; fmt.Sprint(d) != fmt.Sprint(e) {
+			t.Errorf("expected a density of %v after %d turns, got %v", e, turns, d)
+		}
+	}
+}
@@ -1,10 +1,19 @@
 package stubs
 
-import "uk.ac.bris.cs/gameoflife/util"
+import (
+	"time"
 
-var ProcessSlice = "WorkerOperations.ProcessSlice"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+var LoadSlice = "WorkerOperations.LoadSlice"
+var StepSlice = "WorkerOperations.StepSlice"
+var CollectSlice = "WorkerOperations.CollectSlice"
+var GetBoundary = "WorkerOperations.GetBoundary"
 var BrokerRequest = "BrokerOperations.BrokerRequest"
 var SubscribeWorker = "BrokerOperations.SubscribeWorker"
+var UnsubscribeWorker = "BrokerOperations.UnsubscribeWorker"
+var Heartbeat = "BrokerOperations.Heartbeat"
 var SubscribeController = "BrokerOperations.SubscribeController"
 var GetAliveCells = "BrokerOperations.GetAliveCells"
 var KeyPressPGM = "BrokerOperations.KeyPressPGM"
@@ -12,19 +21,75 @@ var KillBroker = "BrokerOperations.Kill"
 var KillWorker = "WorkerOperations.Kill"
 var TogglePause = "BrokerOperations.TogglePause"
 var DisconnectController = "BrokerOperations.DisconnectController"
+var GetCheckpoint = "BrokerOperations.GetCheckpoint"
+var GetStats = "BrokerOperations.GetStats"
 
+//IP is the address the worker listens on, which also identifies it in heartbeats and when it unsubscribes
 type SubscriptionRequest struct {
 	IP string
 }
 
+//WrittenTurn is the turn of the last checkpoint the controller wrote, or -1 if it hasn't written one yet
+type CheckpointRequest struct {
+	WrittenTurn int
+}
+
 type PGMResponse struct{
 	World [][]uint8
 	Turns int
 }
 
+//CyclePeriod is the period of the cycle the broker has found the world in so far, or 0 if it hasn't found one,
+//as in Response, so the controller can report it without waiting for the run to finish.
 type AliveCellsResponse struct{
 	Cells int
 	TurnsCompleted int
+	CycleTurn int
+	CycleStart int
+	CyclePeriod int
+}
+
+//Stats holds the statistics of every turn completed since the controller last asked for them, in order
+type StatsResponse struct {
+	Stats []util.Stats
+}
+
+//StepRequest asks a worker to run Turns turns on the band it keeps, which should have completed Turn turns,
+//getting halos that many times as deep from its peers itself. Hash asks the worker for the hash of its band as well.
+type StepRequest struct {
+	Turn int
+	Turns int
+	Hash bool
+}
+
+//StepResponse holds the number of alive cells in a worker's band after its turns, and its part of the hash of the world
+//if it was asked for.
+type StepResponse struct {
+	AliveCount int
+	Hash uint64
+}
+
+//BoundaryRequest asks a worker for the edges of its band after Turn turns, which it keeps until it has run its turns
+//twice more.
+type BoundaryRequest struct {
+	Turn int
+}
+
+//BoundaryResponse holds the rows and cells along the edges of a worker's band, which is all the other bands need of
+//it: as many rows from its top and bottom as the range of the rule times the most turns it runs at once, and for every
+//row the cells as far as the range from its west and east ends, with bit i holding the cell i cells in from the end.
+type BoundaryResponse struct {
+	Top [][]uint64
+	Bottom [][]uint64
+	West []uint64
+	East []uint64
+}
+
+//Peer is another worker that a worker gets some of its halos from, keeping rows Start to End-1 of the world.
+type Peer struct {
+	Address string
+	Start int
+	End int
 }
 
 type GenericMessage struct {
@@ -36,12 +101,53 @@ type PauseResponse struct{
 	Resuming bool
 }
 
+//NextSlice is the next state of the rows of Slice a worker was asked to process
+//CyclePeriod is the period of the cycle the broker found the world in, or 0 if it didn't find one,
+//which was spotted after CycleTurn turns when the world repeated the one after CycleStart turns.
+//CompletedTurns is the number of turns NextWorld has completed, which is fewer than asked for if the controller quit.
 type Response struct {
 	NextWorld [][]uint8
 	AliveCells []util.Cell
+	NextSlice util.Bitboard
+	CycleTurn int
+	CycleStart int
+	CyclePeriod int
+	CompletedTurns int
 }
 
+//StartTurn is the number of turns CurrentWorld has already completed, which is non-zero when resuming from a checkpoint.
+//The broker keeps a checkpoint of the world every CheckpointTurns turns and every CheckpointInterval
+//for the controller to fetch with GetCheckpoint.
+//Slice is the band of the world a worker is given to keep with LoadSlice, which starts at row SliceStart of a world
+//WorldHeight rows high that has completed StartTurn turns. Peers are the workers with the rest of the rows its halos
+//come from, which it gives up on if they take longer than PeerTimeout to answer. BatchTurns is the most turns it is
+//asked to run at once.
+//Engine is gol.HashLife for the broker to jump turns with HashLife itself instead of using the workers.
+//Unbounded asks the broker to run the world without edges itself, returning every alive cell but only the part of
+//the world the size of CurrentWorld that it started in.
+//Topology says how the edges of the world are joined, which the halos of each slice or band are built with.
+//CycleHistory is how many of the latest worlds the broker hashes to spot the world repeating, and StopOnCycle asks it
+//to skip the remaining whole cycles once it does.
+//Stats asks the broker to keep the statistics of every turn for the controller to fetch with GetStats,
+//with the density of StatsSlices bands of rows, one for each of the controller's threads whatever the workers are.
 type Request struct {
 	CurrentWorld [][]uint8
+	Slice util.Bitboard
+	SliceStart int
+	WorldHeight int
+	Peers []Peer
+	PeerTimeout time.Duration
+	BatchTurns int
 	Turns int
+	Rule util.Rule
+	StartTurn int
+	CheckpointTurns int
+	CheckpointInterval time.Duration
+	Engine string
+	Unbounded bool
+	Topology util.Topology
+	CycleHistory int
+	StopOnCycle bool
+	Stats bool
+	StatsSlices int
 }
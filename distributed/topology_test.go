@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//writes the cells as a plaintext pattern of the given size
+func writeCellsFile(filename string, cells []util.Cell, width, height int) error {
+	rows := make([]string, height)
+	for y := range rows {
+		row := []byte(strings.Repeat(".", width))
+		for _, cell := range cells {
+			if cell.Y == y {
+				row[cell.X] = 'O'
+			}
+		}
+		rows[y] = string(row)
+	}
+	return ioutil.WriteFile(filename, []byte(strings.Join(rows, "\n")+"\n"), 0644)
+}
+
+//tiles copies of the cells of a width x height world, flipped so that running the tiles on a torus
+//runs the top left tile as if its edges were joined by the topology, and returns the size of the tiled world
+func coverCells(cells []util.Cell, width, height int, topology string) ([]util.Cell, int, int) {
+	var cover []util.Cell
+	for _, cell := range cells {
+		x, y := cell.X, cell.Y
+		flipX, flipY := width-1-x, height-1-y
+		switch topology {
+		case "klein":
+			cover = append(cover, cell, util.Cell{X: flipX, Y: height + y})
+		case "projective":
+			cover = append(cover, cell, util.Cell{X: width + x, Y: flipY},
+				util.Cell{X: flipX, Y: height + y}, util.Cell{X: width + flipX, Y: height + flipY})
+		case "reflect":
+			cover = append(cover, cell, util.Cell{X: width + flipX, Y: y},
+				util.Cell{X: x, Y: height + flipY}, util.Cell{X: width + flipX, Y: height + flipY})
+		}
+	}
+	if topology == "klein" {
+		return cover, width, 2 * height
+	}
+	return cover, 2 * width, 2 * height
+}
+
+//the cells in the top left width x height of the world
+func cropCells(cells []util.Cell, width, height int) []util.Cell {
+	var cropped []util.Cell
+	for _, cell := range cells {
+		if cell.X < width && cell.Y < height {
+			cropped = append(cropped, cell)
+		}
+	}
+	return cropped
+}
+
+// TestTopologyGliders sends a glider across the edges of a 20x16 world joined as a Klein bottle, a projective plane
+// and with reflecting edges, checking each against the same world tiled with flipped copies of itself on a torus.
+func TestTopologyGliders(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	//a glider heading down and to the right, which crosses the bottom edge, then the right edge through a corner
+	glider := []util.Cell{{X: 3, Y: 9}, {X: 4, Y: 10}, {X: 2, Y: 11}, {X: 3, Y: 11}, {X: 4, Y: 11}}
+	const width, height = 20, 16
+	for _, topology := range []string{"klein", "projective", "reflect"} {
+		for _, turns := range []int{0, 30, 64, 100} {
+			t.Run(fmt.Sprintf("%v-%d", topology, turns), func(t *testing.T) {
+				filename := filepath.Join(dir, topology+".cells")
+				if err := writeCellsFile(filename, glider, width, height); err != nil {
+					t.Fatal(err)
+				}
+				p := gol.Params{Turns: turns, Threads: 4, ImageWidth: width, ImageHeight: height, InputFile: filename,
+					OutputFile: dir, Topology: topology}
+
+				cover, coverWidth, coverHeight := coverCells(glider, width, height, topology)
+				coverFilename := filepath.Join(dir, topology+".cover.cells")
+				if err := writeCellsFile(coverFilename, cover, coverWidth, coverHeight); err != nil {
+					t.Fatal(err)
+				}
+				torus := p
+				torus.Topology, torus.ImageWidth, torus.ImageHeight = "torus", coverWidth, coverHeight
+				torus.InputFile = coverFilename
+
+				assertEqualBoard(t, runFinalCells(p, nil), cropCells(runFinalCells(torus, nil), width, height), p)
+			})
+		}
+	}
+}
+
+// TestTopologyDead sends a glider into the bottom right corner of a world with dead edges,
+// where it turns into a block instead of wrapping around.
+func TestTopologyDead(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "glider.rle")
+	if err := ioutil.WriteFile(filename, []byte(gliderRle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, threads := range []int{1, 4} {
+		p := gol.Params{Turns: 100, Threads: threads, ImageWidth: 16, ImageHeight: 16, InputFile: filename,
+			OutputFile: dir, OffsetX: 4, OffsetY: 4, Topology: "dead"}
+		block := []util.Cell{{X: 14, Y: 14}, {X: 15, Y: 14}, {X: 14, Y: 15}, {X: 15, Y: 15}}
+		assertEqualBoard(t, runFinalCells(p, nil), block, p)
+	}
+}
@@ -0,0 +1,531 @@
+package util
+
+import "math/bits"
+
+// wordSize is the number of cells packed into each word of a Bitboard.
+const wordSize = 64
+
+// Bitboard is a world with its cells packed 64 to a word, so that a whole word of cells can be updated at once.
+// Bit i of Rows[y][w] is the cell at x = 64*w + i, and bits past the width in the last word of a row are always 0.
+// Rows can be shared between bitboards, which is how a world is split into slices.
+// Under a Generations rule Decay holds the grey level of every decaying cell, indexed by row then column,
+// and 0 for cells that are alive or dead. It is nil under rules with only two states.
+type Bitboard struct {
+	Width int
+	Rows  [][]uint64
+	Decay [][]uint8
+}
+
+// NewBitboard allocates an empty bitboard of the given size.
+func NewBitboard(width, height int) Bitboard {
+	board := Bitboard{Width: width, Rows: make([][]uint64, height)}
+	for y := range board.Rows {
+		board.Rows[y] = make([]uint64, (width+wordSize-1)/wordSize)
+	}
+	return board
+}
+
+// PackWorld converts a world of 0x00 and 0xFF bytes indexed by row then column into a bitboard.
+func PackWorld(world [][]byte) Bitboard {
+	width := 0
+	if len(world) > 0 {
+		width = len(world[0])
+	}
+	board := NewBitboard(width, len(world))
+	for y := range world {
+		for x, cell := range world[y] {
+			if cell == 0xFF {
+				board.Rows[y][x/wordSize] |= 1 << uint(x%wordSize)
+			}
+		}
+	}
+	return board
+}
+
+// PackWorldStates converts a world into a bitboard for the rule. Under a Generations rule grey levels between
+// 0x00 and 0xFF become the decay states closest to them, otherwise only 0xFF bytes are alive as in PackWorld.
+func PackWorldStates(world [][]byte, rule Rule) Bitboard {
+	board := PackWorld(world)
+	if rule.States > 2 {
+		board = board.WithDecay()
+		for y := range world {
+			for x, cell := range world[y] {
+				board.SetGrey(x, y, rule.Grey(rule.State(cell)))
+			}
+		}
+	}
+	return board
+}
+
+// WithDecay returns the bitboard with room for the decay states of a Generations rule, all of which start at 0.
+func (b Bitboard) WithDecay() Bitboard {
+	if b.Decay == nil {
+		b.Decay = make([][]uint8, len(b.Rows))
+		for y := range b.Decay {
+			b.Decay[y] = make([]uint8, b.Width)
+		}
+	}
+	return b
+}
+
+// Unpack converts the bitboard back into a world of bytes indexed by row then column,
+// where alive cells are 0xFF, dead cells are 0x00 and decaying cells are their grey level.
+func (b Bitboard) Unpack() [][]byte {
+	world := make([][]byte, len(b.Rows))
+	for y := range world {
+		world[y] = make([]byte, b.Width)
+		for x := range world[y] {
+			world[y][x] = b.Grey(x, y)
+		}
+	}
+	return world
+}
+
+// Height returns the number of rows in the bitboard.
+func (b Bitboard) Height() int {
+	return len(b.Rows)
+}
+
+// Alive returns whether the cell at x, y is alive.
+func (b Bitboard) Alive(x, y int) bool {
+	return b.Rows[y][x/wordSize]>>uint(x%wordSize)&1 == 1
+}
+
+// Set makes the cell at x, y alive or dead.
+func (b Bitboard) Set(x, y int, alive bool) {
+	if alive {
+		b.Rows[y][x/wordSize] |= 1 << uint(x%wordSize)
+	} else {
+		b.Rows[y][x/wordSize] &^= 1 << uint(x%wordSize)
+	}
+}
+
+// Grey returns the grey level of the cell at x, y: 0xFF if it is alive, its decay level if it is decaying
+// and 0x00 if it is dead.
+func (b Bitboard) Grey(x, y int) uint8 {
+	if b.Alive(x, y) {
+		return 0xFF
+	}
+	if b.Decay != nil {
+		return b.Decay[y][x]
+	}
+	return 0x00
+}
+
+// SetGrey sets the cell at x, y from its grey level, the opposite of Grey.
+// Grey levels other than 0xFF are dead cells if the bitboard has no decay states.
+func (b Bitboard) SetGrey(x, y int, grey uint8) {
+	b.Set(x, y, grey == 0xFF)
+	if b.Decay != nil {
+		b.Decay[y][x] = 0
+		if grey != 0xFF {
+			b.Decay[y][x] = grey
+		}
+	}
+}
+
+// AliveCount returns the number of alive cells.
+func (b Bitboard) AliveCount() int {
+	count := 0
+	for _, row := range b.Rows {
+		for _, word := range row {
+			count += bits.OnesCount64(word)
+		}
+	}
+	return count
+}
+
+// AliveCells returns the alive cells.
+func (b Bitboard) AliveCells() []Cell {
+	cells := make([]Cell, 0, b.AliveCount())
+	for y, row := range b.Rows {
+		for w, word := range row {
+			for ; word != 0; word &= word - 1 {
+				cells = append(cells, Cell{X: w*wordSize + bits.TrailingZeros64(word), Y: y})
+			}
+		}
+	}
+	return cells
+}
+
+// FlippedCells calls flipped with every cell that differs between the two bitboards, which must be the same size.
+func (b Bitboard) FlippedCells(other Bitboard, flipped func(cell Cell)) {
+	for y, row := range b.Rows {
+		for w, word := range row {
+			for diff := word ^ other.Rows[y][w]; diff != 0; diff &= diff - 1 {
+				flipped(Cell{X: w*wordSize + bits.TrailingZeros64(diff), Y: y})
+			}
+		}
+	}
+}
+
+// DecayedCells calls decayed with every cell whose decay level differs between the two bitboards,
+// along with its level in the other one, which is 0 once it is dead or alive.
+func (b Bitboard) DecayedCells(other Bitboard, decayed func(cell Cell, grey uint8)) {
+	if b.Decay == nil || other.Decay == nil {
+		return
+	}
+	for y, row := range b.Decay {
+		for x, grey := range row {
+			if grey != other.Decay[y][x] {
+				decayed(Cell{X: x, Y: y}, other.Decay[y][x])
+			}
+		}
+	}
+}
+
+// Edges holds the cells just past the west and east ends of each row of a slice, including its halo rows.
+// Bit i is 1 if the cell i+1 cells past the end is alive and 0 if it is dead, out to the range of the rule.
+type Edges struct {
+	West []uint64
+	East []uint64
+}
+
+// Step returns the next state of every row apart from the halo rows, as many at the top and bottom as the range
+// of the rule, which hold the neighbours of the rows in between, given the cells just past the ends of every row.
+// Only the decay levels of the rows in between are used, so the halo rows don't need any.
+func (b Bitboard) Step(rule Rule, edges Edges) Bitboard {
+	depth := rule.Range
+	next := Bitboard{Width: b.Width, Rows: make([][]uint64, len(b.Rows)-2*depth)}
+	kernel := newKernel(rule, b.Width)
+	if b.Decay != nil {
+		next = next.WithDecay()
+	}
+	for y := range next.Rows {
+		next.Rows[y] = make([]uint64, len(b.Rows[y+depth]))
+		var decay, nextDecay []uint8
+		if b.Decay != nil {
+			decay, nextDecay = b.Decay[y+depth], next.Decay[y]
+		}
+		around := y + 2*depth + 1
+		kernel.stepRow(next.Rows[y], nextDecay, b.Rows[y:around], decay, edges.West[y:around], edges.East[y:around], b.Width)
+	}
+	return next
+}
+
+// StepBand returns the next state of a band of rows of a world, given as many halo rows above and below it as the
+// range of the rule, and the cells just past the ends of every row including the halo rows, as in Step.
+// It lets a worker keep its band between turns, being sent only the rows around it.
+func (b Bitboard) StepBand(rule Rule, top, bottom [][]uint64, edges Edges) Bitboard {
+	slice := Bitboard{Width: b.Width}
+	slice.Rows = append(slice.Rows, top...)
+	slice.Rows = append(slice.Rows, b.Rows...)
+	slice.Rows = append(slice.Rows, bottom...)
+	if b.Decay != nil {
+		slice.Decay = append(make([][]uint8, len(top)), b.Decay...)
+		slice.Decay = append(slice.Decay, make([][]uint8, len(bottom))...)
+	}
+	return slice.Step(rule, edges)
+}
+
+// StepBandTurns returns the state of a band of rows after turns turns, given turns times as many halo rows above and
+// below it as the range of the rule, which are worked out along with the band and so run out as the turns go by.
+// The band starts at row start of a world height rows high, whose edges are joined by a topology that CanStepTurns
+// allows with the rule, as then the cells past the ends of every row come from the row itself.
+func (b Bitboard) StepBandTurns(rule Rule, topology Topology, top, bottom [][]uint64, start, height, turns int) Bitboard {
+	depth := rule.Range
+	slice := Bitboard{Width: b.Width}
+	slice.Rows = append(slice.Rows, top...)
+	slice.Rows = append(slice.Rows, b.Rows...)
+	slice.Rows = append(slice.Rows, bottom...)
+	first := start - len(top)
+	for turn := 0; turn < turns; turn++ {
+		edges := Edges{West: make([]uint64, len(slice.Rows)), East: make([]uint64, len(slice.Rows))}
+		if topology == Torus {
+			//the cells past the west end of a row are the ones at its east end, and the other way round
+			edges.East, edges.West = slice.EdgeCells(depth)
+		}
+		slice = slice.Step(rule, edges)
+		first += depth
+		if topology == DeadBorder {
+			for y := range slice.Rows {
+				if first+y < 0 || first+y >= height {
+					for w := range slice.Rows[y] {
+						slice.Rows[y][w] = 0
+					}
+				}
+			}
+		}
+	}
+	return slice
+}
+
+// EdgeCells returns the depth cells in from the west and east ends of each row, with bit i holding the cell i cells
+// in from the end, which along with the rows at the top and bottom of a band is all that the bands around it need.
+func (b Bitboard) EdgeCells(depth int) ([]uint64, []uint64) {
+	west := make([]uint64, len(b.Rows))
+	east := make([]uint64, len(b.Rows))
+	for y := range b.Rows {
+		for i := 0; i < depth && i < b.Width; i++ {
+			if b.Alive(i, y) {
+				west[y] |= 1 << uint(i)
+			}
+			if b.Alive(b.Width-1-i, y) {
+				east[y] |= 1 << uint(i)
+			}
+		}
+	}
+	return west, east
+}
+
+// SetBandEdges copies the rows at the top and bottom of the band of rows from start, and the depth cells in from the
+// ends of each of its rows as returned by EdgeCells, into the world, leaving the rest of the band as it was.
+// The edges of every band are enough of a world for Slice to give each band its halos.
+func (b Bitboard) SetBandEdges(start int, top, bottom [][]uint64, west, east []uint64, depth int) {
+	end := start + len(west)
+	for i := range top {
+		copy(b.Rows[start+i], top[i])
+	}
+	for i := range bottom {
+		copy(b.Rows[end-len(bottom)+i], bottom[i])
+	}
+	for y := start; y < end; y++ {
+		for i := 0; i < depth && i < b.Width; i++ {
+			b.Set(i, y, west[y-start]&(1<<uint(i)) != 0)
+			b.Set(b.Width-1-i, y, east[y-start]&(1<<uint(i)) != 0)
+		}
+	}
+}
+
+// Stepper works out the next state of bands of rows of a whole world whose edges are joined by a topology.
+// It keeps its own halo rows for the edges of the world so that it doesn't allocate,
+// which means each goroutine needs its own Stepper.
+type Stepper struct {
+	kernel   kernel
+	topology Topology
+	halos    [][]uint64
+	rows     [][]uint64
+	west     []uint64
+	east     []uint64
+}
+
+// NewStepper returns a Stepper for worlds of the given width.
+func NewStepper(rule Rule, topology Topology, width int) *Stepper {
+	words := (width + wordSize - 1) / wordSize
+	around := 2*rule.Range + 1
+	s := &Stepper{
+		kernel:   newKernel(rule, width),
+		topology: topology,
+		halos:    make([][]uint64, around),
+		rows:     make([][]uint64, around),
+		west:     make([]uint64, around),
+		east:     make([]uint64, around),
+	}
+	for i := range s.halos {
+		s.halos[i] = make([]uint64, words)
+	}
+	return s
+}
+
+// StepRows works out the next state of rows start to end-1 of the world into the same rows of next,
+// which must be the same size. Nothing is allocated, so workers can call it every turn on their own band of a shared world.
+func (s *Stepper) StepRows(world, next Bitboard, start, end int) {
+	depth := s.kernel.rule.Range
+	for y := start; y < end; y++ {
+		for i := range s.rows {
+			//any of the rows other than the middle one can be past an edge, so they each have their own halo
+			s.rows[i] = s.topology.row(world, y+i-depth, s.halos[i])
+			s.west[i], s.east[i] = s.topology.edgeCells(world, y+i-depth, depth)
+		}
+		var decay, nextDecay []uint8
+		if world.Decay != nil {
+			decay, nextDecay = world.Decay[y], next.Decay[y]
+		}
+		s.kernel.stepRow(next.Rows[y], nextDecay, s.rows, decay, s.west, s.east, world.Width)
+	}
+}
+
+// kernel works out the next state of rows of cells under a rule.
+// Rules with a range of 1 that don't count the middle cell are worked out a word at a time from the eight cells
+// around each cell, masked to the neighbourhood. Larger neighbourhoods count each cell from running totals
+// of the rows around it, kept in totals so that they don't need allocating.
+type kernel struct {
+	rule   Rule
+	decay  *decayTable
+	masks  [8]uint64
+	totals [][]int
+}
+
+func newKernel(rule Rule, width int) kernel {
+	k := kernel{rule: rule, decay: newDecayTable(rule), masks: rule.Neighbourhood.masks()}
+	if rule.Range > 1 || rule.Middle {
+		k.totals = make([][]int, 2*rule.Range+1)
+		for i := range k.totals {
+			k.totals[i] = make([]int, width+2*rule.Range+1)
+		}
+	}
+	return k
+}
+
+// stepRow works out the next state of the middle row of rows from the rows above and below it, a word at a time.
+// west and east hold the cells just past the ends of each of the rows.
+// If the row has decay levels, their next levels are written into nextDecay.
+func (k kernel) stepRow(next []uint64, nextDecay []uint8, rows [][]uint64, decay []uint8, west, east []uint64, width int) {
+	if k.totals != nil {
+		k.sumRows(rows, west, east, width)
+	}
+	depth := len(rows) / 2
+	row := rows[depth]
+	above, below := rows[0], rows[len(rows)-1]
+	westAbove, westRow, westBelow := west[0], west[depth], west[len(west)-1]
+	eastAbove, eastRow, eastBelow := east[0], east[depth], east[len(east)-1]
+	masked := k.rule.Neighbourhood != Moore
+	last := len(row) - 1
+	for w := range row {
+		var cells []uint8
+		var decaying uint64
+		if decay != nil {
+			end := (w + 1) * wordSize
+			if end > width {
+				end = width
+			}
+			cells = decay[w*wordSize : end]
+			for i, grey := range cells {
+				if grey != 0 {
+					decaying |= 1 << uint(i)
+				}
+			}
+		}
+		var result uint64
+		if k.totals != nil {
+			result = k.countWord(row[w], decaying, w, width)
+		} else {
+			neighbours := [8]uint64{
+				westWord(above, w, westAbove), above[w], eastWord(above, w, width, eastAbove),
+				westWord(row, w, westRow), eastWord(row, w, width, eastRow),
+				westWord(below, w, westBelow), below[w], eastWord(below, w, width, eastBelow),
+			}
+			if masked {
+				for i := range neighbours {
+					neighbours[i] &= k.masks[i]
+				}
+			}
+			result = nextWord(row[w], decaying, neighbours, k.rule)
+		}
+		if w == last {
+			result &= lastWordMask(width)
+		}
+		next[w] = result
+		if decay != nil {
+			//cells that were alive and aren't any more start decaying
+			dying := row[w] &^ result
+			for i, grey := range cells {
+				if dying>>uint(i)&1 == 1 {
+					grey = 0xFF
+				}
+				nextDecay[w*wordSize+i] = k.decay[grey]
+			}
+		}
+	}
+}
+
+//fills totals with the running totals of alive cells along each of the rows, starting from the cell range cells
+//past the west end, so that totals[i][x+range+1] - totals[i][x+range] is 1 if the cell at x of row i is alive
+func (k kernel) sumRows(rows [][]uint64, west, east []uint64, width int) {
+	depth := k.rule.Range
+	for i, row := range rows {
+		totals := k.totals[i]
+		for j := 0; j < width+2*depth; j++ {
+			var cell uint64
+			switch x := j - depth; {
+			case x < 0:
+				cell = west[i] >> uint(-1-x) & 1
+			case x >= width:
+				cell = east[i] >> uint(x-width) & 1
+			default:
+				cell = row[x/wordSize] >> uint(x%wordSize) & 1
+			}
+			totals[j+1] = totals[j] + int(cell)
+		}
+	}
+}
+
+//works out the next state of word w of the middle row by counting the neighbours of each of its cells from totals
+func (k kernel) countWord(alive, decaying uint64, w, width int) uint64 {
+	depth := k.rule.Range
+	var result uint64
+	for i := uint(0); i < wordSize && w*wordSize+int(i) < width; i++ {
+		x := w*wordSize + int(i) + depth
+		count := 0
+		for dy, totals := range k.totals {
+			first, last := k.rule.Neighbourhood.window(dy-depth, depth)
+			count += totals[x+last+1] - totals[x+first]
+		}
+		cellAlive := alive>>i&1 == 1
+		if cellAlive && !k.rule.Middle {
+			count--
+		}
+		if cellAlive && k.rule.Survive[count] || !cellAlive && decaying>>i&1 == 0 && k.rule.Birth[count] {
+			result |= 1 << i
+		}
+	}
+	return result
+}
+
+// nextWord works out the next state of a word of cells from the words holding each of their eight neighbours,
+// which are 0 for cells outside the neighbourhood.
+// Decaying cells can't be born. The neighbour count of each cell is kept as four bit planes,
+// so bit i of count0 to count3 holds the binary digits of the count of cell i.
+func nextWord(alive, decaying uint64, neighbours [8]uint64, rule Rule) uint64 {
+	var count0, count1, count2, count3 uint64
+	for _, neighbour := range neighbours {
+		//ripple carry add of one bit to every count in the word
+		carry0 := count0 & neighbour
+		count0 ^= neighbour
+		carry1 := count1 & carry0
+		count1 ^= carry0
+		carry2 := count2 & carry1
+		count2 ^= carry1
+		count3 |= carry2
+	}
+
+	var result uint64
+	for n := range rule.Birth {
+		if !rule.Birth[n] && !rule.Survive[n] {
+			continue
+		}
+		//cells whose count is exactly n
+		equal := ^uint64(0)
+		for bit, plane := range [4]uint64{count0, count1, count2, count3} {
+			if n>>uint(bit)&1 == 1 {
+				equal &= plane
+			} else {
+				equal &^= plane
+			}
+		}
+		if rule.Birth[n] {
+			result |= equal &^ alive &^ decaying
+		}
+		if rule.Survive[n] {
+			result |= equal & alive
+		}
+	}
+	return result
+}
+
+// westWord returns word w of the row shifted so that each cell holds its west neighbour,
+// where bit 0 of westCell is the cell just past the west end of the row.
+func westWord(row []uint64, w int, westCell uint64) uint64 {
+	carry := westCell & 1
+	if w > 0 {
+		carry = row[w-1] >> (wordSize - 1)
+	}
+	return row[w]<<1 | carry
+}
+
+// eastWord returns word w of the row shifted so that each cell holds its east neighbour,
+// where bit 0 of eastCell is the cell just past the east end of the row.
+func eastWord(row []uint64, w, width int, eastCell uint64) uint64 {
+	if w < len(row)-1 {
+		return row[w]>>1 | row[w+1]<<(wordSize-1)
+	}
+	return row[w]>>1 | eastCell&1<<uint(width-1-w*wordSize)
+}
+
+// lastWordMask returns the bits of the last word in a row that hold cells.
+func lastWordMask(width int) uint64 {
+	if width%wordSize == 0 {
+		return ^uint64(0)
+	}
+	return 1<<uint(width%wordSize) - 1
+}
@@ -0,0 +1,106 @@
+package util
+
+// The offset basis and prime of the 64 bit FNV-1a hash, which worlds are hashed with to spot them repeating.
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+//mixes the bytes of a word into an FNV-1a hash, lowest byte first
+func hashWord(hash, word uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		hash ^= word & 0xFF
+		hash *= fnvPrime
+		word >>= 8
+	}
+	return hash
+}
+
+// Hash returns a hash of the size of the bitboard, its alive cells and their decay levels,
+// which is the same for any two bitboards holding the same world.
+func (b Bitboard) Hash() uint64 {
+	return b.RowsHash(0, len(b.Rows))
+}
+
+// RowsHash returns the part of the Hash of a world of the given height that comes from the rows of the bitboard,
+// which are the rows of the world from start onwards. The hashes of bands that cover a world add up to its Hash,
+// so a world split between workers can be hashed without putting it back together.
+func (b Bitboard) RowsHash(start, height int) uint64 {
+	//the rows are added up like the chunks of an unbounded world, each hashed along with where it is
+	var hash uint64
+	size := hashWord(hashWord(fnvOffset, uint64(b.Width)), uint64(height))
+	for y, row := range b.Rows {
+		rowHash := hashWord(size, uint64(start+y))
+		for _, word := range row {
+			rowHash = hashWord(rowHash, word)
+		}
+		if b.Decay != nil {
+			for _, grey := range b.Decay[y] {
+				rowHash = (rowHash ^ uint64(grey)) * fnvPrime
+			}
+		}
+		hash += mixHash(rowHash)
+	}
+	return hash
+}
+
+//scrambles the bits of a hash with the finaliser of MurmurHash3, so that the differences between the hashes of
+//similar rows can't cancel out when they are added up
+func mixHash(hash uint64) uint64 {
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
+
+// Hash returns a hash of the alive cells, which is the same for any two unbounded worlds with the same alive cells.
+// Worlds that have moved are different, so a glider never repeats.
+func (u *Unbounded) Hash() uint64 {
+	//the chunks are in no particular order, so their hashes are mixed and added up like the rows of a bitboard
+	var hash uint64
+	for key, c := range u.chunks {
+		chunkHash := hashWord(hashWord(fnvOffset, uint64(key.X)), uint64(key.Y))
+		for _, word := range c {
+			chunkHash = hashWord(chunkHash, word)
+		}
+		hash += mixHash(chunkHash)
+	}
+	return hash
+}
+
+// CycleDetector spots a world returning to an earlier state from the hashes of the worlds it has been shown,
+// remembering the hashes of the last few worlds only so that long runs use a bounded amount of memory.
+// Cycles with a period longer than the history are missed.
+type CycleDetector struct {
+	turns  map[uint64]int
+	hashes []uint64
+	next   int
+}
+
+// NewCycleDetector returns a detector that remembers the hashes of the last history worlds it is shown.
+func NewCycleDetector(history int) *CycleDetector {
+	return &CycleDetector{turns: make(map[uint64]int, history), hashes: make([]uint64, 0, history)}
+}
+
+// Observe records the hash of the world after the given number of completed turns, which should increase with every
+// call. If the same hash was recorded after an earlier turn it returns that turn, where the cycle starts,
+// and the number of turns since, which is the period of the cycle or a multiple of it if turns were skipped.
+func (d *CycleDetector) Observe(hash uint64, turn int) (start, period int, found bool) {
+	if start, ok := d.turns[hash]; ok {
+		return start, turn - start, true
+	}
+	//the oldest hash is forgotten to make room for this one once the history is full
+	if len(d.hashes) < cap(d.hashes) {
+		d.hashes = append(d.hashes, hash)
+	} else if len(d.hashes) > 0 {
+		delete(d.turns, d.hashes[d.next])
+		d.hashes[d.next] = hash
+		d.next = (d.next + 1) % len(d.hashes)
+	} else {
+		return 0, 0, false
+	}
+	d.turns[hash] = turn
+	return 0, 0, false
+}
@@ -0,0 +1,243 @@
+package util
+
+import (
+	"errors"
+	"strconv"
+)
+
+// maxHashLifeNodes is how many nodes HashLife keeps before it forgets every result and starts again from the current world.
+const maxHashLifeNodes = 1 << 22
+
+// node is a square of 2^level cells. Nodes are hash-consed, so two nodes with the same cells are the same node.
+type node struct {
+	nw, ne, sw, se *node
+	level          uint
+}
+
+// resultKey identifies the centre of a node after 2^turns turns.
+type resultKey struct {
+	node  *node
+	turns uint
+}
+
+// HashLife works out future states of a world on a torus using a quadtree of hash-consed nodes.
+// The result of every node it steps is remembered, so regions that repeat in space or time are only worked out once,
+// which lets periodic worlds jump billions of turns.
+// The width and height must be powers of two, so that the torus can be tiled into a square node.
+type HashLife struct {
+	width, height int
+	rule          Rule
+	//the world tiled into a square node of 2^level cells
+	root    *node
+	level   uint
+	dead    *node
+	alive   *node
+	empty   []*node
+	nodes   map[[4]*node]*node
+	results map[resultKey]*node
+}
+
+// NewHashLife returns the HashLife engine for the world.
+func NewHashLife(world Bitboard, rule Rule) (*HashLife, error) {
+	if err := CheckHashLifeSize(world.Width, world.Height()); err != nil {
+		return nil, err
+	}
+	if rule.States > 2 {
+		return nil, errors.New("hashlife can't run generations rules")
+	}
+	if rule.Range > 1 {
+		return nil, errors.New("hashlife can't run rules with a range larger than 1")
+	}
+	h := &HashLife{width: world.Width, height: world.Height(), rule: rule}
+	//the smallest node that can be stepped has 4x4 cells
+	h.level = 2
+	for 1<<h.level < h.width || 1<<h.level < h.height {
+		h.level++
+	}
+	h.load(world)
+	return h, nil
+}
+
+// CheckHashLifeSize returns an error if HashLife can't run a world of the given size.
+func CheckHashLifeSize(width, height int) error {
+	if width <= 0 || height <= 0 || width&(width-1) != 0 || height&(height-1) != 0 {
+		return errors.New("hashlife needs the width and height to be powers of two, not " +
+			strconv.Itoa(width) + "x" + strconv.Itoa(height))
+	}
+	return nil
+}
+
+// Step advances the world by the given number of turns, in jumps of powers of two.
+func (h *HashLife) Step(turns int) {
+	for jump := uint(0); turns > 0; jump++ {
+		if turns&1 == 1 {
+			h.advance(jump)
+			if len(h.nodes) > maxHashLifeNodes {
+				h.load(h.World())
+			}
+		}
+		turns >>= 1
+	}
+}
+
+// World returns the current state of the world.
+func (h *HashLife) World() Bitboard {
+	world := NewBitboard(h.width, h.height)
+	h.unpack(world, h.root, 0, 0)
+	return world
+}
+
+//forgets every node and result, then builds the tiled world from scratch
+func (h *HashLife) load(world Bitboard) {
+	h.nodes = make(map[[4]*node]*node)
+	h.results = make(map[resultKey]*node)
+	h.dead = &node{}
+	h.alive = &node{}
+	h.empty = []*node{h.dead}
+	h.root = h.pack(world, 0, 0, h.level)
+}
+
+//builds the node of the given level with its top left cell at x, y, repeating the world in both directions
+func (h *HashLife) pack(world Bitboard, x, y int, level uint) *node {
+	if level == 0 {
+		if world.Alive(x%h.width, y%h.height) {
+			return h.alive
+		}
+		return h.dead
+	}
+	half := 1 << (level - 1)
+	return h.join(
+		h.pack(world, x, y, level-1), h.pack(world, x+half, y, level-1),
+		h.pack(world, x, y+half, level-1), h.pack(world, x+half, y+half, level-1))
+}
+
+//sets the alive cells of the node with its top left cell at x, y that are inside the world
+func (h *HashLife) unpack(world Bitboard, n *node, x, y int) {
+	if x >= h.width || y >= h.height || n == h.emptyNode(n.level) {
+		return
+	}
+	if n.level == 0 {
+		world.Set(x, y, true)
+		return
+	}
+	half := 1 << (n.level - 1)
+	h.unpack(world, n.nw, x, y)
+	h.unpack(world, n.ne, x+half, y)
+	h.unpack(world, n.sw, x, y+half)
+	h.unpack(world, n.se, x+half, y+half)
+}
+
+//returns the node with the given quadrants, which must all be the same level
+func (h *HashLife) join(nw, ne, sw, se *node) *node {
+	key := [4]*node{nw, ne, sw, se}
+	if n, ok := h.nodes[key]; ok {
+		return n
+	}
+	n := &node{nw: nw, ne: ne, sw: sw, se: se, level: nw.level + 1}
+	h.nodes[key] = n
+	return n
+}
+
+func (h *HashLife) emptyNode(level uint) *node {
+	for uint(len(h.empty)) <= level {
+		e := h.empty[len(h.empty)-1]
+		h.empty = append(h.empty, h.join(e, e, e, e))
+	}
+	return h.empty[level]
+}
+
+func (h *HashLife) centre(n *node) *node {
+	return h.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+//advances the world by 2^turns turns
+//Tiling the world into a node twice the size or more means the centre of its result holds the whole world again,
+//shifted by half the world if it is only twice the size, which swapping the quadrants undoes.
+func (h *HashLife) advance(turns uint) {
+	level := h.level + 1
+	if turns+2 > level {
+		level = turns + 2
+	}
+	n := h.root
+	for n.level < level {
+		n = h.join(n, n, n, n)
+	}
+	n = h.result(n, turns)
+	for n.level > h.level {
+		n = n.nw
+	}
+	if level == h.level+1 {
+		n = h.join(n.se, n.sw, n.ne, n.nw)
+	}
+	h.root = n
+}
+
+//returns the centre half of the node after 2^turns turns, where turns is at most the node's level - 2
+func (h *HashLife) result(n *node, turns uint) *node {
+	key := resultKey{n, turns}
+	if r, ok := h.results[key]; ok {
+		return r
+	}
+	var r *node
+	if n.level == 2 {
+		r = h.stepSmallest(n)
+	} else {
+		//nine overlapping nodes half the size, covering the node
+		n00, n01, n02 := n.nw, h.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), n.ne
+		n10 := h.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne)
+		n11 := h.centre(n)
+		n12 := h.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne)
+		n20, n21, n22 := n.sw, h.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), n.se
+		inner := [9]*node{n00, n01, n02, n10, n11, n12, n20, n21, n22}
+		next := turns
+		if turns == n.level-2 {
+			//the full jump is made in two halves
+			next = turns - 1
+			for i := range inner {
+				inner[i] = h.result(inner[i], next)
+			}
+		} else {
+			for i := range inner {
+				inner[i] = h.centre(inner[i])
+			}
+		}
+		r = h.join(
+			h.result(h.join(inner[0], inner[1], inner[3], inner[4]), next),
+			h.result(h.join(inner[1], inner[2], inner[4], inner[5]), next),
+			h.result(h.join(inner[3], inner[4], inner[6], inner[7]), next),
+			h.result(h.join(inner[4], inner[5], inner[7], inner[8]), next))
+	}
+	h.results[key] = r
+	return r
+}
+
+//works out the centre 2x2 cells of a 4x4 node after a turn
+func (h *HashLife) stepSmallest(n *node) *node {
+	var cells [4][4]bool
+	for y, row := range [2][2]*node{{n.nw, n.ne}, {n.sw, n.se}} {
+		for x, quadrant := range row {
+			cells[2*y][2*x] = quadrant.nw == h.alive
+			cells[2*y][2*x+1] = quadrant.ne == h.alive
+			cells[2*y+1][2*x] = quadrant.sw == h.alive
+			cells[2*y+1][2*x+1] = quadrant.se == h.alive
+		}
+	}
+	var next [4]*node
+	for i := range next {
+		x, y := 1+i%2, 1+i/2
+		neighbours := 0
+		for dy := -1; dy <= 1; dy++ {
+			first, last := h.rule.Neighbourhood.window(dy, 1)
+			for dx := first; dx <= last; dx++ {
+				if (dx != 0 || dy != 0 || h.rule.Middle) && cells[y+dy][x+dx] {
+					neighbours++
+				}
+			}
+		}
+		next[i] = h.dead
+		if h.rule.Next(cells[y][x], neighbours) {
+			next[i] = h.alive
+		}
+	}
+	return h.join(next[0], next[1], next[2], next[3])
+}
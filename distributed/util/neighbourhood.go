@@ -0,0 +1,92 @@
+package util
+
+import "errors"
+
+// Neighbourhood is the shape of the cells around a cell that count as its neighbours.
+// Each shape can be grown to a larger range for Larger than Life rules.
+type Neighbourhood int
+
+const (
+	// Moore is every cell within the range across and down, the eight cells around a cell at range 1.
+	Moore Neighbourhood = iota
+	// VonNeumann is every cell within the range counting steps across and down, the four orthogonal cells at range 1.
+	VonNeumann
+	// Hexagonal is a hexagonal grid with each row shifted half a cell from the one above, stored as squares
+	// by leaving out the cells to the north east and south west, so a cell has six neighbours at range 1.
+	Hexagonal
+)
+
+// maxRange is the largest range a neighbourhood can have, so that the cells past either end of a row fit in a word.
+const maxRange = wordSize
+
+var neighbourhoodNames = []string{"moore", "vonneumann", "hex"}
+
+// ParseNeighbourhood parses the name of a neighbourhood: moore, vonneumann or hex.
+// An empty name is the Moore neighbourhood, which Life has always used.
+func ParseNeighbourhood(name string) (Neighbourhood, error) {
+	if name == "" {
+		return Moore, nil
+	}
+	for i, neighbourhoodName := range neighbourhoodNames {
+		if name == neighbourhoodName {
+			return Neighbourhood(i), nil
+		}
+	}
+	return Moore, errors.New("unknown neighbourhood " + name + ", expected moore, vonneumann or hex")
+}
+
+func (n Neighbourhood) String() string {
+	return neighbourhoodNames[n]
+}
+
+//the first and last columns relative to a cell that are in its neighbourhood on the row dy rows below it,
+//which always include the cell itself when dy is 0
+func (n Neighbourhood) window(dy, radius int) (int, int) {
+	switch n {
+	case VonNeumann:
+		if dy < 0 {
+			dy = -dy
+		}
+		return dy - radius, radius - dy
+	case Hexagonal:
+		first, last := -radius, radius
+		if dy-radius > first {
+			first = dy - radius
+		}
+		if dy+radius < last {
+			last = dy + radius
+		}
+		return first, last
+	}
+	return -radius, radius
+}
+
+//the number of cells in the neighbourhood, not counting the cell itself
+func (n Neighbourhood) size(radius int) int {
+	size := 0
+	for dy := -radius; dy <= radius; dy++ {
+		first, last := n.window(dy, radius)
+		size += last - first + 1
+	}
+	return size - 1
+}
+
+//the words of the eight cells around a cell at range 1 that are in the neighbourhood, in the order stepRow counts them:
+//north west, north, north east, west, east, south west, south and south east, as all ones or all zeros
+func (n Neighbourhood) masks() [8]uint64 {
+	var masks [8]uint64
+	i := 0
+	for dy := -1; dy <= 1; dy++ {
+		first, last := n.window(dy, 1)
+		for dx := -1; dx <= 1; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			if dx >= first && dx <= last {
+				masks[i] = ^uint64(0)
+			}
+			i++
+		}
+	}
+	return masks
+}
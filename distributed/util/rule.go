@@ -0,0 +1,329 @@
+package util
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Rule is an outer-totalistic Life-like rule, or a Generations rule if it has more than two states.
+// Birth[n] is true if a dead cell with n alive neighbours becomes alive and
+// Survive[n] is true if an alive cell with n alive neighbours stays alive.
+// States is the number of states a cell can be in: 0 is dead, 1 is alive and, under a Generations rule,
+// alive cells that don't survive go through the decay states 2 to States-1 before they are dead.
+// Only alive cells count as neighbours, and decaying cells can't be born.
+// The neighbours of a cell are the cells of Neighbourhood within Range of it, which is at least 1,
+// and Middle counts the cell itself as one of its neighbours, as Larger than Life rules can.
+type Rule struct {
+	Birth         []bool
+	Survive       []bool
+	States        int
+	Neighbourhood Neighbourhood
+	Range         int
+	Middle        bool
+}
+
+// ConwayRule is the rule used by Conway's Game of Life.
+const ConwayRule = "B3/S23"
+
+//maximum number of states, so that every state has its own grey level
+const maxStates = 256
+
+//suffixes of rules in B/S notation that use a neighbourhood other than Moore's
+var neighbourhoodSuffixes = map[byte]Neighbourhood{'V': VonNeumann, 'v': VonNeumann, 'H': Hexagonal, 'h': Hexagonal}
+
+// ParseRule parses a rule written in B/S notation (e.g. "B36/S23" or "B2/S").
+// The older S/B notation (e.g. "23/36") is also accepted.
+// Generations rules add the number of states as a third part (e.g. "B2/S/C3" or "345/2/4").
+// A V or H at the end uses the von Neumann or hexagonal neighbourhood (e.g. "B2/S34H").
+// Larger than Life rules are written as in Golly (e.g. "R5,C0,M1,S33..57,B34..45,NM" for Bosco's Rule).
+func ParseRule(s string) (Rule, error) {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) > 1 && (trimmed[0] == 'R' || trimmed[0] == 'r') && trimmed[1] >= '0' && trimmed[1] <= '9' {
+		return parseLargerThanLife(s)
+	}
+	rule := Rule{States: 2, Range: 1}
+	if len(trimmed) > 0 {
+		if neighbourhood, ok := neighbourhoodSuffixes[trimmed[len(trimmed)-1]]; ok {
+			rule.Neighbourhood = neighbourhood
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+	}
+	neighbours := rule.Neighbourhood.size(1)
+	rule.Birth, rule.Survive = make([]bool, neighbours+1), make([]bool, neighbours+1)
+	parts := strings.Split(trimmed, "/")
+	if len(parts) == 3 {
+		states := strings.TrimPrefix(strings.TrimPrefix(parts[2], "C"), "c")
+		var err error
+		rule.States, err = strconv.Atoi(states)
+		if err != nil || rule.States < 2 || rule.States > maxStates {
+			return rule, errors.New("rule " + s + " needs between 2 and " + strconv.Itoa(maxStates) + " states")
+		}
+		parts = parts[:2]
+	}
+	if len(parts) != 2 {
+		return rule, errors.New("rule " + s + " is not in B/S notation")
+	}
+	sawBirth := false
+	sawSurvive := false
+	for i, part := range parts {
+		var counts []bool
+		switch {
+		case len(part) > 0 && (part[0] == 'B' || part[0] == 'b'):
+			counts = rule.Birth
+			sawBirth = true
+			part = part[1:]
+		case len(part) > 0 && (part[0] == 'S' || part[0] == 's'):
+			counts = rule.Survive
+			sawSurvive = true
+			part = part[1:]
+		case i == 0:
+			//S/B notation lists survival counts first
+			counts = rule.Survive
+			sawSurvive = true
+		default:
+			counts = rule.Birth
+			sawBirth = true
+		}
+		for _, digit := range part {
+			if digit < '0' || digit > '0'+rune(neighbours) {
+				return rule, errors.New("rule " + s + " has an invalid neighbour count " + string(digit))
+			}
+			counts[digit-'0'] = true
+		}
+	}
+	if !sawBirth || !sawSurvive {
+		return rule, errors.New("rule " + s + " needs both a birth and a survival part")
+	}
+	return rule, nil
+}
+
+//parses a Larger than Life rule, a comma separated list of the range (R), states (C, where 0 also means 2),
+//whether the middle cell counts (M), the survival (S) and birth (B) counts and the neighbourhood (N),
+//which is M for Moore, N for von Neumann or H for hexagonal.
+//Counts are ranges like 33..57 or single counts, and several can follow an S or B separated by commas.
+func parseLargerThanLife(s string) (Rule, error) {
+	rule := Rule{States: 2}
+	var lists [2][]string
+	list := -1
+	for _, item := range strings.Split(strings.TrimSpace(s), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return rule, errors.New("rule " + s + " has an empty part")
+		}
+		value := item[1:]
+		previous := list
+		list = -1
+		var err error
+		switch item[0] {
+		case 'R', 'r':
+			rule.Range, err = strconv.Atoi(value)
+			if err != nil || rule.Range < 1 || rule.Range > maxRange {
+				return rule, errors.New("rule " + s + " needs a range between 1 and " + strconv.Itoa(maxRange))
+			}
+		case 'C', 'c':
+			rule.States, err = strconv.Atoi(value)
+			if rule.States == 0 {
+				rule.States = 2
+			}
+			if err != nil || rule.States < 2 || rule.States > maxStates {
+				return rule, errors.New("rule " + s + " needs between 2 and " + strconv.Itoa(maxStates) + " states")
+			}
+		case 'M', 'm':
+			if value != "0" && value != "1" {
+				return rule, errors.New("rule " + s + " needs M0 or M1")
+			}
+			rule.Middle = value == "1"
+		case 'N', 'n':
+			switch value {
+			case "M", "m":
+				rule.Neighbourhood = Moore
+			case "N", "n":
+				rule.Neighbourhood = VonNeumann
+			case "H", "h":
+				rule.Neighbourhood = Hexagonal
+			default:
+				return rule, errors.New("rule " + s + " has an unknown neighbourhood N" + value)
+			}
+		case 'S', 's':
+			list = 0
+			lists[list] = append(lists[list], value)
+		case 'B', 'b':
+			list = 1
+			lists[list] = append(lists[list], value)
+		default:
+			//more counts for the list before
+			if previous < 0 || item[0] < '0' || item[0] > '9' {
+				return rule, errors.New("rule " + s + " has an unknown part " + item)
+			}
+			list = previous
+			lists[list] = append(lists[list], item)
+		}
+	}
+	if rule.Range == 0 {
+		return rule, errors.New("rule " + s + " needs a range")
+	}
+	if lists[0] == nil || lists[1] == nil {
+		return rule, errors.New("rule " + s + " needs both a birth and a survival part")
+	}
+	neighbours := rule.Neighbourhood.size(rule.Range)
+	if rule.Middle {
+		neighbours++
+	}
+	rule.Survive, rule.Birth = make([]bool, neighbours+1), make([]bool, neighbours+1)
+	for i, counts := range [2][]bool{rule.Survive, rule.Birth} {
+		for _, countRange := range lists[i] {
+			if countRange == "" {
+				continue
+			}
+			bounds := strings.SplitN(strings.Replace(countRange, "..", "-", 1), "-", 2)
+			first, err := strconv.Atoi(bounds[0])
+			last := first
+			if err == nil && len(bounds) == 2 {
+				last, err = strconv.Atoi(bounds[1])
+			}
+			if err != nil || first < 0 || last > neighbours || first > last {
+				return rule, errors.New("rule " + s + " has an invalid neighbour count " + countRange)
+			}
+			for n := first; n <= last; n++ {
+				counts[n] = true
+			}
+		}
+	}
+	return rule, nil
+}
+
+// WithNeighbourhood returns the rule with the given neighbourhood and range, keeping its birth and survival counts.
+// It returns an error if any of the counts are more than the cells in the new neighbourhood.
+func (r Rule) WithNeighbourhood(neighbourhood Neighbourhood, radius int) (Rule, error) {
+	if radius < 1 || radius > maxRange {
+		return r, errors.New("the range needs to be between 1 and " + strconv.Itoa(maxRange))
+	}
+	neighbours := neighbourhood.size(radius)
+	if r.Middle {
+		neighbours++
+	}
+	birth, survive := make([]bool, neighbours+1), make([]bool, neighbours+1)
+	for n := range r.Birth {
+		if (r.Birth[n] || r.Survive[n]) && n > neighbours {
+			return r, errors.New("rule " + r.String() + " counts more neighbours than the " +
+				neighbourhood.String() + " neighbourhood of range " + strconv.Itoa(radius) + " has")
+		}
+	}
+	copy(birth, r.Birth)
+	copy(survive, r.Survive)
+	r.Birth, r.Survive, r.Neighbourhood, r.Range = birth, survive, neighbourhood, radius
+	return r, nil
+}
+
+// String returns the rule in B/S notation, or in Golly's Larger than Life notation if it has a larger range
+// or counts the middle cell.
+func (r Rule) String() string {
+	if r.Range > 1 || r.Middle {
+		return r.largerThanLifeString()
+	}
+	var builder strings.Builder
+	builder.WriteString("B")
+	for n, born := range r.Birth {
+		if born {
+			builder.WriteByte(byte('0' + n))
+		}
+	}
+	builder.WriteString("/S")
+	for n, survives := range r.Survive {
+		if survives {
+			builder.WriteByte(byte('0' + n))
+		}
+	}
+	if r.States > 2 {
+		builder.WriteString("/C" + strconv.Itoa(r.States))
+	}
+	switch r.Neighbourhood {
+	case VonNeumann:
+		builder.WriteString("V")
+	case Hexagonal:
+		builder.WriteString("H")
+	}
+	return builder.String()
+}
+
+func (r Rule) largerThanLifeString() string {
+	states := r.States
+	if states == 2 {
+		states = 0
+	}
+	middle := "0"
+	if r.Middle {
+		middle = "1"
+	}
+	parts := []string{"R" + strconv.Itoa(r.Range), "C" + strconv.Itoa(states), "M" + middle,
+		"S" + countRanges(r.Survive), "B" + countRanges(r.Birth), "N" + "MNH"[r.Neighbourhood:r.Neighbourhood+1]}
+	return strings.Join(parts, ",")
+}
+
+//the true counts as comma separated ranges, like 33..57
+func countRanges(counts []bool) string {
+	var ranges []string
+	for n := 0; n < len(counts); n++ {
+		if !counts[n] {
+			continue
+		}
+		first := n
+		for n+1 < len(counts) && counts[n+1] {
+			n++
+		}
+		ranges = append(ranges, strconv.Itoa(first)+".."+strconv.Itoa(n))
+	}
+	return strings.Join(ranges, ",")
+}
+
+// Next returns whether a cell is alive in the next turn given its current state and number of alive neighbours.
+func (r Rule) Next(alive bool, neighbours int) bool {
+	if alive {
+		return r.Survive[neighbours]
+	}
+	return r.Birth[neighbours]
+}
+
+// Grey returns the grey level a cell in the given state is stored as: 0x00 when dead, 0xFF when alive,
+// and evenly spaced levels in between that get darker as the cell decays.
+func (r Rule) Grey(state int) uint8 {
+	if state == 0 {
+		return 0x00
+	}
+	return uint8(0xFF - (state-1)*0xFF/(r.States-1))
+}
+
+// State returns the state whose grey level is closest to the given one.
+// Under a rule with only two states every grey level other than 0xFF is dead.
+func (r Rule) State(grey uint8) int {
+	if grey == 0xFF {
+		return 1
+	}
+	state, distance := 0, int(grey)
+	for decay := 2; decay < r.States; decay++ {
+		d := int(grey) - int(r.Grey(decay))
+		if d < 0 {
+			d = -d
+		}
+		if d < distance {
+			state, distance = decay, d
+		}
+	}
+	return state
+}
+
+//the grey level of each cell next turn for every grey level it can have now, if it doesn't stay alive or get born
+//0xFF is an alive cell that has just died, which starts decaying
+type decayTable [256]uint8
+
+func newDecayTable(rule Rule) *decayTable {
+	var table decayTable
+	for grey := range table {
+		state := rule.State(uint8(grey))
+		if state > 0 && state+1 < rule.States {
+			table[grey] = rule.Grey(state + 1)
+		}
+	}
+	return &table
+}
@@ -0,0 +1,91 @@
+package util
+
+import "math/bits"
+
+// Stats are the statistics of a world after a turn, compared with the world a turn before it.
+// TopLeft and BottomRight are the corners of the smallest rectangle holding every alive cell, and are both 0, 0
+// if there are no alive cells. SliceDensity is the fraction of the cells that are alive in each band of rows
+// the world is split into, with the first bands taking a row each of whatever doesn't split evenly,
+// the same as the bands worked on by each thread or worker.
+type Stats struct {
+	CompletedTurns int
+	Alive          int
+	Births         int
+	Deaths         int
+	TopLeft        Cell
+	BottomRight    Cell
+	SliceDensity   []float64
+}
+
+// NewStats works out the statistics of a world from the world before it, which must be the same size,
+// splitting it into the given number of slices.
+func NewStats(previous, current Bitboard, slices int) Stats {
+	var stats Stats
+	for y, row := range current.Rows {
+		for w, word := range row {
+			before := previous.Rows[y][w]
+			stats.Births += bits.OnesCount64(word &^ before)
+			stats.Deaths += bits.OnesCount64(before &^ word)
+			if word == 0 {
+				continue
+			}
+			first, last := w*wordSize+bits.TrailingZeros64(word), w*wordSize+wordSize-1-bits.LeadingZeros64(word)
+			if stats.Alive == 0 {
+				stats.TopLeft, stats.BottomRight = Cell{X: first, Y: y}, Cell{X: last, Y: y}
+			}
+			if first < stats.TopLeft.X {
+				stats.TopLeft.X = first
+			}
+			if last > stats.BottomRight.X {
+				stats.BottomRight.X = last
+			}
+			stats.BottomRight.Y = y
+			stats.Alive += bits.OnesCount64(word)
+		}
+	}
+	stats.SliceDensity = sliceDensity(current, slices)
+	return stats
+}
+
+// NewUnboundedStats works out the statistics of an unbounded world from the world before it,
+// with the slices taken from the window of it written to images.
+func NewUnboundedStats(previous, current *Unbounded, window Bitboard, slices int) Stats {
+	stats := Stats{Alive: current.AliveCount()}
+	previous.FlippedCells(current, func(cell Cell) {
+		if current.Alive(cell.X, cell.Y) {
+			stats.Births++
+		} else {
+			stats.Deaths++
+		}
+	})
+	stats.TopLeft, stats.BottomRight, _ = current.Bounds()
+	stats.SliceDensity = sliceDensity(window, slices)
+	return stats
+}
+
+//the fraction of alive cells in each band of rows, which is 0 for bands without any rows
+func sliceDensity(world Bitboard, slices int) []float64 {
+	if slices < 1 {
+		slices = 1
+	}
+	density := make([]float64, slices)
+	rowsPerSlice, remainder := world.Height()/slices, world.Height()%slices
+	start := 0
+	for i := range density {
+		end := start + rowsPerSlice
+		if i < remainder {
+			end++
+		}
+		if end > start && world.Width > 0 {
+			alive := 0
+			for _, row := range world.Rows[start:end] {
+				for _, word := range row {
+					alive += bits.OnesCount64(word)
+				}
+			}
+			density[i] = float64(alive) / float64((end-start)*world.Width)
+		}
+		start = end
+	}
+	return density
+}
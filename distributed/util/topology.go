@@ -0,0 +1,189 @@
+package util
+
+import "errors"
+
+// Topology says how the edges of a world are joined, which decides the neighbours of the cells along them.
+type Topology int
+
+const (
+	// Torus joins each edge to the opposite one.
+	Torus Topology = iota
+	// DeadBorder treats every cell past the edges as dead.
+	DeadBorder
+	// Reflecting mirrors the world in each edge, so the cell just past an edge is the same as the one just inside it.
+	Reflecting
+	// KleinBottle joins the left and right edges like a torus, but joins the top and bottom edges with a twist,
+	// so a pattern leaving through the bottom comes back through the top mirrored left to right.
+	KleinBottle
+	// ProjectivePlane joins both pairs of opposite edges with a twist,
+	// so a pattern leaving through the left or right also comes back mirrored top to bottom.
+	ProjectivePlane
+)
+
+var topologyNames = []string{"torus", "dead", "reflect", "klein", "projective"}
+
+// ParseTopology parses the name of a topology: torus, dead, reflect, klein or projective.
+// An empty name is a torus, which is how the world has always wrapped.
+func ParseTopology(name string) (Topology, error) {
+	if name == "" {
+		return Torus, nil
+	}
+	for i, topologyName := range topologyNames {
+		if name == topologyName {
+			return Topology(i), nil
+		}
+	}
+	return Torus, errors.New("unknown topology " + name + ", expected torus, dead, reflect, klein or projective")
+}
+
+func (t Topology) String() string {
+	return topologyNames[t]
+}
+
+//the cell of a world of the given size that the cell at x, y stands for, where x and y can be past an edge,
+//or false if it is past a dead border
+func (t Topology) mapCell(x, y, width, height int) (int, int, bool) {
+	switch t {
+	case DeadBorder:
+		return x, y, x >= 0 && x < width && y >= 0 && y < height
+	case Reflecting:
+		return reflect(x, width), reflect(y, height), true
+	case KleinBottle, ProjectivePlane:
+		//cells more than a world past an edge go through it more than once, twisting each time
+		for y < 0 || y >= height {
+			x = width - 1 - x
+			if y < 0 {
+				y += height
+			} else {
+				y -= height
+			}
+		}
+		for t == ProjectivePlane && (x < 0 || x >= width) {
+			y = height - 1 - y
+			if x < 0 {
+				x += width
+			} else {
+				x -= width
+			}
+		}
+	}
+	return wrap(x, width), wrap(y, height), true
+}
+
+func wrap(i, size int) int {
+	return (i%size + size) % size
+}
+
+func reflect(i, size int) int {
+	for i < 0 || i >= size {
+		if i < 0 {
+			i = -1 - i
+		} else {
+			i = 2*size - 1 - i
+		}
+	}
+	return i
+}
+
+//row y of the world, where y can be past the top or bottom edge
+//rows past an edge are written into halo unless they are the same as a row of the world
+func (t Topology) row(world Bitboard, y int, halo []uint64) []uint64 {
+	height := world.Height()
+	if y >= 0 && y < height {
+		return world.Rows[y]
+	}
+	switch t {
+	case Torus, Reflecting:
+		//the column of each cell is unchanged, so the row past the edge is a whole row of the world
+		_, mapped, _ := t.mapCell(0, y, world.Width, height)
+		return world.Rows[mapped]
+	}
+	for w := range halo {
+		halo[w] = 0
+	}
+	for x := 0; x < world.Width; x++ {
+		if mappedX, mappedY, ok := t.mapCell(x, y, world.Width, height); ok && world.Alive(mappedX, mappedY) {
+			halo[x/wordSize] |= 1 << uint(x%wordSize)
+		}
+	}
+	return halo
+}
+
+//the depth cells just past the west and east ends of row y, where y can also be past an edge,
+//with bit i holding the cell i+1 cells past the end
+func (t Topology) edgeCells(world Bitboard, y, depth int) (uint64, uint64) {
+	var west, east uint64
+	for i := 0; i < depth; i++ {
+		west |= t.cell(world, -1-i, y) << uint(i)
+		east |= t.cell(world, world.Width+i, y) << uint(i)
+	}
+	return west, east
+}
+
+func (t Topology) cell(world Bitboard, x, y int) uint64 {
+	if mappedX, mappedY, ok := t.mapCell(x, y, world.Width, world.Height()); ok && world.Alive(mappedX, mappedY) {
+		return 1
+	}
+	return 0
+}
+
+// Slice returns rows start to end-1 of the world with depth halo rows either side, along with the depth cells
+// just past the ends of each of its rows, ready for a worker to Step under a rule whose range is depth.
+// The rows inside the world are shared with it, while halo rows past an edge are newly allocated.
+// Halo rows have no decay levels, as decaying cells aren't anyone's neighbours.
+func (t Topology) Slice(world Bitboard, start, end, depth int) (Bitboard, Edges) {
+	slice := Bitboard{Width: world.Width}
+	var edges Edges
+	for y := start - depth; y < end+depth; y++ {
+		var halo []uint64
+		if y < 0 || y >= world.Height() {
+			halo = make([]uint64, (world.Width+wordSize-1)/wordSize)
+		}
+		slice.Rows = append(slice.Rows, t.row(world, y, halo))
+		if world.Decay != nil {
+			var decay []uint8
+			if y >= start && y < end {
+				decay = world.Decay[y]
+			}
+			slice.Decay = append(slice.Decay, decay)
+		}
+		west, east := t.edgeCells(world, y, depth)
+		edges.West = append(edges.West, west)
+		edges.East = append(edges.East, east)
+	}
+	return slice, edges
+}
+
+// CanStepTurns says whether StepBandTurns can run several turns at once on a band of a world with the topology under
+// the rule. The halos have to be worked out along with the band, which needs edges that don't turn the world round,
+// so that the cells past the ends of each row come from the row itself, and a rule without decaying cells,
+// as halo rows don't have decay levels.
+func (t Topology) CanStepTurns(rule Rule) bool {
+	return (t == Torus || t == DeadBorder) && rule.States <= 2
+}
+
+// HaloRows returns the rows of a world of the given size that Slice reads the halos of rows start to end-1 from,
+// in order, so that the halos can be put together from whoever has those rows.
+// They can include rows of the band itself, as the cells past its ends can be in its own rows.
+func (t Topology) HaloRows(width, height, start, end, depth int) []int {
+	read := make([]bool, height)
+	for y := start - depth; y < end+depth; y++ {
+		inBand := y >= start && y < end
+		for x := -depth; x < width+depth; x++ {
+			//only the cells past the ends of the band's own rows are in its halos
+			if inBand && x == 0 {
+				x = width
+			}
+			if _, mappedY, ok := t.mapCell(x, y, width, height); ok {
+				read[mappedY] = true
+			}
+		}
+	}
+	var rows []int
+	for y := range read {
+		if read[y] {
+			rows = append(rows, y)
+		}
+	}
+	return rows
+}
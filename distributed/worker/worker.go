@@ -5,123 +5,116 @@ import (
 	"fmt"
 	"net"
 	"net/rpc"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"uk.ac.bris.cs/gameoflife/band"
 	"uk.ac.bris.cs/gameoflife/stubs"
 )
 
 var listener net.Listener
 
+//How often the worker tells the broker it is alive, unless set with -heartbeat
+const defaultHeartbeatInterval = 2 * time.Second
+
 func main() {
 	myIp := flag.String("ip","localhost","Worker's ip")
 	port := flag.String("port","8050","Port worker will listen on")
 	brokerIp := flag.String("brokerIp","localhost:8040","Address to connect to broker")
+	heartbeatInterval := flag.Duration("heartbeat", defaultHeartbeatInterval,
+		"How often to tell the broker the worker is alive, which should be well within the broker's -timeout")
 	flag.Parse()
-	subscriber, serr := rpc.Dial("tcp",*brokerIp)
-	if serr != nil {
-		fmt.Println("Worker on "+*myIp+":"+*port+" failed to connect to broker on "+*brokerIp+" - "+serr.Error())
-	}
-	subRequest := stubs.SubscriptionRequest{IP: *myIp+":"+*port}
-	subResp := new(stubs.GenericMessage)
-	err := subscriber.Call(stubs.SubscribeWorker, subRequest, subResp)
-	if err != nil {
-		fmt.Println(err)
-	}
-	err = subscriber.Close()
+	err := rpc.Register(&WorkerOperations{})
 	if err != nil {
 		fmt.Println(err)
 	}
-	err = rpc.Register(&WorkerOperations{})
+	//Listening before subscribing means the broker can dial the worker as soon as it joins, even mid-run
+	listener, err = net.Listen("tcp", ":"+*port)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Println("Worker listening error: ", err.Error())
 	}
 
-	listener, err = net.Listen("tcp", ":"+*port)
+	subRequest := stubs.SubscriptionRequest{IP: *myIp+":"+*port}
+	err = callBroker(*brokerIp, stubs.SubscribeWorker, subRequest)
 	if err != nil {
-		fmt.Println("Worker listening error: ", err.Error())
+		//The heartbeats subscribe the worker once the broker can be reached
+		fmt.Println("Worker on "+*myIp+":"+*port+" failed to subscribe to broker on "+*brokerIp+" - "+err.Error())
+	}
+	if *heartbeatInterval <= 0 {
+		*heartbeatInterval = defaultHeartbeatInterval
 	}
+	stopHeartbeats := make(chan bool)
+	go sendHeartbeats(*brokerIp, subRequest, *heartbeatInterval, stopHeartbeats)
+	go unsubscribeOnSignal(*brokerIp, subRequest, stopHeartbeats)
 	rpc.Accept(listener)
 }
 
-type WorkerOperations struct{}
-
-func (w *WorkerOperations) Kill(req stubs.GenericMessage, resp *stubs.GenericMessage) (err error){
-	err = listener.Close()
+//Makes a single call to the broker on a connection of its own
+func callBroker(brokerIp string, method string, req stubs.SubscriptionRequest) error {
+	broker, err := rpc.Dial("tcp", brokerIp)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-	return
+	defer broker.Close()
+	return broker.Call(method, req, new(stubs.GenericMessage))
 }
 
-func (w *WorkerOperations) ProcessSlice(req stubs.Request, resp *stubs.Response) (err error) {
-	fmt.Println("Received")
-
-	currentSlice := req.CurrentWorld
-	//Making new slice to write changes to
-	nextSlice := make([][]byte, len(currentSlice) - 2)
-	for i := range nextSlice{
-		nextSlice[i] = make([]byte, len(currentSlice[0]))
-	}
-	for i := 1;i < len(currentSlice) - 1;i++	{
-		for j := range currentSlice[i]	{
-			if getNumSurroundingCells(i, j, currentSlice) == 3 {
-				nextSlice[i-1][j] = 0xFF
-			}	else if getNumSurroundingCells(i, j, currentSlice) < 2	{
-				nextSlice[i-1][j] = 0
-			}	else if getNumSurroundingCells(i, j, currentSlice) > 3	{
-				nextSlice[i-1][j] = 0
-			}	else if getNumSurroundingCells(i, j, currentSlice) == 2 {
-				nextSlice[i-1][j] = currentSlice[i][j]
+//Tells the broker the worker is still alive until it is stopped, connecting again whenever a heartbeat fails
+//The stop is only taken between heartbeats, so none are still on their way to the broker once it has been sent
+func sendHeartbeats(brokerIp string, req stubs.SubscriptionRequest, interval time.Duration, stop <-chan bool) {
+	var broker *rpc.Client
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			if broker != nil {
+				broker.Close()
+			}
+			return
+		case <-ticker.C:
+		}
+		var err error
+		if broker == nil {
+			broker, err = rpc.Dial("tcp", brokerIp)
+		}
+		if err == nil {
+			err = broker.Call(stubs.Heartbeat, req, new(stubs.GenericMessage))
+		}
+		if err != nil {
+			fmt.Println("Heartbeat to broker on "+brokerIp+" failed - "+err.Error())
+			if broker != nil {
+				broker.Close()
+				broker = nil
 			}
 		}
 	}
-	resp.NextWorld = nextSlice
-	return
 }
 
-//count number of active cells surrounding a current cell
-func getNumSurroundingCells(x int, y int, world [][]byte) int {
-	const ALIVE = 0xFF
-	var counter = 0
-	var succX = x + 1
-	var succY = y + 1
-	var prevX = x - 1
-	var prevY = y - 1
-	succX = boundNumber(succX,len(world))
-	succY = boundNumber(succY,len(world[0]))
-	prevX = boundNumber(prevX,len(world))
-	prevY = boundNumber(prevY,len(world[0]))
-	if world[prevX][y] == ALIVE	{
-		counter++
-	}
-	if world[prevX][prevY] == ALIVE {
-		counter++
-	}
-	if world[prevX][succY] == ALIVE {
-		counter++
-	}
-	if world[x][succY] == ALIVE {
-		counter++
-	}
-	if world[x][prevY] == ALIVE {
-		counter++
-	}
-	if world[succX][y] == ALIVE {
-		counter++
-	}
-	if world[succX][succY] == ALIVE {
-		counter++
-	}
-	if world[succX][prevY] == ALIVE {
-		counter++
+//Unsubscribes from the broker when the worker is interrupted, so the broker stops giving it work straight away
+func unsubscribeOnSignal(brokerIp string, req stubs.SubscriptionRequest, stopHeartbeats chan<- bool) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	//A heartbeat arriving while the broker waits to release the worker would subscribe it again
+	stopHeartbeats <- true
+	if err := callBroker(brokerIp, stubs.UnsubscribeWorker, req); err != nil {
+		fmt.Println(err)
 	}
-	return counter
+	os.Exit(0)
 }
 
-func boundNumber(num int,worldLen int) int{
-	if num < 0 {
-		return num + worldLen
-	}else if num > worldLen - 1 {
-		return num - worldLen
-	}else{
-		return num
+//WorkerOperations serves the band the worker keeps to the broker and to its peers
+type WorkerOperations struct {
+	band.Worker
+}
+
+func (w *WorkerOperations) Kill(req stubs.GenericMessage, resp *stubs.GenericMessage) (err error){
+	err = listener.Close()
+	if err != nil {
+		fmt.Println(err)
 	}
+	return
 }
+
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"sort"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+var glider = []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+
+var replicator = []util.Cell{
+	{X: 8, Y: 6}, {X: 9, Y: 6}, {X: 10, Y: 6}, {X: 7, Y: 7}, {X: 10, Y: 7}, {X: 6, Y: 8},
+	{X: 10, Y: 8}, {X: 6, Y: 9}, {X: 9, Y: 9}, {X: 6, Y: 10}, {X: 7, Y: 10}, {X: 8, Y: 10},
+}
+
+// ruleTests are known patterns for different rules on a torus.
+var ruleTests = []struct {
+	rule          string
+	width, height int
+	turns         int
+	initial       []util.Cell
+	expected      []util.Cell
+}{
+	{
+		rule:  "B3/S23",
+		width: 16, height: 16,
+		turns:    4,
+		initial:  glider,
+		expected: []util.Cell{{X: 2, Y: 1}, {X: 3, Y: 2}, {X: 1, Y: 3}, {X: 2, Y: 3}, {X: 3, Y: 3}},
+	},
+	{
+		rule:  "B36/S23",
+		width: 32, height: 32,
+		turns:   12,
+		initial: replicator,
+		expected: []util.Cell{
+			{X: 6, Y: 4}, {X: 7, Y: 4}, {X: 8, Y: 4}, {X: 5, Y: 5}, {X: 8, Y: 5}, {X: 4, Y: 6},
+			{X: 8, Y: 6}, {X: 4, Y: 7}, {X: 7, Y: 7}, {X: 4, Y: 8}, {X: 5, Y: 8}, {X: 6, Y: 8},
+			{X: 10, Y: 8}, {X: 11, Y: 8}, {X: 12, Y: 8}, {X: 9, Y: 9}, {X: 12, Y: 9}, {X: 8, Y: 10},
+			{X: 12, Y: 10}, {X: 8, Y: 11}, {X: 11, Y: 11}, {X: 8, Y: 12}, {X: 9, Y: 12}, {X: 10, Y: 12},
+		},
+	},
+	{
+		rule:  "B2/S",
+		width: 16, height: 16,
+		turns:    2,
+		initial:  []util.Cell{{X: 4, Y: 4}, {X: 5, Y: 4}},
+		expected: []util.Cell{{X: 4, Y: 2}, {X: 5, Y: 2}, {X: 3, Y: 4}, {X: 6, Y: 4}, {X: 4, Y: 6}, {X: 5, Y: 6}},
+	},
+	{
+		rule:  "B3/S012345678",
+		width: 16, height: 16,
+		turns:    1,
+		initial:  []util.Cell{{X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}},
+		expected: []util.Cell{{X: 5, Y: 3}, {X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}, {X: 5, Y: 5}},
+	},
+	{
+		//only cells with exactly one alive orthogonal neighbour are born, so the diagonals stay dead
+		rule:  "B1/S01234V",
+		width: 16, height: 16,
+		turns:   2,
+		initial: []util.Cell{{X: 5, Y: 5}},
+		expected: []util.Cell{
+			{X: 5, Y: 3}, {X: 5, Y: 4}, {X: 3, Y: 5}, {X: 4, Y: 5}, {X: 5, Y: 5}, {X: 6, Y: 5}, {X: 7, Y: 5},
+			{X: 5, Y: 6}, {X: 5, Y: 7},
+		},
+	},
+	{
+		rule:  "B1/S0123456H",
+		width: 16, height: 16,
+		turns:   1,
+		initial: []util.Cell{{X: 5, Y: 5}},
+		expected: []util.Cell{
+			{X: 4, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}, {X: 5, Y: 5}, {X: 6, Y: 5}, {X: 5, Y: 6}, {X: 6, Y: 6},
+		},
+	},
+	{
+		//the cell counts itself, so it survives with no other neighbours and fills the diamond around it
+		rule:  "R2,C0,M1,S1,B1,NN",
+		width: 16, height: 16,
+		turns:   1,
+		initial: []util.Cell{{X: 5, Y: 5}},
+		expected: []util.Cell{
+			{X: 5, Y: 3}, {X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}, {X: 3, Y: 5}, {X: 4, Y: 5}, {X: 5, Y: 5},
+			{X: 6, Y: 5}, {X: 7, Y: 5}, {X: 4, Y: 6}, {X: 5, Y: 6}, {X: 6, Y: 6}, {X: 5, Y: 7},
+		},
+	},
+}
+
+func makeWorld(width, height int, alive []util.Cell) [][]byte {
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+	}
+	for _, cell := range alive {
+		world[cell.Y][cell.X] = 0xFF
+	}
+	return world
+}
+
+func sortedAliveCells(world [][]byte) []util.Cell {
+	var cells []util.Cell
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 0xFF {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
+// TestGenerations runs a Brian's Brain (B2/S/C3) spaceship split between 1, 2 and 4 bands,
+// checking that the decaying cells behind it are kept as grey levels.
+func TestGenerations(t *testing.T) {
+	var addresses []string
+	for i := 0; i < 4; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		addresses = append(addresses, workerListener.Addr().String())
+	}
+	rule, _ := util.ParseRule("B2/S/C3")
+	for _, starts := range [][]int{{0}, {0, 4}, {0, 2, 4, 6}} {
+		world := makeWorld(8, 8, []util.Cell{{X: 3, Y: 4}, {X: 4, Y: 4}})
+		world[5][3], world[5][4] = 0x80, 0x80
+		world, err := runBands(addresses[:len(starts)], starts, world, rule, util.Torus, 3, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := makeWorld(8, 8, []util.Cell{{X: 3, Y: 1}, {X: 4, Y: 1}})
+		expected[2][3], expected[2][4] = 0x80, 0x80
+		if fmt.Sprint(world) != fmt.Sprint(expected) {
+			t.Errorf("%d bands: expected %v, got %v", len(starts), expected, world)
+		}
+	}
+}
+
+//serves a worker on a free localhost port, returning the listener on it
+func startWorker(t *testing.T) net.Listener {
+	server := rpc.NewServer()
+	server.Register(&WorkerOperations{})
+	workerListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(workerListener)
+	return workerListener
+}
+
+//runs turns on bands of the world kept by workers on the given addresses like the broker would, up to batch turns at
+//once, telling each worker which of the others have the rows its halos come from for it to get them itself
+func runBands(addresses []string, starts []int, world [][]byte, rule util.Rule, topology util.Topology,
+	turns, batch int) ([][]byte, error) {
+	board := util.PackWorldStates(world, rule)
+	bandRows := func(i int) (int, int) {
+		if i+1 < len(starts) {
+			return starts[i], starts[i+1]
+		}
+		return starts[i], board.Height()
+	}
+	clients := make([]*rpc.Client, len(addresses))
+	for i, address := range addresses {
+		client, err := rpc.Dial("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		clients[i] = client
+	}
+	for i, client := range clients {
+		start, end := bandRows(i)
+		band := util.Bitboard{Width: board.Width, Rows: board.Rows[start:end]}
+		if board.Decay != nil {
+			band.Decay = board.Decay[start:end]
+		}
+		var peers []stubs.Peer
+		for j := range clients {
+			peerStart, peerEnd := bandRows(j)
+			for _, y := range topology.HaloRows(board.Width, board.Height(), start, end, rule.Range*batch) {
+				if j != i && y >= peerStart && y < peerEnd {
+					peers = append(peers, stubs.Peer{Address: addresses[j], Start: peerStart, End: peerEnd})
+					break
+				}
+			}
+		}
+		req := stubs.Request{Slice: band, Rule: rule, Topology: topology, SliceStart: start, WorldHeight: board.Height(),
+			Peers: peers, PeerTimeout: time.Second, BatchTurns: batch}
+		if err := client.Call(stubs.LoadSlice, req, new(stubs.GenericMessage)); err != nil {
+			return nil, err
+		}
+	}
+	for turn := 0; turn < turns; turn += batch {
+		req := stubs.StepRequest{Turn: turn, Turns: batch}
+		if turns-turn < batch {
+			req.Turns = turns - turn
+		}
+		errs := make(chan error, len(clients))
+		for _, client := range clients {
+			go func(client *rpc.Client) {
+				errs <- client.Call(stubs.StepSlice, req, new(stubs.StepResponse))
+			}(client)
+		}
+		for range clients {
+			if err := <-errs; err != nil {
+				return nil, err
+			}
+		}
+	}
+	var nextWorld [][]byte
+	for _, client := range clients {
+		resp := new(stubs.Response)
+		if err := client.Call(stubs.CollectSlice, stubs.GenericMessage{}, resp); err != nil {
+			return nil, err
+		}
+		nextWorld = append(nextWorld, resp.NextSlice.Unpack()...)
+	}
+	return nextWorld, nil
+}
+
+// TestBands checks known patterns for several rules on workers that keep their bands between turns and get their
+// halos from each other, a turn at a time and several turns at once, including bands thinner than their halos,
+// and gliders crossing the dead edges of a world and the twisted edges of a projective plane.
+func TestBands(t *testing.T) {
+	var addresses []string
+	for i := 0; i < 4; i++ {
+		workerListener := startWorker(t)
+		defer workerListener.Close()
+		addresses = append(addresses, workerListener.Addr().String())
+	}
+	for _, test := range ruleTests {
+		rule, err := util.ParseRule(test.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, starts := range [][]int{{0}, {0, test.height / 2}, {0, 1, 3, test.height - 5}} {
+			for _, batch := range []int{1, 3} {
+				world, err := runBands(addresses[:len(starts)], starts, makeWorld(test.width, test.height, test.initial),
+					rule, util.Torus, test.turns, batch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				given := sortedAliveCells(world)
+				expected := sortedAliveCells(makeWorld(test.width, test.height, test.expected))
+				if fmt.Sprint(given) != fmt.Sprint(expected) {
+					t.Errorf("%v in bands from %v, %d turns at once: after %d turns expected %v, got %v", test.rule,
+						starts, batch, test.turns, expected, given)
+				}
+			}
+		}
+	}
+
+	rule, _ := util.ParseRule(util.ConwayRule)
+	for _, batch := range []int{1, 4} {
+		world := makeWorld(16, 16, []util.Cell{{X: 5, Y: 4}, {X: 6, Y: 5}, {X: 4, Y: 6}, {X: 5, Y: 6}, {X: 6, Y: 6}})
+		given, err := runBands(addresses[:3], []int{0, 5, 6}, world, rule, util.DeadBorder, 100, batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []util.Cell{{X: 14, Y: 14}, {X: 15, Y: 14}, {X: 14, Y: 15}, {X: 15, Y: 15}}
+		if fmt.Sprint(sortedAliveCells(given)) != fmt.Sprint(expected) {
+			t.Errorf("dead border, %d turns at once: expected %v, got %v", batch, expected, sortedAliveCells(given))
+		}
+	}
+
+	world := makeWorld(16, 16, glider)
+	given, err := runBands(addresses[:3], []int{0, 5, 6}, world, rule, util.ProjectivePlane, 40, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := util.PackWorldStates(world, rule)
+	for turn := 0; turn < 40; turn++ {
+		slice, edges := util.ProjectivePlane.Slice(expected, 0, expected.Height(), 1)
+		expected = slice.Step(rule, edges)
+	}
+	if fmt.Sprint(sortedAliveCells(given)) != fmt.Sprint(expected.AliveCells()) {
+		t.Errorf("projective plane: expected %v, got %v", expected.AliveCells(), sortedAliveCells(given))
+	}
+
+	//a worker can't run several turns at once on twisted edges, or a worker that hasn't been given a band a turn at all,
+	//and a worker only keeps the edges of its band after its last two steps
+	if _, err := runBands(addresses[3:], []int{0}, world, rule, util.ProjectivePlane, 2, 2); err == nil {
+		t.Error("expected a worker to fail to run several turns at once on a projective plane")
+	}
+	if err := (&WorkerOperations{}).StepSlice(stubs.StepRequest{}, new(stubs.StepResponse)); err == nil {
+		t.Error("expected a worker without a band to fail to run a turn")
+	}
+	client, err := rpc.Dial("tcp", addresses[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for turn, ok := range map[int]bool{38: false, 39: true, 40: true, 41: false} {
+		err := client.Call(stubs.GetBoundary, stubs.BoundaryRequest{Turn: turn}, new(stubs.BoundaryResponse))
+		if (err == nil) != ok {
+			t.Errorf("expected getting the edges after turn %d of 40 to succeed to be %v, got %v", turn, ok, err)
+		}
+	}
+}
+
+//heartbeatBroker counts the heartbeats it is sent
+type heartbeatBroker struct {
+	heartbeats chan bool
+}
+
+func (b *heartbeatBroker) Heartbeat(req stubs.SubscriptionRequest, resp *stubs.GenericMessage) (err error) {
+	b.heartbeats <- true
+	return
+}
+
+// TestStopHeartbeats checks that no heartbeats reach the broker once they have been stopped, as one arriving while the
+// worker unsubscribes would subscribe it again.
+func TestStopHeartbeats(t *testing.T) {
+	broker := &heartbeatBroker{heartbeats: make(chan bool, 100)}
+	server := rpc.NewServer()
+	server.RegisterName("BrokerOperations", broker)
+	brokerListener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer brokerListener.Close()
+	go server.Accept(brokerListener)
+
+	stop := make(chan bool)
+	go sendHeartbeats(brokerListener.Addr().String(), stubs.SubscriptionRequest{IP: "a:1"}, time.Millisecond, stop)
+	for i := 0; i < 10; i++ {
+		<-broker.heartbeats
+	}
+	stop <- true
+	for len(broker.heartbeats) > 0 {
+		<-broker.heartbeats
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(broker.heartbeats) != 0 {
+		t.Errorf("Expected no heartbeats after stopping them, got %v", len(broker.heartbeats))
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestCheckpointResume writes a checkpoint every 25 turns of a 100 turn run on a 64x64 image,
+// then resumes from the checkpoint after 50 turns and checks it finishes with the same world.
+func TestCheckpointResume(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{
+		Turns:           100,
+		Threads:         4,
+		ImageWidth:      64,
+		ImageHeight:     64,
+		OutputFile:      dir,
+		CheckpointTurns: 25,
+		CheckpointFile:  filepath.Join(dir, "checkpoint{turns}.pgm"),
+	}
+	runFinalCells(p, nil)
+	for _, turns := range []int{25, 50, 75} {
+		if _, err := os.Stat(filepath.Join(dir, fmt.Sprintf("checkpoint%d.pgm", turns))); err != nil {
+			t.Errorf("expected a checkpoint after %d turns: %v", turns, err)
+		}
+	}
+
+	expectedAlive := readAliveCells("check/images/64x64x100.pgm", 64, 64)
+	resumed := gol.Params{
+		Turns:      100,
+		Threads:    3,
+		OutputFile: dir,
+		Resume:     filepath.Join(dir, "checkpoint50.pgm"),
+	}
+	cells := runFinalCells(resumed, nil)
+	assertEqualBoard(t, cells, expectedAlive, p)
+}
+
+// TestCheckpointRule checks that resuming uses the rule recorded in the checkpoint rather than the params.
+func TestCheckpointRule(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{
+		Turns:           20,
+		Threads:         2,
+		ImageWidth:      64,
+		ImageHeight:     64,
+		Rule:            "B36/S23",
+		OutputFile:      dir,
+		CheckpointTurns: 10,
+		CheckpointFile:  filepath.Join(dir, "checkpoint.pgm"),
+	}
+	expected := runFinalCells(p, nil)
+
+	resumed := gol.Params{
+		Turns:      20,
+		Threads:    2,
+		OutputFile: dir,
+		Resume:     filepath.Join(dir, "checkpoint.pgm"),
+	}
+	cells := runFinalCells(resumed, nil)
+	assertEqualBoard(t, cells, expected, p)
+}
+
+// TestCheckpointEngine checks that resuming a Generations rule's checkpoint with HashLife, which can't run it,
+// is reported as an error rather than panicking.
+func TestCheckpointEngine(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{
+		Turns:           20,
+		Threads:         2,
+		ImageWidth:      64,
+		ImageHeight:     64,
+		Rule:            "B2/S/C3",
+		OutputFile:      dir,
+		CheckpointTurns: 10,
+		CheckpointFile:  filepath.Join(dir, "checkpoint.pgm"),
+	}
+	runFinalCells(p, nil)
+
+	resumed := gol.Params{
+		Turns:      20,
+		Threads:    2,
+		OutputFile: dir,
+		Engine:     gol.HashLife,
+		Resume:     filepath.Join(dir, "checkpoint.pgm"),
+	}
+	events := make(chan gol.Event)
+	go gol.Run(resumed, events, nil)
+	var errorEvent *gol.ErrorOccurred
+	var lastEvent gol.Event
+	for event := range events {
+		if e, ok := event.(gol.ErrorOccurred); ok {
+			errorEvent = &e
+		}
+		lastEvent = event
+	}
+	if errorEvent == nil || errorEvent.Err == nil || errorEvent.Filename != "" {
+		t.Fatalf("expected an ErrorOccurred event for the engine rather than a file, got %#v", errorEvent)
+	}
+	if state, ok := lastEvent.(gol.StateChange); !ok || state.NewState != gol.Quitting {
+		t.Errorf("expected the last event to be Quitting, got %#v", lastEvent)
+	}
+}
+
+// TestCheckpointTopology checks that resuming a run on each topology other than a torus, with a hexagonal
+// neighbourhood as well, continues on the edges and neighbourhood recorded in the checkpoint rather than the defaults.
+func TestCheckpointTopology(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	for _, topology := range []string{"dead", "reflect", "klein", "projective"} {
+		for _, neighbourhood := range []string{"moore", "hex"} {
+			t.Run(topology+" "+neighbourhood, func(t *testing.T) {
+				p := gol.Params{
+					Turns:           100,
+					Threads:         4,
+					ImageWidth:      64,
+					ImageHeight:     64,
+					Topology:        topology,
+					Neighbourhood:   neighbourhood,
+					OutputFile:      dir,
+					CheckpointTurns: 50,
+					CheckpointFile:  filepath.Join(dir, "checkpoint.pgm"),
+				}
+				expected := runFinalCells(p, nil)
+				torus := p
+				torus.Topology, torus.CheckpointTurns = "", 0
+				if fmt.Sprint(runFinalCells(torus, nil)) == fmt.Sprint(expected) {
+					t.Fatalf("expected %v edges to change the world after 100 turns", topology)
+				}
+
+				resumed := gol.Params{
+					Turns:      100,
+					Threads:    3,
+					OutputFile: dir,
+					Resume:     filepath.Join(dir, "checkpoint.pgm"),
+				}
+				cells := runFinalCells(resumed, nil)
+				assertEqualBoard(t, cells, expected, p)
+			})
+		}
+	}
+}
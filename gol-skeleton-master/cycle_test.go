@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// runCycle runs the game of life and returns the alive cells from the FinalTurnComplete event
+// along with every CycleDetected event
+func runCycle(p gol.Params) ([]util.Cell, []gol.CycleDetected) {
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	var cycles []gol.CycleDetected
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CycleDetected:
+			cycles = append(cycles, e)
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	return cells, cycles
+}
+
+// TestCycleStop checks that the 512x512 image is found to settle into a cycle of period 2, and that stopping on it
+// gives the alive cells count_test.go expects after a hundred million turns, and one more.
+func TestCycleStop(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	for turns, expected := range map[int]int{100000000: 5565, 100000001: 5567} {
+		t.Run(fmt.Sprint(turns), func(t *testing.T) {
+			p := gol.Params{Turns: turns, Threads: 8, ImageWidth: 512, ImageHeight: 512, StopOnCycle: true,
+				OutputFile: dir}
+			cells, cycles := runCycle(p)
+			if len(cells) != expected {
+				t.Errorf("expected %d alive cells, got %d", expected, len(cells))
+			}
+			if len(cycles) != 1 {
+				t.Fatalf("expected a single CycleDetected event, got %v", cycles)
+			}
+			cycle := cycles[0]
+			if cycle.Period != 2 || cycle.StartTurn > 10000 || cycle.CompletedTurns != cycle.StartTurn+2 {
+				t.Errorf("expected a period of 2 starting before turn 10000, got %+v", cycle)
+			}
+		})
+	}
+}
+
+// TestCycleGlider checks that a glider is found to return to where it started on a 16x16 torus after 64 turns,
+// and that skipping its cycles gives the same result as running every turn, including when resuming from a checkpoint
+// part way through a cycle. A shorter history misses the cycle.
+func TestCycleGlider(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "glider.rle")
+	if err := ioutil.WriteFile(filename, []byte(gliderRle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 1000, Threads: 4, ImageWidth: 16, ImageHeight: 16, InputFile: filename, OutputFile: dir}
+	expected := runFinalCells(p, nil)
+
+	stopped := p
+	stopped.CycleHistory, stopped.StopOnCycle = 64, true
+	cells, cycles := runCycle(stopped)
+	assertEqualBoard(t, cells, expected, stopped)
+	if len(cycles) != 1 || cycles[0] != (gol.CycleDetected{CompletedTurns: 64, StartTurn: 0, Period: 64}) {
+		t.Errorf("expected a period of 64 from turn 0, got %v", cycles)
+	}
+
+	detected := p
+	detected.CycleHistory, detected.CheckpointTurns = 64, 70
+	detected.CheckpointFile = filepath.Join(dir, "checkpoint.pgm")
+	cells, cycles = runCycle(detected)
+	assertEqualBoard(t, cells, expected, detected)
+	if len(cycles) != 1 {
+		t.Errorf("expected a single CycleDetected event, got %v", cycles)
+	}
+
+	//the last checkpoint is after 980 turns
+	resumed := gol.Params{Turns: 5000, Threads: 2, OutputFile: dir, Resume: detected.CheckpointFile, StopOnCycle: true}
+	cells, cycles = runCycle(resumed)
+	longer := p
+	longer.Turns = 5000
+	assertEqualBoard(t, cells, runFinalCells(longer, nil), resumed)
+	if len(cycles) != 1 || cycles[0] != (gol.CycleDetected{CompletedTurns: 1044, StartTurn: 980, Period: 64}) {
+		t.Errorf("expected a period of 64 from turn 980, got %v", cycles)
+	}
+
+	short := stopped
+	short.CycleHistory = 63
+	cells, cycles = runCycle(short)
+	assertEqualBoard(t, cells, expected, short)
+	if len(cycles) != 0 {
+		t.Errorf("expected a history of 63 to miss the cycle, got %v", cycles)
+	}
+}
+
+// TestCycleReported checks that a cycle is reported as soon as it is found when the run doesn't stop on it,
+// rather than once the run has finished.
+func TestCycleReported(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "glider.rle")
+	if err := ioutil.WriteFile(filename, []byte(gliderRle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 1 << 40, Threads: 4, ImageWidth: 16, ImageHeight: 16, InputFile: filename, OutputFile: dir,
+		CycleHistory: 64, AliveCellsInterval: 10 * time.Millisecond}
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 1)
+	go gol.Run(p, events, keyPresses)
+	var cycles []gol.CycleDetected
+	quitting := false
+	timeout := time.After(30 * time.Second)
+	for done := false; !done; {
+		select {
+		case event, ok := <-events:
+			done = !ok
+			switch e := event.(type) {
+			case gol.CycleDetected:
+				cycles = append(cycles, e)
+				//the run would take far longer than the test to finish
+				keyPresses <- 'q'
+			case gol.StateChange:
+				quitting = quitting || e.NewState == gol.Quitting
+			}
+		case <-timeout:
+			if len(cycles) == 0 {
+				t.Fatal("expected a CycleDetected event long before the run finished")
+			}
+			t.Fatal("expected the run to quit once q was pressed")
+		}
+	}
+	if !quitting {
+		t.Error("expected a Quitting event before the events were closed")
+	}
+	if len(cycles) != 1 || cycles[0] != (gol.CycleDetected{CompletedTurns: 64, StartTurn: 0, Period: 64}) {
+		t.Errorf("expected a single period of 64 from turn 0, got %v", cycles)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestSkipEvents runs 64x64 for 100 turns skipping the events sent for every cell and turn, checking that none of
+// them are sent and that the final turn is still right.
+func TestSkipEvents(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	skip := gol.SkipCellFlipped | gol.SkipCellDecayed | gol.SkipTurnComplete | gol.SkipImageOutputComplete
+	p := gol.Params{Turns: 100, Threads: 4, ImageWidth: 64, ImageHeight: 64, OutputFile: dir, SkipEvents: skip}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var cells []util.Cell
+	counts := make(map[string]int)
+	for event := range events {
+		counts[fmt.Sprintf("%T", event)]++
+		switch e := event.(type) {
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	for _, skipped := range []gol.Event{gol.CellFlipped{}, gol.CellDecayed{}, gol.TurnComplete{}, gol.ImageOutputComplete{}} {
+		if count := counts[fmt.Sprintf("%T", skipped)]; count > 0 {
+			t.Errorf("expected no %T events, got %d", skipped, count)
+		}
+	}
+	if counts["gol.FinalTurnComplete"] != 1 || counts["gol.StateChange"] != 1 {
+		t.Errorf("expected the final turn and quitting to still be sent, got %v", counts)
+	}
+	assertEqualBoard(t, cells, readAliveCells("check/images/64x64x100.pgm", 64, 64), p)
+}
+
+// TestAliveCellsInterval checks that the alive cells of 512x512 are counted every 100ms when asked to, against the
+// counts in check/alive, with the cells that change not sent as in a run without a window.
+func TestAliveCellsInterval(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{Turns: 100000000, Threads: 8, ImageWidth: 512, ImageHeight: 512, OutputFile: dir,
+		AliveCellsInterval: 100 * time.Millisecond, SkipEvents: gol.SkipCellFlipped | gol.SkipTurnComplete}
+	alive := readAliveCounts(p.ImageWidth, p.ImageHeight)
+	events := make(chan gol.Event)
+	keyPresses := make(chan rune, 2)
+	go gol.Run(p, events, keyPresses)
+
+	start := time.Now()
+	counted := 0
+	quitting := false
+	timeout := time.After(30 * time.Second)
+	for done := false; !done; {
+		var event gol.Event
+		select {
+		case next, ok := <-events:
+			event, done = next, !ok
+		case <-timeout:
+			t.Fatalf("expected the run to quit once q was pressed, got %d counts", counted)
+		}
+		switch e := event.(type) {
+		case gol.AliveCellsCount:
+			expected := alive[e.CompletedTurns]
+			if e.CompletedTurns > 10000 {
+				expected = 5565 + 2*(e.CompletedTurns%2)
+			}
+			if e.CellsCount != expected {
+				t.Errorf("At turn %v expected %v alive cells, got %v instead", e.CompletedTurns, expected, e.CellsCount)
+			}
+			counted++
+			if counted == 10 {
+				keyPresses <- 'q'
+			}
+		case gol.CellFlipped, gol.TurnComplete:
+			t.Fatalf("expected no %T events", e)
+		case gol.StateChange:
+			quitting = quitting || e.NewState == gol.Quitting
+		}
+	}
+	if !quitting {
+		t.Error("expected a Quitting event before the events were closed")
+	}
+	//ten counts at the default interval would take 20 seconds
+	if elapsed := time.Since(start); counted < 10 || elapsed > 5*time.Second {
+		t.Errorf("expected 10 counts within 5 seconds, got %d in %v", counted, elapsed)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+//a 16x16 pgm image holding a Brian's Brain spaceship heading up from y, two alive cells with two decaying ones below
+func spaceshipImage(y int) []byte {
+	pixels := make([]byte, 16*16)
+	pixels[16*y+7], pixels[16*y+8] = 0xFF, 0xFF
+	pixels[16*((y+1)%16)+7], pixels[16*((y+1)%16)+8] = 0x80, 0x80
+	return append([]byte("P5\n16 16\n255\n"), pixels...)
+}
+
+// TestGenerations runs a Brian's Brain (B2/S/C3) spaceship across the top edge of a 16x16 world,
+// checking that the decaying cells are written to the image as grey, that reading the image back keeps them,
+// and that replaying the CellFlipped and CellDecayed events draws the same image.
+func TestGenerations(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	input := filepath.Join(dir, "spaceship.pgm")
+	if err := ioutil.WriteFile(input, spaceshipImage(2), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, threads := range []int{1, 4} {
+		t.Run(fmt.Sprint(threads), func(t *testing.T) {
+			output := filepath.Join(dir, fmt.Sprintf("out-%d-{turns}.pgm", threads))
+			p := gol.Params{Turns: 5, Threads: threads, ImageWidth: 16, ImageHeight: 16, Rule: "B2/S/C3",
+				InputFile: input, OutputFile: output}
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			pixels := make([]byte, 16*16)
+			var cells []util.Cell
+			for event := range events {
+				switch e := event.(type) {
+				case gol.CellFlipped:
+					pixels[16*e.Cell.Y+e.Cell.X] ^= 0xFF
+				case gol.CellDecayed:
+					pixels[16*e.Cell.Y+e.Cell.X] = e.Grey
+				case gol.FinalTurnComplete:
+					cells = e.Alive
+				}
+			}
+			//the spaceship moves up a cell every turn, so after 5 turns it has wrapped around to the bottom
+			expected := spaceshipImage(13)
+			assertEqualBoard(t, cells, []util.Cell{{X: 7, Y: 13}, {X: 8, Y: 13}}, p)
+			image, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("out-%d-5.pgm", threads)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(image) != string(expected) {
+				t.Errorf("expected the image %q, got %q", expected, image)
+			}
+			if string(pixels) != string(expected[len(expected)-len(pixels):]) {
+				t.Errorf("expected the events to draw %v, got %v", expected[len(expected)-len(pixels):], pixels)
+			}
+
+			resumed := p
+			resumed.Turns, resumed.InputFile = 3, filepath.Join(dir, fmt.Sprintf("out-%d-5.pgm", threads))
+			assertEqualBoard(t, runFinalCells(resumed, nil), []util.Cell{{X: 7, Y: 10}, {X: 8, Y: 10}}, resumed)
+		})
+	}
+}
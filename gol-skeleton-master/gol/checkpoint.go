@@ -0,0 +1,156 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// defaultCheckpointFile is the checkpoint filename template used when Params.CheckpointFile is empty.
+// Leaving out {turns} means each checkpoint replaces the previous one.
+const defaultCheckpointFile = "out/{w}x{h}.checkpoint.pgm"
+
+// Checkpoints are pgm images with these comments recording the state that isn't in the world itself.
+const (
+	checkpointTurnsComment         = "completed turns:"
+	checkpointRuleComment          = "rule:"
+	checkpointTopologyComment      = "topology:"
+	checkpointNeighbourhoodComment = "neighbourhood:"
+	checkpointRangeComment         = "range:"
+)
+
+// checkpoint is what a checkpoint records besides the world,
+// including the density and seed of the random soup it started as, if it did.
+// The topology, neighbourhood and range are empty in checkpoints written before they were recorded.
+type checkpoint struct {
+	turns         int
+	rule          string
+	topology      string
+	neighbourhood string
+	radius        int
+	random        float64
+	seed          int64
+}
+
+// checkpointFilename fills in the checkpoint filename template for a world after the given number of turns.
+func checkpointFilename(p Params, turns int) string {
+	template := p.CheckpointFile
+	if template == "" && p.Random > 0 {
+		template = defaultRandomCheckpointFile
+	} else if template == "" {
+		template = defaultCheckpointFile
+	}
+	return fillFilename(template, p, turns)
+}
+
+// checkpointDue returns whether a checkpoint should be written after the given number of completed turns.
+func checkpointDue(p Params, turns int) bool {
+	return p.CheckpointTurns > 0 && turns%p.CheckpointTurns == 0 && turns < p.Turns
+}
+
+// topologyComments returns the comments recording the edges of the world and the neighbourhood of the rule,
+// so that resuming a checkpoint runs on the same ones.
+func topologyComments(p Params, rule util.Rule) []string {
+	return []string{
+		checkpointTopologyComment + " " + WorldTopology(p),
+		checkpointNeighbourhoodComment + " " + rule.Neighbourhood.String(),
+		checkpointRangeComment + " " + strconv.Itoa(rule.Range),
+	}
+}
+
+// writeCheckpointFile writes the world as a pgm image with the completed turns, rule and any other comments.
+// The image is written to a temporary file which then replaces the checkpoint, so a crash part way through
+// never leaves a broken checkpoint behind.
+func writeCheckpointFile(filename string, world [][]byte, turns int, rule string, comments ...string) error {
+	file, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	err = writePgm(file, world, append([]string{
+		checkpointTurnsComment + " " + strconv.Itoa(turns),
+		checkpointRuleComment + " " + rule}, comments...)...)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// readCheckpoint reads the completed turns, rule, topology and random soup recorded in a checkpoint's comments.
+// The world itself is read like any other pgm image.
+func readCheckpoint(filename string) (checkpoint, error) {
+	saved := checkpoint{turns: -1}
+	file, err := os.Open(filename)
+	if err != nil {
+		return saved, err
+	}
+	defer file.Close()
+
+	//The comments come straight after the magic number, so stop at the first other line
+	reader := bufio.NewReader(file)
+	magic, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(magic) != "P5" {
+		return saved, errors.New(filename + " is not a checkpoint")
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, "#") {
+			break
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if strings.HasPrefix(comment, checkpointTurnsComment) {
+			saved.turns, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(comment, checkpointTurnsComment)))
+			if err != nil || saved.turns < 0 {
+				return saved, fmt.Errorf("%v: invalid completed turns: %v", filename, comment)
+			}
+		} else if strings.HasPrefix(comment, checkpointRuleComment) {
+			saved.rule = strings.TrimSpace(strings.TrimPrefix(comment, checkpointRuleComment))
+		} else if strings.HasPrefix(comment, checkpointTopologyComment) {
+			saved.topology = strings.TrimSpace(strings.TrimPrefix(comment, checkpointTopologyComment))
+			if _, err := util.ParseTopology(saved.topology); err != nil {
+				return saved, fmt.Errorf("%v: %v", filename, err)
+			}
+		} else if strings.HasPrefix(comment, checkpointNeighbourhoodComment) {
+			saved.neighbourhood = strings.TrimSpace(strings.TrimPrefix(comment, checkpointNeighbourhoodComment))
+			if _, err := util.ParseNeighbourhood(saved.neighbourhood); err != nil {
+				return saved, fmt.Errorf("%v: %v", filename, err)
+			}
+		} else if strings.HasPrefix(comment, checkpointRangeComment) {
+			saved.radius, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(comment, checkpointRangeComment)))
+			if err != nil || saved.radius < 1 {
+				return saved, fmt.Errorf("%v: invalid range: %v", filename, comment)
+			}
+		} else if strings.HasPrefix(comment, randomComment) {
+			saved.random, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimPrefix(comment, randomComment)), 64)
+			if err != nil {
+				return saved, fmt.Errorf("%v: invalid density: %v", filename, comment)
+			}
+		} else if strings.HasPrefix(comment, seedComment) {
+			saved.seed, err = strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(comment, seedComment)), 10, 64)
+			if err != nil {
+				return saved, fmt.Errorf("%v: invalid seed: %v", filename, comment)
+			}
+		}
+	}
+	if saved.turns < 0 || saved.rule == "" {
+		return saved, errors.New(filename + " is missing the completed turns or rule of a checkpoint")
+	}
+	if _, err := util.ParseRule(saved.rule); err != nil {
+		return saved, fmt.Errorf("%v: %v", filename, err)
+	}
+	return saved, nil
+}
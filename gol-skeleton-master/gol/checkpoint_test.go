@@ -0,0 +1,77 @@
+package gol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCheckpointFile checks that a checkpoint reads back with the same world, completed turns and rule,
+// and that a plain pgm image is not mistaken for a checkpoint.
+func TestCheckpointFile(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	world := [][]byte{
+		{0x00, 0xFF, 0x00},
+		{0xFF, 0x00, 0xFF},
+	}
+	filename := filepath.Join(dir, "checkpoint.pgm")
+	if err := writeCheckpointFile(filename, world, 10000000000, "B36/S23"); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.turns != 10000000000 || saved.rule != "B36/S23" {
+		t.Errorf("expected 10000000000 turns of B36/S23, got %v turns of %v", saved.turns, saved.rule)
+	}
+	read, err := readPgmFile(filename, 3, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(read) != fmt.Sprint(world) {
+		t.Errorf("expected %v, got %v", world, read)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected only the checkpoint to be left, got %v files", len(files))
+	}
+
+	if _, err := readCheckpoint("../images/16x16.pgm"); err == nil {
+		t.Error("expected an error for a pgm image without checkpoint comments")
+	}
+}
+
+// TestCheckpointUnbounded checks that an unbounded world can't be checkpointed or resumed, as a checkpoint only holds
+// the part of it in the image.
+func TestCheckpointUnbounded(t *testing.T) {
+	for _, p := range []Params{
+		{CheckpointTurns: 100},
+		{CheckpointInterval: time.Minute},
+		{Resume: "out/16x16.checkpoint.pgm"},
+	} {
+		p.ImageWidth, p.ImageHeight = 16, 16
+		if err := CheckEngine(p); err != nil {
+			t.Errorf("expected %+v to run with edges, got %v", p, err)
+		}
+		p.Unbounded = true
+		if err := CheckEngine(p); err == nil {
+			t.Errorf("expected %+v to fail without edges", p)
+		}
+	}
+}
+
+// tempDir makes a directory for the files of a test, returning it and a function removing it for the test to defer.
+func tempDir(t testing.TB) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
@@ -0,0 +1,18 @@
+package gol
+
+// defaultCycleHistory is how many worlds are hashed to spot repeats when stopping on a cycle without a CycleHistory.
+const defaultCycleHistory = 64
+
+// cycleHistory returns how many of the latest worlds should be hashed to spot the world repeating, or 0 for none.
+func cycleHistory(p Params) int {
+	if p.CycleHistory == 0 && p.StopOnCycle {
+		return defaultCycleHistory
+	}
+	return p.CycleHistory
+}
+
+// skipCycles returns the completed turns after skipping as many whole cycles of the period as fit before p.Turns,
+// which leaves the world exactly as it was.
+func skipCycles(p Params, turns, period int) int {
+	return turns + (p.Turns-turns)/period*period
+}
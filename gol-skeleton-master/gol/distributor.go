@@ -2,7 +2,6 @@ package gol
 
 import (
 	"fmt"
-	"strconv"
 	"time"
 	"uk.ac.bris.cs/gameoflife/util"
 )
@@ -14,52 +13,116 @@ type distributorChannels struct {
 	ioFilename chan<- string
 	ioOutput   chan<- uint8
 	ioInput    <-chan uint8
+	ioErrors   <-chan error
+	ioTurns    chan<- int
 	keyPresses <-chan rune
 }
 
 // distributor divides the work between workers and interacts with other goroutines.
-func distributor(p Params, c distributorChannels) {
+// startTurn is the number of turns already completed by the world being read in, which is non-zero when resuming.
+func distributor(p Params, c distributorChannels, startTurn int) {
+	//an empty rule means Conway's Game of Life
+	rule, err := ruleFromParams(p)
+	if err != nil {
+		quitWithError(c.events, "", err, startTurn)
+		return
+	}
 
-	//Create a 2D slice to store the world
-	currentWorld := make([][]byte, p.ImageWidth)
-	for i := 0; i < p.ImageWidth; i++ {
-		currentWorld[i] = make([]byte, p.ImageHeight)
+	//Create a bitboard to store the world, indexed by row (y) then column (x)
+	currentWorld := util.NewBitboard(p.ImageWidth, p.ImageHeight)
+	if rule.States > 2 {
+		currentWorld = currentWorld.WithDecay()
 	}
 
-	//read in initial state of GOL using io.go
-	filename := strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageHeight)
-	c.ioCommand <- ioInput
-	c.ioFilename <- filename
+	//read in initial state of GOL using io.go, unless it starts as a random soup
+	var soup [][]byte
+	if isRandom(p) {
+		soup = randomWorld(p)
+	} else {
+		c.ioCommand <- inputCommand(p)
+		c.ioFilename <- inputFilename(p)
+		if err := <-c.ioErrors; err != nil {
+			quitWithError(c.events, inputFilename(p), err, startTurn)
+			return
+		}
+	}
 	//read file into current world
-	for j, _ := range currentWorld	{
-		for k, _ := range currentWorld[j]	{
-			newPixel := <-c.ioInput
+	for y, _ := range currentWorld.Rows	{
+		for x := 0; x < currentWorld.Width; x++	{
+			var newPixel uint8
+			if soup != nil {
+				newPixel = soup[y][x]
+			} else {
+				newPixel = <-c.ioInput
+			}
 			if newPixel == 0xFF{
-				c.events <- CellFlipped{Cell: util.Cell{X: j,Y: k},CompletedTurns: 0}
+				if !p.SkipEvents.Skips(SkipCellFlipped) {
+					c.events <- CellFlipped{Cell: util.Cell{X: x,Y: y},CompletedTurns: startTurn}
+				}
+				currentWorld.Set(x, y, true)
+			} else if newPixel != 0x00 && rule.States > 2 {
+				//grey pixels are decaying cells under a Generations rule
+				grey := rule.Grey(rule.State(newPixel))
+				currentWorld.SetGrey(x, y, grey)
+				if !p.SkipEvents.Skips(SkipCellDecayed) {
+					c.events <- CellDecayed{Cell: util.Cell{X: x, Y: y}, Grey: grey, CompletedTurns: startTurn}
+				}
 			}
-			currentWorld[j][k] = newPixel
 		}
 	}
-	//initialize worker channels.
-	var workerChannels []chan [][]byte
-	for i := 0;i < p.Threads;i++ {
-		workerChannels = append(workerChannels, make(chan [][]byte))
+	//start the engine, whose workers keep working on the same band of the world until the end
+	lifeEngine, err := newEngine(p, currentWorld, rule)
+	if err != nil {
+		quitWithError(c.events, "", err, startTurn)
+		return
+	}
+	defer lifeEngine.stop()
+	//the worlds are hashed to spot them repeating if asked to, starting with the one read in
+	var cycles *util.CycleDetector
+	if history := cycleHistory(p); history > 0 {
+		cycles = util.NewCycleDetector(history)
+		cycles.Observe(lifeEngine.hash(), startTurn)
+	}
+	//the statistics of every turn are written to a file if asked for
+	var stats *statsWriter
+	if p.StatsFile != "" {
+		if stats, err = createStatsFile(p.StatsFile); err != nil {
+			quitWithError(c.events, p.StatsFile, err, startTurn)
+			return
+		}
 	}
 
-	// Execute all turns of the Game of Life.
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
+	//the alive cells are counted on a timer, unless their events are skipped
+	var aliveCountTimes <-chan time.Time
+	if !p.SkipEvents.Skips(SkipAliveCellsCount) {
+		ticker := time.NewTicker(aliveCellsInterval(p))
+		defer ticker.Stop()
+		aliveCountTimes = ticker.C
+	}
+	//checkpoints are also written on a timer if an interval is set
+	var checkpointTimes <-chan time.Time
+	if p.CheckpointInterval > 0 {
+		checkpointTicker := time.NewTicker(p.CheckpointInterval)
+		defer checkpointTicker.Stop()
+		checkpointTimes = checkpointTicker.C
+	}
 
-	turnCounter := 0
+	turnCounter := startTurn
 	turns := p.Turns
-	columnsPerChannel := len(currentWorld) / p.Threads
+	//set when a file can't be written, which stops execution
+	var ioError error
 
 	// Execute all turns of the Game of Life.
-	for turn := 0; turn < turns; turn++ {
+	for turn := startTurn; turn < turns; turn++ {
 		select {
-		case <-ticker.C:
-			cells := getAliveCellsCount(currentWorld)
+		case <-aliveCountTimes:
+			cells := lifeEngine.aliveCount()
 			c.events <- AliveCellsCount{CellsCount: cells,CompletedTurns: turnCounter}
+		case <-checkpointTimes:
+			ioError = writeCheckpoint(p, c, currentWorld, turnCounter)
+			if ioError != nil {
+				turn = p.Turns
+			}
 		case command := <-c.keyPresses:
 			switch command	{
 			case 'p':
@@ -72,9 +135,16 @@ func distributor(p Params, c distributorChannels) {
 						done = true
 						break
 					case 's':
-						writeFile(p, c, currentWorld, turnCounter)
+						ioError = writeFile(p, c, currentWorld, turnCounter)
+						if ioError == nil {
+							ioError = writeRleFile(p, c, currentWorld, turnCounter)
+						}
 					case 'q':
-						writeFile(p, c, currentWorld, turnCounter)
+						ioError = writeFile(p, c, currentWorld, turnCounter)
+						done = true
+						turn = p.Turns
+					}
+					if ioError != nil {
 						done = true
 						turn = p.Turns
 					}
@@ -84,58 +154,97 @@ func distributor(p Params, c distributorChannels) {
 				}
 			case 's':
 				fmt.Println("s")
-				writeFile(p, c, currentWorld, turnCounter)
+				ioError = writeFile(p, c, currentWorld, turnCounter)
+				if ioError == nil {
+					ioError = writeRleFile(p, c, currentWorld, turnCounter)
+				}
+				if ioError != nil {
+					turn = p.Turns
+				}
 			case 'q':
 				fmt.Println("q")
-				writeFile(p, c, currentWorld, turnCounter)
+				ioError = writeFile(p, c, currentWorld, turnCounter)
 				turn = p.Turns
 			}
 			default:
-				nextWorld := [][]byte{}
-				//Splitting up world and distributing to channels
-				remainderThreads := len(currentWorld) % p.Threads
-				offset := 0
-				for sliceNum := 0; sliceNum < p.Threads; sliceNum++{
-					go worker(workerChannels[sliceNum])
-					currentSlice := sliceWorld(sliceNum,columnsPerChannel,currentWorld,&remainderThreads,&offset)
-					workerChannels[sliceNum] <- currentSlice
+				//update current world, which may jump several turns at once
+				completedTurns := lifeEngine.turnsAtOnce(maxTurnsAtOnce(p, turnCounter))
+				previousWorld := currentWorld
+				//the cells that change are only looked for if their events are sent
+				var flipped func(util.Cell)
+				if !p.SkipEvents.Skips(SkipCellFlipped) {
+					flipped = func(cell util.Cell) {
+						c.events <- CellFlipped{Cell: cell, CompletedTurns: turnCounter + completedTurns}
+					}
+				}
+				currentWorld = lifeEngine.advance(completedTurns, flipped)
+				//the previous world is left alone until the next turn, so decay levels can be compared with it
+				if !p.SkipEvents.Skips(SkipCellDecayed) {
+					previousWorld.DecayedCells(currentWorld, func(cell util.Cell, grey uint8) {
+						c.events <- CellDecayed{Cell: cell, Grey: grey, CompletedTurns: turnCounter + completedTurns}
+					})
+				}
+				turnCounter += completedTurns
+				turn = turnCounter - 1
+				if !p.SkipEvents.Skips(SkipTurnComplete) {
+					c.events <- TurnComplete{CompletedTurns: turnCounter}
 				}
-				//Reconstructing image from worker channels
-				for i := range workerChannels{
-					nextSlice := <- workerChannels[i]
-					for j := range nextSlice{
-						nextWorld = append(nextWorld, nextSlice[j])
+				if checkpointDue(p, turnCounter) {
+					ioError = writeCheckpoint(p, c, currentWorld, turnCounter)
+					if ioError != nil {
+						turn = p.Turns
+					}
+				}
+				if stats != nil && ioError == nil {
+					turnStats := lifeEngine.stats(p.Threads)
+					turnStats.CompletedTurns = turnCounter
+					if ioError = stats.write(turnStats); ioError != nil {
+						c.events <- ErrorOccurred{CompletedTurns: turnCounter, Filename: p.StatsFile, Err: ioError}
+						turn = p.Turns
 					}
 				}
-				//update current world.
-				for i := range currentWorld	{
-					for j := range currentWorld[i]	{
-						if currentWorld[i][j] != nextWorld[i][j]	{
-							c.events <- CellFlipped{Cell: util.Cell{X: i,Y: j},CompletedTurns: turns}
+				if cycles != nil && ioError == nil {
+					if start, period, found := cycles.Observe(lifeEngine.hash(), turnCounter); found {
+						if !p.SkipEvents.Skips(SkipCycleDetected) {
+							c.events <- CycleDetected{CompletedTurns: turnCounter, StartTurn: start, Period: period}
+						}
+						cycles = nil
+						//the world is the same after every whole cycle, so they can be skipped
+						if p.StopOnCycle {
+							turnCounter = skipCycles(p, turnCounter, period)
+							turn = turnCounter - 1
 						}
-						currentWorld[i][j] = nextWorld[i][j]
 					}
 				}
-				turn = turnCounter
-				turnCounter++
-				c.events <- TurnComplete{CompletedTurns: turnCounter}
 		}
 	}
 
-	//calculate the alive cells
-	aliveCells := make([]util.Cell	, 0, p.ImageWidth * p.ImageHeight)
-	for i , _ := range currentWorld {
-		for j, _ := range currentWorld[i] {
-			if currentWorld[i][j] == 0xFF	{
-				newCell := util.Cell{X: j, Y: i}
-				aliveCells = append(aliveCells, newCell)
-			}
+	if stats != nil {
+		if err := stats.close(); err != nil && ioError == nil {
+			c.events <- ErrorOccurred{CompletedTurns: turnCounter, Filename: p.StatsFile, Err: err}
+			ioError = err
 		}
 	}
+
+	//the error has already been reported, so quit without a final turn
+	if ioError != nil {
+		c.events <- StateChange{turnCounter, Quitting}
+		close(c.events)
+		return
+	}
+
+	//the final world is written before the final turn is reported, so that failing to write it is reported instead
+	if err := writeFile(p, c, currentWorld, turns); err != nil {
+		c.events <- StateChange{turns, Quitting}
+		close(c.events)
+		return
+	}
+
+	//calculate the alive cells
+	aliveCells := lifeEngine.aliveCells()
 	c.events <- FinalTurnComplete{
 		CompletedTurns: turns,
 		Alive: aliveCells}
-	writeFile(p, c, currentWorld, turns)
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
 	c.events <- StateChange{turns, Quitting}
@@ -144,130 +253,63 @@ func distributor(p Params, c distributorChannels) {
 	close(c.events)
 }
 
-//Helper function for splitting the world into slices
-func sliceWorld(sliceNum int,columnsPerChannel int,currentWorld [][]byte,remainderThreads *int,offset *int) [][]byte{
-	var currentSlice [][]byte
-	//Adding extra column to back of slice to avoid lines of cells that aren't processed
-	extraBackHaloColumnIndex := boundNumber(sliceNum * columnsPerChannel - 1 + *offset,len(currentWorld))
-	currentSlice = append(currentSlice,currentWorld[extraBackHaloColumnIndex])
-	for i := 0;i < columnsPerChannel;i++{
-		currentSlice = append(currentSlice,currentWorld[boundNumber(sliceNum * columnsPerChannel + i + *offset,len(currentWorld))])
-	}
-	//Adding extra column to this thread if the world doesn't split into each thread without remainders
-	if *remainderThreads > 0 {
-		*remainderThreads -= 1
-		currentSlice = append(currentSlice,
-			currentWorld[boundNumber(sliceNum * columnsPerChannel + columnsPerChannel + *offset,len(currentWorld))])
-		*offset += 1
-	}
-	//Adding extra column to front of slice to avoid lines of cells that aren't processed
-	extraFrontHaloColumnIndex := boundNumber(sliceNum * columnsPerChannel + columnsPerChannel + *offset,len(currentWorld))
-	currentSlice = append(currentSlice,currentWorld[extraFrontHaloColumnIndex])
-	return currentSlice
-}
-
-//calculates the number of alive cells given the current world
-func getAliveCellsCount(currentWorld [][]byte) int	{
-	counter := 0
-	for i , _ := range currentWorld {
-		for j, _ := range currentWorld[i] {
-			if currentWorld[i][j] == 0xFF	{
-				counter++
-			}
+//the most turns that can be done at once without going past the last turn or a checkpoint
+func maxTurnsAtOnce(p Params, turnCounter int) int {
+	maxTurns := p.Turns - turnCounter
+	if p.CheckpointTurns > 0 {
+		untilCheckpoint := p.CheckpointTurns - turnCounter%p.CheckpointTurns
+		if untilCheckpoint < maxTurns {
+			maxTurns = untilCheckpoint
 		}
 	}
-	return counter
+	return maxTurns
 }
 
-//Sends the next state of a slice to the given channel, should be run as goroutine
-func worker(channel chan [][]byte) {
-	currentSlice := <- channel
-	//Making new slice to write changes to
-	nextSlice := make([][]byte, len(currentSlice) - 2)
-	for i := range nextSlice{
-		nextSlice[i] = make([]byte, len(currentSlice[0]))
-	}
-	for i := 1;i < len(currentSlice) - 1;i++	{
-		for j := range currentSlice[i]	{
-			if getNumSurroundingCells(i, j, currentSlice) == 3 {
-				nextSlice[i-1][j] = 0xFF
-			}	else if getNumSurroundingCells(i, j, currentSlice) < 2	{
-				nextSlice[i-1][j] = 0
-			}	else if getNumSurroundingCells(i, j, currentSlice) > 3	{
-				nextSlice[i-1][j] = 0
-			}	else if getNumSurroundingCells(i, j, currentSlice) == 2 {
-				nextSlice[i-1][j] = currentSlice[i][j]
-			}
-		}
-	}
-	channel <- nextSlice
+//writes file safely
+func writeFile(p Params, c distributorChannels, currentWorld util.Bitboard, turns int) error {
+	outFile := outputFilename(p, turns)
+	return writeWorld(p, c, outputCommand(outFile), outFile, currentWorld, turns)
 }
 
-//count number of active cells surrounding a current cell
-func getNumSurroundingCells(x int, y int, world [][]byte)	int{
-	const ALIVE = 0xFF
-	var counter = 0
-	var succX = x + 1
-	var succY = y + 1
-	var prevX = x - 1
-	var prevY = y - 1
-	succX = boundNumber(succX,len(world))
-	succY = boundNumber(succY,len(world[0]))
-	prevX = boundNumber(prevX,len(world))
-	prevY = boundNumber(prevY,len(world[0]))
-	if world[prevX][y] == ALIVE	{
-		counter++
-	}
-	if world[prevX][prevY] == ALIVE {
-		counter++
-	}
-	if world[prevX][succY] == ALIVE {
-		counter++
-	}
-	if world[x][succY] == ALIVE {
-		counter++
-	}
-	if world[x][prevY] == ALIVE {
-		counter++
-	}
-	if world[succX][y] == ALIVE {
-		counter++
-	}
-	if world[succX][succY] == ALIVE {
-		counter++
+//writes the world as an rle pattern next to the output file, unless the output file already is one
+func writeRleFile(p Params, c distributorChannels, currentWorld util.Bitboard, turns int) error {
+	outFile := outputFilename(p, turns)
+	if rleFile := rleFilename(outFile); rleFile != outFile {
+		return writeWorld(p, c, ioOutputPattern, rleFile, currentWorld, turns)
 	}
-	if world[succX][prevY] == ALIVE {
-		counter++
-	}
-	return counter
+	return nil
 }
 
-//function is only necessary since golang's modulo operator can return negative values
-func boundNumber(num int,worldLen int) int{
-	if num < 0 {
-		return num + worldLen
-	}else if num > worldLen - 1 {
-		return num - worldLen
-	}else{
-		return num
-	}
+//writes a checkpoint that the run can be resumed from
+func writeCheckpoint(p Params, c distributorChannels, currentWorld util.Bitboard, turns int) error {
+	return writeWorld(p, c, ioOutputCheckpoint, checkpointFilename(p, turns), currentWorld, turns)
 }
 
-//writes file safely
-func writeFile(p Params, c distributorChannels, currentWorld [][]byte, turns int)	{
-	outFile := strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(turns)
-	c.ioCommand <- ioOutput
+//sends the world to the io goroutine to be written using the given command
+//if the file can't be written an ErrorOccurred event is sent and the error is returned
+func writeWorld(p Params, c distributorChannels, command ioCommand, outFile string, currentWorld util.Bitboard, turns int) error {
+	c.ioCommand <- command
 	c.ioFilename <- outFile
-	for i := range currentWorld	{
-		for j := range currentWorld[i]	{
-			c.ioOutput <- currentWorld[i][j]
+	if command == ioOutputCheckpoint {
+		c.ioTurns <- turns
+	}
+	for i := range currentWorld.Rows	{
+		for j := 0; j < currentWorld.Width; j++	{
+			c.ioOutput <- currentWorld.Grey(j, i)
 		}
 	}
+	if err := <-c.ioErrors; err != nil {
+		c.events <- ErrorOccurred{CompletedTurns: turns, Filename: outFile, Err: err}
+		return err
+	}
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
 	<-c.ioIdle
-	c.events <- ImageOutputComplete{
-		CompletedTurns: turns,
-		Filename: outFile,
+	if !p.SkipEvents.Skips(SkipImageOutputComplete) {
+		c.events <- ImageOutputComplete{
+			CompletedTurns: turns,
+			Filename: outFile,
+		}
 	}
+	return nil
 }
\ No newline at end of file
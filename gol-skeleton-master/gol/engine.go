@@ -0,0 +1,162 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// engine works out future states of the world for the distributor.
+type engine interface {
+	//returns how many turns the engine will advance at once, between 1 and maxTurns
+	turnsAtOnce(maxTurns int) int
+	//advances the world by the given number of turns, calling flipped with every cell that changes unless it is nil,
+	//and returns the part of the world written to images
+	advance(turns int, flipped func(util.Cell)) util.Bitboard
+	aliveCount() int
+	aliveCells() []util.Cell
+	//returns a hash of the whole world, which is the same whenever the world is in the same state
+	hash() uint64
+	//returns the statistics of the world compared with the one before the last advance, split into the given slices
+	stats(slices int) util.Stats
+	stop()
+}
+
+// newEngine starts the engine selected in the params on the world.
+func newEngine(p Params, world util.Bitboard, rule util.Rule) (engine, error) {
+	if err := CheckEngine(p); err != nil {
+		return nil, err
+	}
+	if p.Unbounded {
+		//the rule may have come from a checkpoint rather than the params
+		if err := util.CheckUnboundedRule(rule); err != nil {
+			return nil, err
+		}
+		return &unboundedEngine{world: util.NewUnbounded(world), rule: rule, width: world.Width, height: world.Height()}, nil
+	}
+	if p.Engine == HashLife {
+		life, err := util.NewHashLife(world, rule)
+		if err != nil {
+			return nil, err
+		}
+		return &hashLifeEngine{life: life, world: world}, nil
+	}
+	//an empty topology means a torus
+	topology, err := util.ParseTopology(p.Topology)
+	if err != nil {
+		return nil, err
+	}
+	return newWorkerPool(world, p.Threads, rule, topology), nil
+}
+
+func (pool *workerPool) turnsAtOnce(maxTurns int) int {
+	return 1
+}
+
+// advance runs a single turn on every worker.
+func (pool *workerPool) advance(turns int, flipped func(util.Cell)) util.Bitboard {
+	previous := pool.current
+	world := pool.step()
+	if flipped != nil {
+		previous.FlippedCells(world, flipped)
+	}
+	return world
+}
+
+func (pool *workerPool) aliveCount() int {
+	return pool.current.AliveCount()
+}
+
+func (pool *workerPool) aliveCells() []util.Cell {
+	return pool.current.AliveCells()
+}
+
+func (pool *workerPool) hash() uint64 {
+	return pool.current.Hash()
+}
+
+//the world before the last step is left in next until the step after
+func (pool *workerPool) stats(slices int) util.Stats {
+	return util.NewStats(pool.next, pool.current, slices)
+}
+
+// hashLifeEngine jumps as many turns as it can at once with HashLife.
+type hashLifeEngine struct {
+	life     *util.HashLife
+	world    util.Bitboard
+	previous util.Bitboard
+}
+
+// turnsAtOnce is the largest power of two that fits, so that the results of earlier jumps can be reused.
+func (e *hashLifeEngine) turnsAtOnce(maxTurns int) int {
+	turns := 1
+	for turns*2 <= maxTurns {
+		turns *= 2
+	}
+	return turns
+}
+
+func (e *hashLifeEngine) advance(turns int, flipped func(util.Cell)) util.Bitboard {
+	e.life.Step(turns)
+	e.previous = e.world
+	e.world = e.life.World()
+	if flipped != nil {
+		e.previous.FlippedCells(e.world, flipped)
+	}
+	return e.world
+}
+
+func (e *hashLifeEngine) aliveCount() int {
+	return e.world.AliveCount()
+}
+
+func (e *hashLifeEngine) aliveCells() []util.Cell {
+	return e.world.AliveCells()
+}
+
+func (e *hashLifeEngine) hash() uint64 {
+	return e.world.Hash()
+}
+
+func (e *hashLifeEngine) stats(slices int) util.Stats {
+	return util.NewStats(e.previous, e.world, slices)
+}
+
+func (e *hashLifeEngine) stop() {}
+
+// unboundedEngine runs a world without edges a turn at a time.
+// Images hold the part of the world the size of the image in its top left, where it started.
+type unboundedEngine struct {
+	world         *util.Unbounded
+	previous      *util.Unbounded
+	rule          util.Rule
+	width, height int
+}
+
+func (e *unboundedEngine) turnsAtOnce(maxTurns int) int {
+	return 1
+}
+
+func (e *unboundedEngine) advance(turns int, flipped func(util.Cell)) util.Bitboard {
+	e.previous = e.world
+	e.world = e.world.Step(e.rule)
+	if flipped != nil {
+		e.previous.FlippedCells(e.world, flipped)
+	}
+	return e.world.Window(0, 0, e.width, e.height)
+}
+
+func (e *unboundedEngine) aliveCount() int {
+	return e.world.AliveCount()
+}
+
+func (e *unboundedEngine) aliveCells() []util.Cell {
+	return e.world.AliveCells()
+}
+
+//the whole world is hashed rather than the part in the image, so patterns leaving the image don't look like repeats
+func (e *unboundedEngine) hash() uint64 {
+	return e.world.Hash()
+}
+
+func (e *unboundedEngine) stats(slices int) util.Stats {
+	return util.NewUnboundedStats(e.previous, e.world, e.world.Window(0, 0, e.width, e.height), slices)
+}
+
+func (e *unboundedEngine) stop() {}
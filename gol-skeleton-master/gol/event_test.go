@@ -0,0 +1,38 @@
+package gol
+
+import "testing"
+
+// TestParseEventFilter checks that lists of event names are parsed ignoring case and spaces, printed back in order,
+// and that unknown names return errors.
+func TestParseEventFilter(t *testing.T) {
+	tests := []struct {
+		names    string
+		expected EventFilter
+		printed  string
+	}{
+		{"", 0, ""},
+		{"CellFlipped", SkipCellFlipped, "CellFlipped"},
+		{"turncomplete, CellFlipped", SkipCellFlipped | SkipTurnComplete, "CellFlipped,TurnComplete"},
+		{"AliveCellsCount,ImageOutputComplete,CycleDetected,CellDecayed",
+			SkipCellDecayed | SkipAliveCellsCount | SkipImageOutputComplete | SkipCycleDetected,
+			"CellDecayed,AliveCellsCount,ImageOutputComplete,CycleDetected"},
+	}
+	for _, test := range tests {
+		filter, err := ParseEventFilter(test.names)
+		if err != nil {
+			t.Errorf("%q: %v", test.names, err)
+			continue
+		}
+		if filter != test.expected || filter.String() != test.printed {
+			t.Errorf("%q: expected %v, got %v", test.names, test.printed, filter)
+		}
+	}
+	for _, names := range []string{"CellFlipped,", "FinalTurnComplete", "StateChange"} {
+		if _, err := ParseEventFilter(names); err == nil {
+			t.Errorf("%q: expected an error", names)
+		}
+	}
+	if !SkipTurnComplete.Skips(SkipCellFlipped|SkipTurnComplete) || SkipTurnComplete.Skips(SkipCellFlipped) {
+		t.Error("expected a filter to skip only the events in it")
+	}
+}
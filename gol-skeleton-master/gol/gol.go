@@ -1,15 +1,193 @@
 package gol
 
+import (
+	"errors"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	Rule        string
+	// InputFile overrides the images/<W>x<H>.pgm convention, in which case the dimensions are read from its header.
+	// A pattern (rle, cells or life 1.06) is placed into a world of the requested size instead, and a .json scenario
+	// lists the size and topology of the world and the pgm images and pattern files to stamp into it, each at an
+	// offset and possibly rotated or reflected.
+	InputFile string
+	// OutputFile is a filename template where {w}, {h} and {turns} are substituted, or a directory to write into.
+	OutputFile string
+	// OffsetX and OffsetY are where a pattern InputFile is placed in the world.
+	OffsetX int
+	OffsetY int
+	// Centre places a pattern InputFile in the centre of the world instead.
+	Centre bool
+
+	// CheckpointTurns and CheckpointInterval are how often a checkpoint recording the world, completed turns, rule and
+	// topology is written, in turns and in time.
+	CheckpointTurns    int
+	CheckpointInterval time.Duration
+	// CheckpointFile is the template checkpoints are written to, out/{w}x{h}.checkpoint.pgm by default.
+	CheckpointFile string
+	// Resume continues from a checkpoint, using its world, completed turns, rule and topology, until Turns turns are
+	// complete.
+	Resume string
+
+	// Engine selects how turns are worked out, BruteForce by default.
+	Engine string
+	// Unbounded runs the world without edges instead of on a torus, so cells can end up anywhere, including at
+	// negative coordinates. Images still hold the part of the world the size of the image that it started in,
+	// so an unbounded world can't be checkpointed.
+	Unbounded bool
+	// Topology says how the edges of a bounded world are joined: torus (the default), dead, reflect, klein or
+	// projective. It overrides a scenario's.
+	Topology string
+	// Neighbourhood (moore, vonneumann or hex) and Range replace the neighbourhood of the rule when they are set,
+	// so Larger than Life rules can also be given as a B/S rule with a Range.
+	Neighbourhood string
+	Range         int
+	// Random starts from a soup where each cell is alive with that probability instead of reading InputFile.
+	Random float64
+	// Seed generates the soup, so that the same seed always gives the same soup. It is recorded in the comments
+	// of the images and checkpoints written, and in their default filenames.
+	Seed int64
+	// CycleHistory is how many of the latest worlds are hashed to spot the world repeating, which sends a
+	// CycleDetected Event, and is 0 to not look for repeats.
+	CycleHistory int
+	// StopOnCycle skips the remaining whole cycles once a repeat is spotted, so the world after Turns turns is worked
+	// out without running them all, remembering 64 worlds if CycleHistory is 0.
+	StopOnCycle bool
+	// StatsFile is a csv file, or jsonl if it ends in .jsonl, to write the population, births, deaths, bounding box
+	// and density of each slice of the world to after every turn, or every jump of HashLife. A csv file starts with
+	// the same columns as the files in check/alive.
+	StatsFile string
+
+	// AliveCellsInterval is how often an AliveCellsCount Event is sent, every 2 seconds by default.
+	AliveCellsInterval time.Duration
+	// SkipEvents is the set of types of Event not to send.
+	SkipEvents EventFilter
+}
+
+// The engines that can be selected with Params.Engine.
+// BruteForce works out every cell every turn, whereas HashLife remembers the results of repeated regions
+// so it can jump billions of turns at once, but only runs worlds whose width and height are powers of two.
+const (
+	BruteForce = "bruteforce"
+	HashLife   = "hashlife"
+)
+
+// CheckEngine returns an error if the engine in the params doesn't exist or can't run the world they describe.
+func CheckEngine(p Params) error {
+	topology, err := util.ParseTopology(WorldTopology(p))
+	if err != nil {
+		return err
+	}
+	if topology != util.Torus && (p.Unbounded || p.Engine == HashLife) {
+		return errors.New("only the bruteforce engine runs worlds with " + topology.String() + " edges")
+	}
+	rule, err := ruleFromParams(p)
+	if err != nil {
+		return err
+	}
+	if rule.States > 2 && p.Engine == HashLife {
+		return errors.New("hashlife can't run generations rules")
+	}
+	if rule.Range > 1 && p.Engine == HashLife {
+		return errors.New("hashlife can't run rules with a range larger than 1")
+	}
+	if p.Unbounded {
+		if p.Engine == HashLife {
+			return errors.New("hashlife only runs worlds with edges")
+		}
+		if err := util.CheckUnboundedRule(rule); err != nil {
+			return err
+		}
+		//a checkpoint only holds the world in the image, so the cells that had left it would be lost when resuming
+		if p.CheckpointTurns > 0 || p.CheckpointInterval > 0 || p.Resume != "" {
+			return errors.New("checkpoints only keep worlds with edges")
+		}
+	}
+	switch p.Engine {
+	case "", BruteForce:
+		return nil
+	case HashLife:
+		return util.CheckHashLifeSize(p.ImageWidth, p.ImageHeight)
+	}
+	return errors.New("unknown engine " + p.Engine)
+}
+
+// defaultAliveCellsInterval is how often an AliveCellsCount Event is sent if the params don't say.
+const defaultAliveCellsInterval = 2 * time.Second
+
+//how often an AliveCellsCount event is sent
+func aliveCellsInterval(p Params) time.Duration {
+	if p.AliveCellsInterval > 0 {
+		return p.AliveCellsInterval
+	}
+	return defaultAliveCellsInterval
+}
+
+//parses the rule from the params, an empty rule means Conway's, with the neighbourhood and range from the params
+func ruleFromParams(p Params) (util.Rule, error) {
+	ruleString := p.Rule
+	if ruleString == "" {
+		ruleString = util.ConwayRule
+	}
+	rule, err := util.ParseRule(ruleString)
+	if err != nil || p.Neighbourhood == "" && p.Range == 0 {
+		return rule, err
+	}
+	neighbourhood, radius := rule.Neighbourhood, rule.Range
+	if p.Neighbourhood != "" {
+		if neighbourhood, err = util.ParseNeighbourhood(p.Neighbourhood); err != nil {
+			return rule, err
+		}
+	}
+	if p.Range != 0 {
+		radius = p.Range
+	}
+	return rule.WithNeighbourhood(neighbourhood, radius)
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
+// If a file cannot be read or written, or the params describe a world the engine can't run, an ErrorOccurred Event
+// is sent and Run quits instead of panicking.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	width, height, err := WorldDimensions(p)
+	if err != nil {
+		quitWithError(events, inputFilename(p), err, 0)
+		return
+	}
+	p.ImageWidth, p.ImageHeight = width, height
+	p.Topology = WorldTopology(p)
+
+	startTurn := 0
+	if p.Resume != "" {
+		saved, err := readCheckpoint(p.Resume)
+		if err != nil {
+			quitWithError(events, p.Resume, err, 0)
+			return
+		}
+		startTurn, p.Rule, p.Random, p.Seed = saved.turns, saved.rule, saved.random, saved.seed
+		if saved.neighbourhood != "" {
+			p.Neighbourhood, p.Range = saved.neighbourhood, saved.radius
+		}
+	}
+	//the neighbourhood becomes part of the rule, so that it is written to patterns and checkpoints along with it
+	if p.Neighbourhood != "" || p.Range != 0 {
+		if rule, err := ruleFromParams(p); err == nil {
+			p.Rule, p.Neighbourhood, p.Range = rule.String(), "", 0
+		}
+	}
+	//the params are only checked now, as the rule may have come from the checkpoint
+	if err := CheckEngine(p); err != nil {
+		quitWithError(events, "", err, startTurn)
+		return
+	}
 
 	//	TODO: Put the missing channels in here.
 
@@ -18,6 +196,8 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 	ioFilename := make(chan string)
 	ioOutput := make(chan uint8)
 	ioInput := make(chan uint8)
+	ioErrors := make(chan error)
+	ioTurns := make(chan int)
 
 	ioChannels := ioChannels{
 		command:  ioCommand,
@@ -25,6 +205,8 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		filename: ioFilename,
 		output:   ioOutput,
 		input:    ioInput,
+		errors:   ioErrors,
+		turns:    ioTurns,
 	}
 	go startIo(p, ioChannels)
 
@@ -35,7 +217,17 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		ioFilename: ioFilename,
 		ioOutput:   ioOutput,
 		ioInput:    ioInput,
+		ioErrors:   ioErrors,
+		ioTurns:    ioTurns,
 		keyPresses: keyPresses,
 	}
-	distributor(p, distributorChannels)
+	distributor(p, distributorChannels, startTurn)
+}
+
+// quitWithError reports a file that could not be read or written, or an error in the params when filename is empty,
+// then quits and closes the events channel.
+func quitWithError(events chan<- Event, filename string, err error, turns int) {
+	events <- ErrorOccurred{CompletedTurns: turns, Filename: filename, Err: err}
+	events <- StateChange{turns, Quitting}
+	close(events)
 }
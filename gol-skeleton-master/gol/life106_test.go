@@ -0,0 +1,37 @@
+package gol
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestReadLife106 checks that a pattern far from the origin only covers its own cells, and that it is placed by its
+// coordinates, wrapping around the world.
+func TestReadLife106(t *testing.T) {
+	file := "#Life 1.06\n1000001 -999999\n1000000 -1000000\n1000002 -999998\n"
+	pattern, x, y, err := readLife106(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{{0xFF, 0, 0}, {0, 0xFF, 0}, {0, 0, 0xFF}}
+	if fmt.Sprint(pattern) != fmt.Sprint(expected) || x != 1000000 || y != -1000000 {
+		t.Errorf("expected %v at (1000000, -1000000), got %v at (%d, %d)", expected, pattern, x, y)
+	}
+
+	world, err := placePattern(Params{ImageWidth: 16, ImageHeight: 16, OffsetX: 1}, pattern, x, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//1000000 is a multiple of 16
+	for _, cell := range [][2]int{{1, 0}, {2, 1}, {3, 2}} {
+		if world[cell[1]][cell[0]] != 0xFF {
+			t.Errorf("expected the cell at (%d, %d) to be alive", cell[0], cell[1])
+		}
+	}
+
+	pattern, _, _, err = readLife106(strings.NewReader("#Life 1.06\n"))
+	if err != nil || len(pattern) != 0 {
+		t.Errorf("expected an empty pattern, got %v, %v", pattern, err)
+	}
+}
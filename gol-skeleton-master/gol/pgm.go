@@ -0,0 +1,191 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// pgmHeader holds the fields at the start of a pgm image.
+type pgmHeader struct {
+	magic  string
+	width  int
+	height int
+	maxval int
+}
+
+// readPgmHeader reads the header of a P2 (ASCII) or P5 (binary) pgm image, skipping any comments.
+// The reader is left at the start of the pixel data.
+func readPgmHeader(reader *bufio.Reader) (pgmHeader, error) {
+	var header pgmHeader
+	magic, err := readPgmToken(reader)
+	if err != nil {
+		return header, err
+	}
+	if magic != "P2" && magic != "P5" {
+		return header, errors.New("not a pgm file")
+	}
+	header.magic = magic
+	fields := []*int{&header.width, &header.height, &header.maxval}
+	names := []string{"width", "height", "maxval"}
+	for i, field := range fields {
+		*field, err = readPgmInt(reader)
+		if err != nil {
+			return header, fmt.Errorf("invalid pgm %v: %v", names[i], err)
+		}
+	}
+	if header.width <= 0 || header.height <= 0 {
+		return header, fmt.Errorf("invalid pgm size %dx%d", header.width, header.height)
+	}
+	if header.maxval <= 0 || header.maxval > 65535 {
+		return header, fmt.Errorf("invalid pgm maxval %d", header.maxval)
+	}
+	return header, nil
+}
+
+// readPgm parses a pgm image. Pixels above half of the maxval become alive cells (0xFF), the rest are dead.
+// If grey is set every pixel is kept as its grey level scaled to 0-255 instead, for the decay states of Generations rules.
+func readPgm(r io.Reader, grey bool) ([][]byte, error) {
+	reader := bufio.NewReader(r)
+	header, err := readPgmHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	world := newPattern(header.width, header.height)
+	bytesPerPixel := 1
+	if header.maxval > 255 {
+		bytesPerPixel = 2
+	}
+	row := make([]byte, header.width*bytesPerPixel)
+	for y := range world {
+		for x := range world[y] {
+			var value int
+			if header.magic == "P2" {
+				value, err = readPgmInt(reader)
+			} else if x == 0 {
+				//The binary format is read a row at a time
+				_, err = io.ReadFull(reader, row)
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, errors.New("pgm pixel data is truncated")
+			} else if err != nil {
+				return nil, err
+			}
+			if header.magic == "P5" {
+				value = int(row[x*bytesPerPixel])
+				if bytesPerPixel == 2 {
+					value = value<<8 | int(row[x*bytesPerPixel+1])
+				}
+			}
+			if value > header.maxval {
+				return nil, fmt.Errorf("pgm pixel value %d is greater than the maxval %d", value, header.maxval)
+			}
+			if grey {
+				world[y][x] = byte(value * 0xFF / header.maxval)
+			} else if 2*value > header.maxval {
+				world[y][x] = 0xFF
+			}
+		}
+	}
+	return world, nil
+}
+
+// readPgmFile reads a pgm image and checks that it is the size of the world.
+func readPgmFile(filename string, width, height int, grey bool) ([][]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	world, err := readPgm(file, grey)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+	if len(world) != height || len(world[0]) != width {
+		return nil, fmt.Errorf("%v is %dx%d but the world is %dx%d", filename, len(world[0]), len(world), width, height)
+	}
+	return world, nil
+}
+
+// writePgm writes the world as a binary (P5) pgm image, with each comment on its own line after the magic number.
+func writePgm(w io.Writer, world [][]byte, comments ...string) error {
+	width := 0
+	if len(world) > 0 {
+		width = len(world[0])
+	}
+	writer := bufio.NewWriter(w)
+	_, _ = writer.WriteString("P5\n")
+	for _, comment := range comments {
+		_, _ = fmt.Fprintf(writer, "# %v\n", comment)
+	}
+	_, _ = fmt.Fprintf(writer, "%d %d\n255\n", width, len(world))
+	for y := range world {
+		_, _ = writer.Write(world[y])
+	}
+	return writer.Flush()
+}
+
+// writePgmFile writes the world to a binary pgm image with the given comments.
+func writePgmFile(filename string, world [][]byte, comments ...string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = writePgm(file, world, comments...)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readPgmInt reads the next token of a pgm image as a non-negative integer.
+func readPgmInt(reader *bufio.Reader) (int, error) {
+	token, err := readPgmToken(reader)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.Atoi(token)
+	if err != nil || value < 0 {
+		return 0, errors.New("expected a number but found " + strconv.Quote(token))
+	}
+	return value, nil
+}
+
+// readPgmToken reads the next whitespace separated token, skipping comments that run from '#' to the end of the line.
+// The single whitespace character ending the token is consumed, which is what separates a P5 header from its pixels.
+func readPgmToken(reader *bufio.Reader) (string, error) {
+	var token []byte
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF && len(token) > 0 {
+			return string(token), nil
+		} else if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		} else if err != nil {
+			return "", err
+		}
+		switch b {
+		case '#':
+			if _, err := reader.ReadString('\n'); err != nil && err != io.EOF {
+				return "", err
+			}
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+			if len(token) > 0 {
+				return string(token), nil
+			}
+		default:
+			token = append(token, b)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package gol
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestReadPgm checks pgm images with comments, both formats, different maxvals and pixels that are whitespace bytes.
+func TestReadPgm(t *testing.T) {
+	expected := [][]byte{
+		{0x00, 0xFF, 0x00},
+		{0xFF, 0x00, 0xFF},
+	}
+	tests := map[string]string{
+		"binary":           "P5\n3 2\n255\n\x00\xFF\x00\xFF\x00\xFF",
+		"comments":         "P5 # binary\n# made by hand\n3 2 # size\n255\n\x00\xFF\x00\xFF\x00\xFF",
+		"whitespace bytes": "P5\n3 2\n255\n\x0A\xFF\x20\xFF\x09\xFF",
+		"whitespace alive": "P5\n3 2\n32\n\x0A\x20\x0A\x20\x0A\x20",
+		"ascii":            "P2\n# ascii\n3 2\n15\n0 15 3\n9 # comment between pixels\n7 10\n",
+		"two byte":         "P5\n3 2\n65535\n\x00\x00\xFF\xFF\x7F\xFF\x80\x00\x00\xFF\xFF\xFE",
+		"maxval 1":         "P2 3 2 1 0 1 0 1 0 1",
+	}
+	for name, image := range tests {
+		t.Run(name, func(t *testing.T) {
+			world, err := readPgm(strings.NewReader(image), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(world) != fmt.Sprint(expected) {
+				t.Errorf("expected %v, got %v", expected, world)
+			}
+		})
+	}
+}
+
+// TestReadPgmGrey checks that grey levels are kept and scaled to 0-255 when asked to.
+func TestReadPgmGrey(t *testing.T) {
+	expected := [][]byte{{0x00, 0xFF, 0x55, 0xAA}}
+	for name, image := range map[string]string{
+		"binary": "P5\n4 1\n255\n\x00\xFF\x55\xAA",
+		"ascii":  "P2\n4 1\n15\n0 15 5 10\n",
+	} {
+		world, err := readPgm(strings.NewReader(image), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(world) != fmt.Sprint(expected) {
+			t.Errorf("%v: expected %v, got %v", name, expected, world)
+		}
+	}
+}
+
+// TestReadPgmErrors checks that invalid pgm images return errors instead of panicking.
+func TestReadPgmErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":           "",
+		"wrong magic":     "P6\n3 2\n255\n",
+		"missing height":  "P5\n3",
+		"invalid width":   "P5\nthree 2\n255\n",
+		"zero maxval":     "P5\n3 2\n0\n",
+		"truncated":       "P5\n3 2\n255\n\x00\xFF",
+		"truncated ascii": "P2\n3 2\n255\n0 255 0 255",
+		"above maxval":    "P2\n3 2\n1\n0 1 0 1 0 2",
+	}
+	for name, image := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readPgm(strings.NewReader(image), false); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// TestReadPgmFile checks that images are read from disk and must match the size of the world.
+func TestReadPgmFile(t *testing.T) {
+	world, err := readPgmFile("../check/images/64x16x1.pgm", 64, 16, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(world) != 16 || len(world[0]) != 64 {
+		t.Errorf("expected a 64x16 world, got %dx%d", len(world[0]), len(world))
+	}
+	if _, err := readPgmFile("../check/images/64x16x1.pgm", 16, 64, false); err == nil {
+		t.Error("expected an error for the wrong size")
+	}
+	if _, err := readPgmFile("../images/missing.pgm", 16, 16, false); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
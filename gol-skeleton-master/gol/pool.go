@@ -0,0 +1,77 @@
+package gol
+
+import "uk.ac.bris.cs/gameoflife/util"
+
+// workerPool is a set of workers that live for the whole run, each owning a band of rows of the world.
+// Every turn each worker works out the next state of its band into the next world, reading the halo rows
+// either side of its band straight from the current world, which no one writes to during the turn.
+// Once every worker is done the two worlds are swapped, so nothing is allocated or copied between turns.
+type workerPool struct {
+	current  util.Bitboard
+	next     util.Bitboard
+	rule     util.Rule
+	topology util.Topology
+	turns    []chan bool
+	done     chan bool
+}
+
+// newWorkerPool starts the given number of workers on the world, or a single worker if no threads are given.
+func newWorkerPool(world util.Bitboard, threads int, rule util.Rule, topology util.Topology) *workerPool {
+	if threads < 1 {
+		threads = 1
+	}
+	pool := &workerPool{
+		current:  world,
+		next:     util.NewBitboard(world.Width, world.Height()),
+		rule:     rule,
+		topology: topology,
+		done:     make(chan bool, threads),
+	}
+	if world.Decay != nil {
+		pool.next = pool.next.WithDecay()
+	}
+	rowsPerWorker := world.Height() / threads
+	remainder := world.Height() % threads
+	start := 0
+	for i := 0; i < threads; i++ {
+		end := start + rowsPerWorker
+		//the first workers take a row each of whatever doesn't split evenly
+		if i < remainder {
+			end++
+		}
+		turns := make(chan bool)
+		pool.turns = append(pool.turns, turns)
+		go pool.worker(start, end, turns)
+		start = end
+	}
+	return pool
+}
+
+// step runs a turn on every worker and returns the new current world.
+// The world returned by the previous step is left untouched until the step after this one.
+func (pool *workerPool) step() util.Bitboard {
+	for _, turns := range pool.turns {
+		turns <- true
+	}
+	for range pool.turns {
+		<-pool.done
+	}
+	pool.current, pool.next = pool.next, pool.current
+	return pool.current
+}
+
+// stop ends the workers.
+func (pool *workerPool) stop() {
+	for _, turns := range pool.turns {
+		close(turns)
+	}
+}
+
+//works out the next state of rows start to end-1 every time it is told to run a turn
+func (pool *workerPool) worker(start, end int, turns <-chan bool) {
+	stepper := util.NewStepper(pool.rule, pool.topology, pool.current.Width)
+	for range turns {
+		stepper.StepRows(pool.current, pool.next, start, end)
+		pool.done <- true
+	}
+}
@@ -0,0 +1,23 @@
+package gol
+
+import (
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestWorkerPoolAllocs checks that once the workers have started, turns don't allocate.
+func TestWorkerPoolAllocs(t *testing.T) {
+	rule, _ := util.ParseRule(util.ConwayRule)
+	world := util.PackWorld(makeWorld(512, 512, glider))
+	for _, threads := range []int{1, 4, 16} {
+		pool := newWorkerPool(world, threads, rule, util.Torus)
+		allocs := testing.AllocsPerRun(10, func() {
+			pool.step()
+		})
+		pool.stop()
+		if allocs != 0 {
+			t.Errorf("%d threads: expected no allocations per turn, got %v", threads, allocs)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package gol
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// TestRandomWorld checks that a seed always gives the same soup, that other seeds give other soups,
+// and that about the requested density of cells are alive.
+func TestRandomWorld(t *testing.T) {
+	p := Params{ImageWidth: 256, ImageHeight: 128, Random: 0.3, Seed: 42}
+	soup := randomWorld(p)
+	if fmt.Sprint(randomWorld(p)) != fmt.Sprint(soup) {
+		t.Error("expected the same seed to give the same soup")
+	}
+	other := p
+	other.Seed = 43
+	if fmt.Sprint(randomWorld(other)) == fmt.Sprint(soup) {
+		t.Error("expected another seed to give another soup")
+	}
+
+	alive := len(sortedAliveCells(soup))
+	if density := float64(alive) / (256 * 128); density < 0.28 || density > 0.32 {
+		t.Errorf("expected a density of about 0.3, got %v", density)
+	}
+	p.Random = 1
+	if alive := len(sortedAliveCells(randomWorld(p))); alive != 256*128 {
+		t.Errorf("expected every cell to be alive at a density of 1, got %d", alive)
+	}
+}
+
+// TestRandomFilenames checks that the seed of a random soup is in the default output and checkpoint filenames,
+// and that a checkpoint records the density and seed.
+func TestRandomFilenames(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := Params{ImageWidth: 16, ImageHeight: 8, Random: 0.25, Seed: -7, OutputFile: dir}
+	if filename := outputFilename(p, 100); filename != filepath.Join(dir, "16x8x100-seed-7.pgm") {
+		t.Errorf("unexpected output filename %v", filename)
+	}
+	if filename := checkpointFilename(p, 100); filename != "out/16x8-seed-7.checkpoint.pgm" {
+		t.Errorf("unexpected checkpoint filename %v", filename)
+	}
+	p.OutputFile = filepath.Join(dir, "{seed}.rle")
+	if filename := outputFilename(p, 100); filename != filepath.Join(dir, "-7.rle") {
+		t.Errorf("unexpected output filename %v", filename)
+	}
+
+	filename := filepath.Join(dir, "checkpoint.pgm")
+	if err := writeCheckpointFile(filename, randomWorld(p), 5, "B3/S23", soupComments(p)...); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := readCheckpoint(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.random != 0.25 || saved.seed != -7 {
+		t.Errorf("expected a density of 0.25 and seed of -7, got %v and %v", saved.random, saved.seed)
+	}
+}
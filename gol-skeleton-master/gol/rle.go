@@ -0,0 +1,159 @@
+package gol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// rleLineLength is the maximum length of a line written to an rle file.
+const rleLineLength = 70
+
+// readRle parses a Run Length Encoded pattern, returning its cells indexed by row then column.
+// The rule in the header is ignored as the rule comes from the params.
+func readRle(r io.Reader) ([][]byte, error) {
+	reader := bufio.NewReader(r)
+	width, height := -1, -1
+
+	//Skip comments and parse the "x = m, y = n, rule = abc" header
+	for width < 0 {
+		line, err := reader.ReadString('\n')
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && trimmed[0] != '#' {
+			//the rule comes last and can have commas of its own, as Larger than Life rules do
+			header := trimmed
+			if i := strings.Index(header, "rule"); i > 0 {
+				header = strings.TrimSuffix(strings.TrimSpace(header[:i]), ",")
+			}
+			for _, field := range strings.Split(header, ",") {
+				keyValue := strings.SplitN(field, "=", 2)
+				if len(keyValue) != 2 {
+					return nil, errors.New("invalid rle header: " + trimmed)
+				}
+				key := strings.TrimSpace(keyValue[0])
+				value := strings.TrimSpace(keyValue[1])
+				var parseError error
+				switch key {
+				case "x":
+					width, parseError = strconv.Atoi(value)
+				case "y":
+					height, parseError = strconv.Atoi(value)
+				}
+				if parseError != nil {
+					return nil, errors.New("invalid rle header: " + trimmed)
+				}
+			}
+			if width < 0 || height < 0 {
+				return nil, errors.New("rle header is missing x or y: " + trimmed)
+			}
+		} else if err == io.EOF {
+			return nil, errors.New("rle file has no header")
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	pattern := newPattern(width, height)
+
+	//Decode the runs of cells until the terminating '!'
+	x, y := 0, 0
+	count := 0
+	for {
+		char, _, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case unicode.IsSpace(char):
+			continue
+		case char >= '0' && char <= '9':
+			count = count*10 + int(char-'0')
+			continue
+		case char == '!':
+			return pattern, nil
+		}
+		if count == 0 {
+			count = 1
+		}
+		switch char {
+		case '$':
+			y += count
+			x = 0
+		case 'b', '.':
+			x += count
+		default:
+			//Any other state is treated as alive
+			if y >= height || x+count > width {
+				return nil, fmt.Errorf("rle pattern exceeds its %dx%d header", width, height)
+			}
+			for i := 0; i < count; i++ {
+				pattern[y][x] = 0xFF
+				x++
+			}
+		}
+		count = 0
+	}
+	return pattern, nil
+}
+
+// writeRle writes the world as a Run Length Encoded pattern.
+func writeRle(w io.Writer, world [][]byte, rule string) error {
+	writer := bufio.NewWriter(w)
+	width := 0
+	if len(world) > 0 {
+		width = len(world[0])
+	}
+	_, _ = fmt.Fprintf(writer, "x = %d, y = %d, rule = %s\n", width, len(world), rule)
+
+	line := ""
+	//add a run to the current line, wrapping it if it is too long
+	addRun := func(count int, tag byte) {
+		run := string(tag)
+		if count > 1 {
+			run = strconv.Itoa(count) + run
+		}
+		if len(line)+len(run) > rleLineLength {
+			_, _ = writer.WriteString(line + "\n")
+			line = ""
+		}
+		line += run
+	}
+
+	currentRow := 0
+	for y := range world {
+		//Trailing dead cells in a row are left out and empty rows are merged into one run of '$'
+		end := len(world[y])
+		for end > 0 && world[y][end-1] != 0xFF {
+			end--
+		}
+		if end == 0 {
+			continue
+		}
+		if y > currentRow {
+			addRun(y-currentRow, '$')
+			currentRow = y
+		}
+		for x := 0; x < end; {
+			alive := world[y][x] == 0xFF
+			count := 0
+			for x < end && (world[y][x] == 0xFF) == alive {
+				count++
+				x++
+			}
+			if alive {
+				addRun(count, 'o')
+			} else {
+				addRun(count, 'b')
+			}
+		}
+	}
+	addRun(1, '!')
+	_, _ = writer.WriteString(line + "\n")
+	return writer.Flush()
+}
@@ -0,0 +1,181 @@
+package gol
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+var glider = []util.Cell{{X: 1, Y: 0}, {X: 2, Y: 1}, {X: 0, Y: 2}, {X: 1, Y: 2}, {X: 2, Y: 2}}
+
+var replicator = []util.Cell{
+	{X: 8, Y: 6}, {X: 9, Y: 6}, {X: 10, Y: 6}, {X: 7, Y: 7}, {X: 10, Y: 7}, {X: 6, Y: 8},
+	{X: 10, Y: 8}, {X: 6, Y: 9}, {X: 9, Y: 9}, {X: 6, Y: 10}, {X: 7, Y: 10}, {X: 8, Y: 10},
+}
+
+// ruleTests are known patterns for different rules on a torus.
+var ruleTests = []struct {
+	rule          string
+	width, height int
+	turns         int
+	initial       []util.Cell
+	expected      []util.Cell
+}{
+	{
+		rule:  "B3/S23",
+		width: 16, height: 16,
+		turns:    4,
+		initial:  glider,
+		expected: []util.Cell{{X: 2, Y: 1}, {X: 3, Y: 2}, {X: 1, Y: 3}, {X: 2, Y: 3}, {X: 3, Y: 3}},
+	},
+	{
+		rule:  "B36/S23",
+		width: 32, height: 32,
+		turns:   12,
+		initial: replicator,
+		expected: []util.Cell{
+			{X: 6, Y: 4}, {X: 7, Y: 4}, {X: 8, Y: 4}, {X: 5, Y: 5}, {X: 8, Y: 5}, {X: 4, Y: 6},
+			{X: 8, Y: 6}, {X: 4, Y: 7}, {X: 7, Y: 7}, {X: 4, Y: 8}, {X: 5, Y: 8}, {X: 6, Y: 8},
+			{X: 10, Y: 8}, {X: 11, Y: 8}, {X: 12, Y: 8}, {X: 9, Y: 9}, {X: 12, Y: 9}, {X: 8, Y: 10},
+			{X: 12, Y: 10}, {X: 8, Y: 11}, {X: 11, Y: 11}, {X: 8, Y: 12}, {X: 9, Y: 12}, {X: 10, Y: 12},
+		},
+	},
+	{
+		rule:  "B2/S",
+		width: 16, height: 16,
+		turns:    2,
+		initial:  []util.Cell{{X: 4, Y: 4}, {X: 5, Y: 4}},
+		expected: []util.Cell{{X: 4, Y: 2}, {X: 5, Y: 2}, {X: 3, Y: 4}, {X: 6, Y: 4}, {X: 4, Y: 6}, {X: 5, Y: 6}},
+	},
+	{
+		rule:  "B3/S012345678",
+		width: 16, height: 16,
+		turns:    1,
+		initial:  []util.Cell{{X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}},
+		expected: []util.Cell{{X: 5, Y: 3}, {X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}, {X: 5, Y: 5}},
+	},
+	{
+		//only cells with exactly one alive orthogonal neighbour are born, so the diagonals stay dead
+		rule:  "B1/S01234V",
+		width: 16, height: 16,
+		turns:   2,
+		initial: []util.Cell{{X: 5, Y: 5}},
+		expected: []util.Cell{
+			{X: 5, Y: 3}, {X: 5, Y: 4}, {X: 3, Y: 5}, {X: 4, Y: 5}, {X: 5, Y: 5}, {X: 6, Y: 5}, {X: 7, Y: 5},
+			{X: 5, Y: 6}, {X: 5, Y: 7},
+		},
+	},
+	{
+		rule:  "B1/S0123456H",
+		width: 16, height: 16,
+		turns:   1,
+		initial: []util.Cell{{X: 5, Y: 5}},
+		expected: []util.Cell{
+			{X: 4, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 5}, {X: 5, Y: 5}, {X: 6, Y: 5}, {X: 5, Y: 6}, {X: 6, Y: 6},
+		},
+	},
+	{
+		//the cell counts itself, so it survives with no other neighbours and fills the diamond around it
+		rule:  "R2,C0,M1,S1,B1,NN",
+		width: 16, height: 16,
+		turns:   1,
+		initial: []util.Cell{{X: 5, Y: 5}},
+		expected: []util.Cell{
+			{X: 5, Y: 3}, {X: 4, Y: 4}, {X: 5, Y: 4}, {X: 6, Y: 4}, {X: 3, Y: 5}, {X: 4, Y: 5}, {X: 5, Y: 5},
+			{X: 6, Y: 5}, {X: 7, Y: 5}, {X: 4, Y: 6}, {X: 5, Y: 6}, {X: 6, Y: 6}, {X: 5, Y: 7},
+		},
+	},
+}
+
+func makeWorld(width, height int, alive []util.Cell) [][]byte {
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+	}
+	for _, cell := range alive {
+		world[cell.Y][cell.X] = 0xFF
+	}
+	return world
+}
+
+func sortedAliveCells(world [][]byte) []util.Cell {
+	var cells []util.Cell
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] == 0xFF {
+				cells = append(cells, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
+func TestParseRule(t *testing.T) {
+	tests := map[string]string{
+		"B3/S23":         "B3/S23",
+		"b36/s23":        "B36/S23",
+		"S23/B3":         "B3/S23",
+		"23/36":          "B36/S23",
+		"B2/S":           "B2/S",
+		" B3678/S34678 ": "B3678/S34678",
+		"B2/S/C3":        "B2/S/C3",
+		"345/2/4":        "B2/S345/C4",
+		"B3/S23/C2":      "B3/S23",
+		"B2/S34H":        "B2/S34H",
+		"B1/S01234v":     "B1/S01234V",
+		"B2/S/C3H":       "B2/S/C3H",
+
+		"R5,C0,M1,S33..57,B34..45,NM": "R5,C0,M1,S33..57,B34..45,NM",
+		"r2,c2,m0,s2-3,5,b3,nn":       "R2,C0,M0,S2..3,5..5,B3..3,NN",
+		"R1,C3,M0,S2..3,B3,NM":        "B3/S23/C3",
+		"R3,C0,M0,S,B4..6,NH":         "R3,C0,M0,S,B4..6,NH",
+	}
+	for input, expected := range tests {
+		rule, err := util.ParseRule(input)
+		if err != nil {
+			t.Errorf("ParseRule(%q) returned error %v", input, err)
+		} else if rule.String() != expected {
+			t.Errorf("ParseRule(%q) = %v, expected %v", input, rule, expected)
+		}
+	}
+	for _, input := range []string{"", "B3", "B9/S23", "B3/S2x", "B3/B3", "B2/S/C1", "B2/S/C257", "B2/S/Cx", "B2/S/C3/4",
+		"B5/S23V", "B7/S2H", "R0,C0,M0,S1,B1,NM", "R65,C0,M0,S1,B1,NM", "R1,C0,M0,S9,B1,NM", "R1,C0,M1,S9,B1,NN",
+		"R2,C0,M2,S1,B1,NM", "R2,C0,M0,S1,NM", "R2,C0,M0,S1,B1,NX", "R2,C0,M0,S3..1,B1,NM", "R2,X1,S1,B1"} {
+		if _, err := util.ParseRule(input); err == nil {
+			t.Errorf("ParseRule(%q) should have returned an error", input)
+		}
+	}
+}
+
+// TestRules checks known patterns for several rules using 1-4 worker threads.
+func TestRules(t *testing.T) {
+	for _, test := range ruleTests {
+		rule, err := util.ParseRule(test.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for threads := 1; threads <= 4; threads++ {
+			t.Run(fmt.Sprintf("%v-%d", test.rule, threads), func(t *testing.T) {
+				world := util.PackWorld(makeWorld(test.width, test.height, test.initial))
+				pool := newWorkerPool(world, threads, rule, util.Torus)
+				defer pool.stop()
+				for turn := 0; turn < test.turns; turn++ {
+					world = pool.step()
+				}
+				given := sortedAliveCells(world.Unpack())
+				expected := sortedAliveCells(makeWorld(test.width, test.height, test.expected))
+				if fmt.Sprint(given) != fmt.Sprint(expected) {
+					t.Errorf("after %d turns expected %v, got %v", test.turns, expected, given)
+				}
+			})
+		}
+	}
+}
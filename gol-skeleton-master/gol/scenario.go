@@ -0,0 +1,230 @@
+package gol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// scenario is a starting world composed from pattern files, read from a .json file like
+//
+//	{"width": 64, "height": 64, "topology": "klein", "patterns": [
+//		{"file": "glider.rle", "x": 10, "y": 5, "rotate": 90, "flipX": true}]}
+//
+// where the topology is optional and pattern files are relative to the scenario file.
+type scenario struct {
+	Width    int               `json:"width"`
+	Height   int               `json:"height"`
+	Topology string            `json:"topology"`
+	Patterns []scenarioPattern `json:"patterns"`
+}
+
+// scenarioPattern is a pgm image or pattern file stamped into a scenario with its top left corner at X, Y,
+// after being flipped left to right if FlipX is set, top to bottom if FlipY is set,
+// and then rotated clockwise by Rotate degrees, which is 0, 90, 180 or 270.
+// Offsets wrap around the edges of the world, and only the alive or decaying cells of the pattern are stamped.
+type scenarioPattern struct {
+	File   string `json:"file"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Rotate int    `json:"rotate"`
+	FlipX  bool   `json:"flipX"`
+	FlipY  bool   `json:"flipY"`
+}
+
+// scenarioExtension is the extension of scenario files.
+const scenarioExtension = ".json"
+
+// isScenario returns whether a file should be read as a scenario.
+func isScenario(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == scenarioExtension
+}
+
+// readScenario reads and checks a scenario file, without reading any of its patterns.
+func readScenario(filename string) (scenario, error) {
+	var s scenario
+	file, err := os.Open(filename)
+	if err != nil {
+		return s, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&s); err != nil {
+		return s, fmt.Errorf("%v: %v", filename, err)
+	}
+	if s.Width <= 0 || s.Height <= 0 {
+		return s, fmt.Errorf("%v: invalid world size %dx%d", filename, s.Width, s.Height)
+	}
+	if _, err := util.ParseTopology(s.Topology); err != nil {
+		return s, fmt.Errorf("%v: %v", filename, err)
+	}
+	for _, pattern := range s.Patterns {
+		if pattern.File == "" {
+			return s, errors.New(filename + ": a pattern is missing its file")
+		}
+		if pattern.Rotate%90 != 0 || pattern.Rotate < 0 || pattern.Rotate >= 360 {
+			return s, fmt.Errorf("%v: %v can't be rotated by %d degrees, only 0, 90, 180 or 270",
+				filename, pattern.File, pattern.Rotate)
+		}
+	}
+	return s, nil
+}
+
+// readScenarioFile composes the world of a scenario from its patterns.
+// If grey is set pgm patterns keep their grey levels, for the decay states of Generations rules.
+func readScenarioFile(filename string, grey bool) ([][]byte, error) {
+	s, err := readScenario(filename)
+	if err != nil {
+		return nil, err
+	}
+	world := newPattern(s.Width, s.Height)
+	for _, stamp := range s.Patterns {
+		patternFile := stamp.File
+		if !filepath.IsAbs(patternFile) {
+			patternFile = filepath.Join(filepath.Dir(filename), patternFile)
+		}
+		var pattern [][]byte
+		//patterns with coordinates of their own are stamped that far from the stamp's position
+		var originX, originY int
+		if isPattern(patternFile) {
+			pattern, originX, originY, err = readPatternFile(patternFile)
+		} else {
+			pattern, err = readPgmPatternFile(patternFile, grey)
+		}
+		if err != nil {
+			return nil, err
+		}
+		originX, originY = transformOrigin(pattern, originX, originY, stamp)
+		pattern = transformPattern(pattern, stamp)
+		if len(pattern) > s.Height || len(pattern) > 0 && len(pattern[0]) > s.Width {
+			return nil, fmt.Errorf("%v: %v does not fit in a %dx%d world", filename, stamp.File, s.Width, s.Height)
+		}
+		for y := range pattern {
+			for x, cell := range pattern[y] {
+				if cell != 0x00 {
+					world[wrapIndex(y+stamp.Y+originY, s.Height)][wrapIndex(x+stamp.X+originX, s.Width)] = cell
+				}
+			}
+		}
+	}
+	return world, nil
+}
+
+// readPgmPatternFile reads a pgm image of any size to stamp into a scenario.
+func readPgmPatternFile(filename string, grey bool) ([][]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	pattern, err := readPgm(file, grey)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+	return pattern, nil
+}
+
+// transformPattern returns the pattern flipped and then rotated as the stamp asks.
+func transformPattern(pattern [][]byte, stamp scenarioPattern) [][]byte {
+	height := len(pattern)
+	width := 0
+	if height > 0 {
+		width = len(pattern[0])
+	}
+	flipped := newPattern(width, height)
+	for y := range pattern {
+		for x, cell := range pattern[y] {
+			flippedX, flippedY := x, y
+			if stamp.FlipX {
+				flippedX = width - 1 - x
+			}
+			if stamp.FlipY {
+				flippedY = height - 1 - y
+			}
+			flipped[flippedY][flippedX] = cell
+		}
+	}
+	//each quarter turn clockwise moves the cell at x, y to height-1-y, x
+	for turns := stamp.Rotate / 90; turns > 0; turns-- {
+		rotated := newPattern(height, width)
+		for y := range flipped {
+			for x, cell := range flipped[y] {
+				rotated[x][height-1-y] = cell
+			}
+		}
+		flipped, width, height = rotated, height, width
+	}
+	return flipped
+}
+
+// transformOrigin returns where the pattern lies from the stamp's position after the stamp flips and rotates it.
+// The pattern is transformed in place within the box spanning both its cells and its origin, as if the origin were
+// folded into its cells, so a pattern at the origin keeps its top left corner there and one offset from it turns with
+// its offset.
+func transformOrigin(pattern [][]byte, originX, originY int, stamp scenarioPattern) (int, int) {
+	height := len(pattern)
+	width := 0
+	if height > 0 {
+		width = len(pattern[0])
+	}
+	boxX, boxY, boxRight, boxBottom := 0, 0, 1, 1
+	if originX < 0 {
+		boxX = originX
+	}
+	if originY < 0 {
+		boxY = originY
+	}
+	if originX+width > 1 {
+		boxRight = originX + width
+	}
+	if originY+height > 1 {
+		boxBottom = originY + height
+	}
+	boxWidth, boxHeight := boxRight-boxX, boxBottom-boxY
+	//the offset of the pattern within the box is transformed, then added back to the box's corner
+	x, y := originX-boxX, originY-boxY
+	if stamp.FlipX {
+		x = boxWidth - x - width
+	}
+	if stamp.FlipY {
+		y = boxHeight - y - height
+	}
+	for turns := stamp.Rotate / 90; turns > 0; turns-- {
+		x, y = boxHeight-y-height, x
+		width, height, boxWidth, boxHeight = height, width, boxHeight, boxWidth
+	}
+	return boxX + x, boxY + y
+}
+
+// wrapIndex wraps an offset cell back into a world of the given size.
+func wrapIndex(i, size int) int {
+	return (i%size + size) % size
+}
+
+// WorldTopology returns the name of the topology described by the params, which is the one in the scenario the world
+// is read from if the params don't set one, and torus if neither does. When resuming it is the one recorded in the
+// checkpoint instead.
+func WorldTopology(p Params) string {
+	name := p.Topology
+	if p.Resume != "" {
+		if saved, err := readCheckpoint(p.Resume); err == nil && saved.topology != "" {
+			name = saved.topology
+		}
+	} else if name == "" && !isRandom(p) && isScenario(inputFilename(p)) {
+		if s, err := readScenario(inputFilename(p)); err == nil {
+			name = s.Topology
+		}
+	}
+	topology, err := util.ParseTopology(name)
+	if err != nil {
+		return name
+	}
+	return topology.String()
+}
@@ -0,0 +1,133 @@
+package gol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestTransformPattern checks reflections and rotations of a 3x2 pattern, and that the reflection happens first.
+func TestTransformPattern(t *testing.T) {
+	pattern := [][]byte{
+		{1, 2, 3},
+		{4, 5, 6},
+	}
+	tests := []struct {
+		stamp    scenarioPattern
+		expected [][]byte
+	}{
+		{scenarioPattern{}, [][]byte{{1, 2, 3}, {4, 5, 6}}},
+		{scenarioPattern{FlipX: true}, [][]byte{{3, 2, 1}, {6, 5, 4}}},
+		{scenarioPattern{FlipY: true}, [][]byte{{4, 5, 6}, {1, 2, 3}}},
+		{scenarioPattern{Rotate: 90}, [][]byte{{4, 1}, {5, 2}, {6, 3}}},
+		{scenarioPattern{Rotate: 180}, [][]byte{{6, 5, 4}, {3, 2, 1}}},
+		{scenarioPattern{Rotate: 270}, [][]byte{{3, 6}, {2, 5}, {1, 4}}},
+		{scenarioPattern{Rotate: 90, FlipX: true}, [][]byte{{6, 3}, {5, 2}, {4, 1}}},
+		{scenarioPattern{FlipX: true, FlipY: true}, [][]byte{{6, 5, 4}, {3, 2, 1}}},
+	}
+	for _, test := range tests {
+		if transformed := transformPattern(pattern, test.stamp); fmt.Sprint(transformed) != fmt.Sprint(test.expected) {
+			t.Errorf("%+v: expected %v, got %v", test.stamp, test.expected, transformed)
+		}
+	}
+}
+
+// TestReadScenario composes a world from a pgm image and an rle file in a scenario, with one of them wrapping around
+// the edges, and checks that invalid scenarios return errors instead of panicking.
+func TestReadScenario(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	files := map[string]string{
+		"line.pgm": "P5\n3 1\n255\n\xFF\x00\xFF",
+		"dot.rle":  "x = 1, y = 1\no!\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filename := filepath.Join(dir, "scenario.json")
+	scenarioJson := `{"width": 4, "height": 3, "topology": "klein", "patterns": [
+		{"file": "line.pgm", "x": 2, "y": 2, "rotate": 90},
+		{"file": "dot.rle", "x": -1, "y": 1}]}`
+	if err := ioutil.WriteFile(filename, []byte(scenarioJson), 0644); err != nil {
+		t.Fatal(err)
+	}
+	world, err := readScenarioFile(filename, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{
+		{0x00, 0x00, 0x00, 0x00},
+		{0x00, 0x00, 0xFF, 0xFF},
+		{0x00, 0x00, 0xFF, 0x00},
+	}
+	if fmt.Sprint(world) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, world)
+	}
+	p := Params{InputFile: filename}
+	if width, height, err := WorldDimensions(p); err != nil || width != 4 || height != 3 {
+		t.Errorf("expected a 4x3 world, got %dx%d and %v", width, height, err)
+	}
+	if topology := WorldTopology(p); topology != "klein" {
+		t.Errorf("expected the scenario's topology, got %v", topology)
+	}
+	p.Topology = "dead"
+	if topology := WorldTopology(p); topology != "dead" {
+		t.Errorf("expected the params to override the scenario's topology, got %v", topology)
+	}
+
+	invalid := map[string]string{
+		"not json":        `{"width": 4,`,
+		"unknown field":   `{"width": 4, "height": 3, "size": 12}`,
+		"no size":         `{"patterns": [{"file": "dot.rle"}]}`,
+		"bad topology":    `{"width": 4, "height": 3, "topology": "sphere"}`,
+		"bad rotation":    `{"width": 4, "height": 3, "patterns": [{"file": "dot.rle", "rotate": 45}]}`,
+		"missing file":    `{"width": 4, "height": 3, "patterns": [{"x": 1}]}`,
+		"missing pattern": `{"width": 4, "height": 3, "patterns": [{"file": "glider.rle"}]}`,
+		"too big":         `{"width": 2, "height": 3, "patterns": [{"file": "line.pgm"}]}`,
+	}
+	for name, scenarioJson := range invalid {
+		if err := ioutil.WriteFile(filename, []byte(scenarioJson), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readScenarioFile(filename, false); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+// TestReadScenarioOrigin checks that a pattern with coordinates of its own is flipped and rotated along with its
+// offset from the stamp's position.
+func TestReadScenarioOrigin(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "pair.lif"), []byte("#Life 1.06\n1 0\n2 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "scenario.json")
+	scenarioJson := `{"width": 5, "height": 5, "patterns": [
+		{"file": "pair.lif", "x": 2, "y": 2, "rotate": 90},
+		{"file": "pair.lif", "x": 2, "y": 0, "flipX": true}]}`
+	if err := ioutil.WriteFile(filename, []byte(scenarioJson), 0644); err != nil {
+		t.Fatal(err)
+	}
+	world, err := readScenarioFile(filename, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]byte{
+		{0x00, 0x00, 0xFF, 0xFF, 0x00},
+		{0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x00, 0x00, 0x00, 0x00, 0x00},
+		{0x00, 0x00, 0xFF, 0x00, 0x00},
+		{0x00, 0x00, 0xFF, 0x00, 0x00},
+	}
+	if fmt.Sprint(world) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, world)
+	}
+}
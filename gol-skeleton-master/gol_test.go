@@ -3,20 +3,24 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
-	//"os"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
-// TestGol tests 16x16, 64x64 and 512x512 images on 0, 1 and 100 turns using 1-16 worker threads.
+// TestGol tests 16x16, 64x64, 512x512, 64x16 and 16x64 images on 0, 1 and 100 turns using 1-16 worker threads.
 func TestGol(t *testing.T) {
 	tests := []gol.Params{
 		{ImageWidth: 16, ImageHeight: 16},
 		{ImageWidth: 64, ImageHeight: 64},
 		{ImageWidth: 512, ImageHeight: 512},
+		{ImageWidth: 64, ImageHeight: 16},
+		{ImageWidth: 16, ImageHeight: 64},
 	}
 	for _, p := range tests {
 		for _, turns := range []int{0, 1, 100} {
@@ -46,32 +50,59 @@ func TestGol(t *testing.T) {
 	}
 }
 
+// BenchmarkGol runs 1000 turns of 512x512 and 10 turns of 4096x4096 using 1-16 worker threads,
+// reporting the turns per second for each number of threads.
+// The 4096x4096 image is the 512x512 image tiled 8 times in each direction.
 func BenchmarkGol(t *testing.B) {
-	p := gol.Params{ImageWidth: 512, ImageHeight: 512}
-	//os.Stdout = nil
-	
-	p.Turns = 1000
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	largeImage := filepath.Join(dir, "4096x4096.pgm")
+	if err := tileImage("images/512x512.pgm", 512, 8, largeImage); err != nil {
+		t.Fatal(err)
+	}
+	tests := []gol.Params{
+		{ImageWidth: 512, ImageHeight: 512, Turns: 1000},
+		{ImageWidth: 4096, ImageHeight: 4096, Turns: 10, InputFile: largeImage},
+	}
+	for _, p := range tests {
+		p.OutputFile = filepath.Join(dir, "{w}x{h}x{turns}.pgm")
 		for threads := 1; threads <= 16; threads++ {
 			p.Threads = threads
 			testName := fmt.Sprintf("%dx%d-%d", p.ImageWidth, p.ImageHeight, p.Threads)
-			t.Run(fmt.Sprintf(testName, threads), func(b *testing.B) {
+			t.Run(testName, func(b *testing.B) {
+				b.ResetTimer()
+				start := time.Now()
 				for i := 0; i < b.N; i++ {
-					events := make(chan gol.Event)
-					b.ResetTimer()
+					events := make(chan gol.Event, 1000)
 					go gol.Run(p, events, nil)
-					for event := range events {
-						switch e := event.(type) {
-						case gol.FinalTurnComplete:
-							fmt.Println(e)
-							continue
-						default:
-
-						}
-					}
+					for range events {
 					}
-				})
+				}
+				b.ReportMetric(float64(p.Turns*b.N)/time.Since(start).Seconds(), "turns/s")
+			})
 		}
 	}
+}
+
+//writes a square pgm image made of the given square image repeated tiles times in each direction
+func tileImage(filename string, size int, tiles int, outFilename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if len(data) < size*size {
+		return fmt.Errorf("%v is smaller than %dx%d", filename, size, size)
+	}
+	pixels := data[len(data)-size*size:]
+	tiled := []byte(fmt.Sprintf("P5\n%d %d\n255\n", size*tiles, size*tiles))
+	for y := 0; y < size*tiles; y++ {
+		row := pixels[(y%size)*size : (y%size+1)*size]
+		for x := 0; x < tiles; x++ {
+			tiled = append(tiled, row...)
+		}
+	}
+	return ioutil.WriteFile(outFilename, tiled, 0644)
+}
 
 func boardFail(t *testing.T, given, expected []util.Cell, p gol.Params) bool {
 	errorString := fmt.Sprintf("-----------------\n\n  FAILED TEST\n  %vx%v\n  %d Workers\n  %d Turns\n", p.ImageWidth, p.ImageHeight, p.Threads, p.Turns)
@@ -173,3 +204,13 @@ func BenchmarkGol(b *testing.B) {
 
 
 
+
+// tempDir makes a directory for the files of a test, returning it and a function removing it for the test to defer.
+func tempDir(t testing.TB) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "gol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
@@ -2,16 +2,20 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 	"uk.ac.bris.cs/gameoflife/gol"
 )
 
-// Pgm tests 16x16, 64x64 and 512x512 image output files on 0, 1 and 100 turns using 1-16 worker threads.
+// Pgm tests 16x16, 64x64, 512x512, 64x16 and 16x64 image output files on 0, 1 and 100 turns using 1-16 worker threads.
 func TestPgm(t *testing.T) {
 	tests := []gol.Params{
 		{ImageWidth: 16, ImageHeight: 16},
 		{ImageWidth: 64, ImageHeight: 64},
 		{ImageWidth: 512, ImageHeight: 512},
+		{ImageWidth: 64, ImageHeight: 16},
+		{ImageWidth: 16, ImageHeight: 64},
 	}
 	for _, p := range tests {
 		for _, turns := range []int{0, 1, 100} {
@@ -41,4 +45,84 @@ func TestPgm(t *testing.T) {
 	}
 }
 
+// TestPgmPaths loads a 64x16 image from an arbitrary path and writes it using an output filename template.
+func TestPgmPaths(t *testing.T) {
+	outDir, removeDir := tempDir(t)
+	defer removeDir()
+	p := gol.Params{
+		Turns:      99,
+		Threads:    4,
+		InputFile:  "check/images/64x16x1.pgm",
+		OutputFile: filepath.Join(outDir, "{w}-{h}", "turn{turns}.pgm"),
+	}
+	expectedAlive := readAliveCells("check/images/64x16x100.pgm", 64, 16)
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	var filename string
+	for event := range events {
+		switch e := event.(type) {
+		case gol.ImageOutputComplete:
+			filename = e.Filename
+		}
+	}
+	expectedFilename := filepath.Join(outDir, "64-16", "turn99.pgm")
+	if filename != expectedFilename {
+		t.Fatalf("expected output file %v, got %v", expectedFilename, filename)
+	}
+	cellsFromImage := readAliveCells(filename, 64, 16)
+	assertEqualBoard(t, cellsFromImage, expectedAlive, gol.Params{ImageWidth: 64, ImageHeight: 16, Turns: 100, Threads: 4})
+}
 
+// TestPgmErrors checks that files which can't be read or written, and params that can't be run, send an ErrorOccurred
+// event and quit cleanly, without a final turn.
+func TestPgmErrors(t *testing.T) {
+	outDir, removeDir := tempDir(t)
+	defer removeDir()
+	notADirectory := filepath.Join(outDir, "file")
+	if err := ioutil.WriteFile(notADirectory, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(outDir, "truncated.pgm")
+	if err := ioutil.WriteFile(truncated, []byte("P5\n16 16\n255\n\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]gol.Params{
+		"missing input file":  {ImageWidth: 16, ImageHeight: 16, InputFile: "images/missing.pgm"},
+		"missing input image": {ImageWidth: 17, ImageHeight: 17},
+		"truncated input":     {ImageWidth: 16, ImageHeight: 16, InputFile: truncated},
+		"unwritable output":   {ImageWidth: 16, ImageHeight: 16, Turns: 1, OutputFile: filepath.Join(notADirectory, "out.pgm")},
+		"invalid rule":        {ImageWidth: 16, ImageHeight: 16, Turns: 1, Rule: "B9/S"},
+	}
+	for name, p := range tests {
+		p.Threads = 4
+		t.Run(name, func(t *testing.T) {
+			events := make(chan gol.Event)
+			go gol.Run(p, events, nil)
+			var errorEvent *gol.ErrorOccurred
+			var lastEvent gol.Event
+			finalTurn := false
+			for event := range events {
+				switch e := event.(type) {
+				case gol.ErrorOccurred:
+					errorEvent = &e
+				case gol.FinalTurnComplete:
+					finalTurn = true
+				}
+				lastEvent = event
+			}
+			if errorEvent == nil {
+				t.Fatal("expected an ErrorOccurred event")
+			}
+			//an error in the params isn't about a file
+			if fileError := name != "invalid rule"; errorEvent.Err == nil || (errorEvent.Filename != "") != fileError {
+				t.Errorf("expected an error with a filename only for a file, got %#v", *errorEvent)
+			}
+			if state, ok := lastEvent.(gol.StateChange); !ok || state.NewState != gol.Quitting {
+				t.Errorf("expected the last event to be Quitting, got %#v", lastEvent)
+			}
+			if finalTurn {
+				t.Error("expected no FinalTurnComplete event after an error")
+			}
+		})
+	}
+}
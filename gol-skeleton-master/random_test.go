@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestRandomSoup runs a random soup in a 100x60 world, which has no image to read, checking that the same seed
+// gives the same result, that the seed is recorded in the output image, and that resuming from a checkpoint keeps it.
+func TestRandomSoup(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	p := gol.Params{Turns: 10, Threads: 4, ImageWidth: 100, ImageHeight: 60, Random: 0.3, Seed: 42, OutputFile: dir,
+		CheckpointTurns: 5, CheckpointFile: filepath.Join(dir, "checkpoint-{seed}.pgm")}
+	cells := runFinalCells(p, nil)
+	if len(cells) == 0 {
+		t.Fatal("expected the soup to have alive cells")
+	}
+	again := p
+	again.Threads = 1
+	assertEqualBoard(t, runFinalCells(again, nil), cells, again)
+	other := p
+	other.Seed = 43
+	if fmt.Sprint(runFinalCells(other, nil)) == fmt.Sprint(cells) {
+		t.Error("expected another seed to give another result")
+	}
+
+	output := filepath.Join(dir, "100x60x10-seed42.pgm")
+	image, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(image), "# seed: 42\n") || !strings.Contains(string(image), "# random: 0.3\n") {
+		t.Errorf("expected the density and seed in the comments of %v", output)
+	}
+	//the pixels come after the comments and header
+	var written []util.Cell
+	for i, pixel := range image[len(image)-100*60:] {
+		if pixel == 0xFF {
+			written = append(written, util.Cell{X: i % 100, Y: i / 100})
+		}
+	}
+	assertEqualBoard(t, written, cells, p)
+
+	if err := os.Remove(output); err != nil {
+		t.Fatal(err)
+	}
+	resumed := gol.Params{Turns: 10, Threads: 2, OutputFile: dir, Resume: filepath.Join(dir, "checkpoint-42.pgm")}
+	assertEqualBoard(t, runFinalCells(resumed, nil), cells, resumed)
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("expected the resumed run to keep the seed in its output filename: %v", err)
+	}
+}
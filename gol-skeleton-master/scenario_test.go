@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// TestScenario composes a world on a Klein bottle from a flipped and rotated glider and a rotated R-pentomino image
+// wrapping around the edge, checking that it runs the same as the world written out by hand with the same topology.
+func TestScenario(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	files := map[string]string{
+		"glider.rle":     gliderRle,
+		"rpentomino.pgm": "P5\n3 3\n255\n\x00\xFF\xFF\xFF\xFF\x00\x00\xFF\x00",
+		"scenario.json": `{"width": 40, "height": 30, "topology": "klein", "patterns": [
+			{"file": "glider.rle", "x": 10, "y": 5, "rotate": 90, "flipX": true},
+			{"file": "rpentomino.pgm", "x": -1, "y": 20, "rotate": 180}]}`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//the glider flipped then turned clockwise, and the R-pentomino turned upside down one cell past the left edge
+	composed := []util.Cell{
+		{X: 10, Y: 5}, {X: 11, Y: 5}, {X: 10, Y: 6}, {X: 12, Y: 6}, {X: 10, Y: 7},
+		{X: 0, Y: 20}, {X: 0, Y: 21}, {X: 1, Y: 21}, {X: 0, Y: 22}, {X: 39, Y: 22},
+	}
+	handWritten := filepath.Join(dir, "composed.cells")
+	if err := writeCellsFile(handWritten, composed, 40, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 0, Threads: 4, InputFile: filepath.Join(dir, "scenario.json"), OutputFile: dir}
+	assertEqualBoard(t, runFinalCells(p, nil), composed, p)
+
+	for _, threads := range []int{1, 4} {
+		t.Run(fmt.Sprint(threads), func(t *testing.T) {
+			p := gol.Params{Turns: 60, Threads: threads, InputFile: filepath.Join(dir, "scenario.json"), OutputFile: dir}
+			cells := runFinalCells(p, nil)
+			expected := gol.Params{Turns: 60, Threads: threads, ImageWidth: 40, ImageHeight: 30,
+				InputFile: handWritten, OutputFile: dir, Topology: "klein"}
+			assertEqualBoard(t, cells, runFinalCells(expected, nil), expected)
+
+			torus := p
+			torus.Topology = "torus"
+			if fmt.Sprint(runFinalCells(torus, nil)) == fmt.Sprint(cells) {
+				t.Error("expected a torus to override the scenario's Klein bottle")
+			}
+		})
+	}
+}
@@ -80,11 +80,25 @@ func (w *Window) FlipPixel(x, y int) {
 		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
 	}
 
+	//a decaying cell's grey pixel flips to alive rather than to its inverse
+	grey := byte(0xFF)
+	if w.pixels[4*(y*int(w.Width)+x)] == 0xFF {
+		grey = 0x00
+	}
+	w.SetGrey(x, y, grey)
+}
+
+// SetGrey sets the pixel to the grey level of a cell's state under a Generations rule.
+func (w *Window) SetGrey(x, y int, grey byte) {
+	if x < 0 || y < 0 || x >= int(w.Width) || y >= int(w.Height) {
+		panic(fmt.Sprintf("CellDecayed event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+
 	width := int(w.Width)
-	w.pixels[4*(y*width+x)+0] = ^w.pixels[4*(y*width+x)+0]
-	w.pixels[4*(y*width+x)+1] = ^w.pixels[4*(y*width+x)+1]
-	w.pixels[4*(y*width+x)+2] = ^w.pixels[4*(y*width+x)+2]
-	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
+	w.pixels[4*(y*width+x)+0] = grey
+	w.pixels[4*(y*width+x)+1] = grey
+	w.pixels[4*(y*width+x)+2] = grey
+	w.pixels[4*(y*width+x)+3] = grey
 }
 
 func (w *Window) CountPixels() int {
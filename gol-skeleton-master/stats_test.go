@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// TestStatsCsv writes the statistics of every turn of the images in check/alive to a csv file, checking that its
+// first columns match the files there, and that the population only changes by the births and deaths.
+func TestStatsCsv(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+
+	for _, test := range []struct{ size, turns int }{{16, 10000}, {64, 10000}, {512, 1000}} {
+		t.Run(fmt.Sprint(test.size), func(t *testing.T) {
+			p := gol.Params{Turns: test.turns, Threads: 4, ImageWidth: test.size, ImageHeight: test.size,
+				OutputFile: dir, StatsFile: filepath.Join(dir, "stats.csv")}
+			runFinalCells(p, nil)
+			expected := readAliveCounts(test.size, test.size)
+
+			file, err := os.Open(p.StatsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			table, err := csv.NewReader(file).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(table) != test.turns+1 || table[0][0] != "completed_turns" || table[0][1] != "alive_cells" ||
+				len(table[0]) != 8+p.Threads {
+				t.Fatalf("expected a header and %d turns with %d slices, got %d rows and header %v",
+					test.turns, p.Threads, len(table), table[0])
+			}
+			previous := readAliveCells(fmt.Sprintf("images/%vx%v.pgm", test.size, test.size), test.size, test.size)
+			alive := len(previous)
+			for turn, row := range table[1:] {
+				var values [4]int
+				for i := range values {
+					if values[i], err = strconv.Atoi(row[i]); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if values[0] != turn+1 || values[1] != expected[turn+1] {
+					t.Fatalf("expected %d alive cells after %d turns, got %v", expected[turn+1], turn+1, row)
+				}
+				if values[1] != alive+values[2]-values[3] {
+					t.Fatalf("after %d turns %d cells changed to %d with %d births and %d deaths",
+						turn+1, alive, values[1], values[2], values[3])
+				}
+				alive = values[1]
+			}
+		})
+	}
+}
+
+// TestStatsJsonl writes the statistics of every turn of a glider to a jsonl file, checking its bounding box as it
+// crosses the edge of the world and the density of each slice, which are the bands of the four threads or workers.
+func TestStatsJsonl(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "glider.rle")
+	if err := ioutil.WriteFile(filename, []byte(gliderRle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 8, Threads: 4, ImageWidth: 8, ImageHeight: 8, InputFile: filename, OffsetX: 4,
+		OutputFile: dir, StatsFile: filepath.Join(dir, "stats.jsonl")}
+	runFinalCells(p, nil)
+	file, err := os.Open(p.StatsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	type line struct {
+		CompletedTurns int       `json:"completed_turns"`
+		AliveCells     int       `json:"alive_cells"`
+		Births         int       `json:"births"`
+		Deaths         int       `json:"deaths"`
+		MinX           int       `json:"min_x"`
+		MinY           int       `json:"min_y"`
+		MaxX           int       `json:"max_x"`
+		MaxY           int       `json:"max_y"`
+		SliceDensity   []float64 `json:"slice_density"`
+	}
+	var lines []line
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) != 8 {
+		t.Fatalf("expected 8 lines, got %d", len(lines))
+	}
+	//the glider moves a cell right and down every 4 turns, so after 4 turns it reaches the right edge
+	//and after 8 it wraps around it, leaving its bounding box across the whole width
+	expected := []line{
+		{CompletedTurns: 4, AliveCells: 5, Births: 2, Deaths: 2, MinX: 5, MinY: 1, MaxX: 7, MaxY: 3,
+			SliceDensity: []float64{1.0 / 16, 4.0 / 16, 0, 0}},
+		{CompletedTurns: 8, AliveCells: 5, Births: 2, Deaths: 2, MinX: 0, MinY: 2, MaxX: 7, MaxY: 4,
+			SliceDensity: []float64{0, 2.0 / 16, 3.0 / 16, 0}},
+	}
+	for _, e := range expected {
+		if l := lines[e.CompletedTurns-1]; fmt.Sprint(l) != fmt.Sprint(e) {
+			t.Errorf("expected %+v, got %+v", e, l)
+		}
+	}
+}
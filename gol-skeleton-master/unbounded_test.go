@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+const gosperGunRle = `#N Gosper glider gun
+x = 36, y = 9, rule = B3/S23
+24bo$22bobo$12b2o6b2o12b2o$11bo3bo4b2o12b2o$2o8bo5bo3b2o$2o8bo3bob2o4bobo$10bo5bo7bo$11bo3bo$12b2o!
+`
+
+// TestUnboundedGun runs a glider gun in an unbounded 64x64 world, checking that its gliders carry on past the edge
+// of the image rather than wrapping, that the CellFlipped events match the final cells,
+// and that it matches a 512x512 torus that the gliders don't have time to wrap around.
+func TestUnboundedGun(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "gun.rle")
+	if err := ioutil.WriteFile(filename, []byte(gosperGunRle), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 300, Threads: 4, ImageWidth: 64, ImageHeight: 64, InputFile: filename, OutputFile: dir,
+		OffsetX: 10, OffsetY: 10, Unbounded: true}
+	events := make(chan gol.Event)
+	go gol.Run(p, events, nil)
+	flipped := make(map[util.Cell]bool)
+	var cells []util.Cell
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			if flipped[e.Cell] {
+				delete(flipped, e.Cell)
+			} else {
+				flipped[e.Cell] = true
+			}
+		case gol.FinalTurnComplete:
+			cells = e.Alive
+		}
+	}
+	var flippedCells []util.Cell
+	outside := false
+	for cell := range flipped {
+		flippedCells = append(flippedCells, cell)
+		outside = outside || cell.X >= 64 || cell.Y >= 64
+	}
+	assertEqualBoard(t, flippedCells, cells, p)
+	if !outside {
+		t.Error("expected gliders to have left the image")
+	}
+
+	torus := p
+	torus.Unbounded, torus.ImageWidth, torus.ImageHeight = false, 512, 512
+	assertEqualBoard(t, cells, runFinalCells(torus, nil), p)
+}
+
+// TestUnboundedNegative sends a glider up and to the left past the top left corner of a 32x32 world.
+func TestUnboundedNegative(t *testing.T) {
+	dir, removeDir := tempDir(t)
+	defer removeDir()
+	filename := filepath.Join(dir, "glider.rle")
+	//a glider heading up and to the left
+	if err := ioutil.WriteFile(filename, []byte("x = 3, y = 3\n3o$o$bo!\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := gol.Params{Turns: 40, Threads: 2, ImageWidth: 32, ImageHeight: 32, InputFile: filename, OutputFile: dir,
+		OffsetX: 2, OffsetY: 2, Unbounded: true}
+	glider := []util.Cell{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 2}}
+	assertEqualBoard(t, runFinalCells(p, nil), offsetCells(glider, 2-10, 2-10), p)
+}
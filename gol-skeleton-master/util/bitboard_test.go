@@ -0,0 +1,396 @@
+package util
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+//works out the next state of a whole world on a torus a cell at a time, to check the bitboard against
+func stepBytes(world [][]byte, rule Rule) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			neighbours := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && world[(y+dy+height)%height][(x+dx+width)%width] == 0xFF {
+						neighbours++
+					}
+				}
+			}
+			if rule.Next(world[y][x] == 0xFF, neighbours) {
+				next[y][x] = 0xFF
+			}
+		}
+	}
+	return next
+}
+
+func randomWorld(random *rand.Rand, width, height int) [][]byte {
+	world := make([][]byte, height)
+	for y := range world {
+		world[y] = make([]byte, width)
+		for x := range world[y] {
+			if random.Intn(3) == 0 {
+				world[y][x] = 0xFF
+			}
+		}
+	}
+	return world
+}
+
+// TestBitboardPack checks that worlds survive being packed and unpacked, including widths that don't fill the last word.
+func TestBitboardPack(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, width := range []int{1, 5, 63, 64, 65, 130} {
+		world := randomWorld(random, width, 3)
+		board := PackWorld(world)
+		if fmt.Sprint(board.Unpack()) != fmt.Sprint(world) {
+			t.Errorf("width %d: expected %v, got %v", width, world, board.Unpack())
+		}
+		alive := 0
+		for y := range world {
+			for x := range world[y] {
+				if world[y][x] == 0xFF {
+					alive++
+				}
+			}
+		}
+		if board.AliveCount() != alive || len(board.AliveCells()) != alive {
+			t.Errorf("width %d: expected %d alive cells, got %d", width, alive, board.AliveCount())
+		}
+	}
+}
+
+//steps a whole world the way a worker steps a single slice of it
+func stepSlice(board Bitboard, rule Rule, topology Topology) Bitboard {
+	slice, edges := topology.Slice(board, 0, board.Height(), rule.Range)
+	return slice.Step(rule, edges)
+}
+
+// TestBitboardStep checks the bitboard against counting each cell's neighbours for random worlds and several rules.
+func TestBitboardStep(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	for _, ruleString := range []string{ConwayRule, "B36/S23", "B2/S", "B3/S012345678", "B012345678/S"} {
+		rule, err := ParseRule(ruleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, width := range []int{1, 2, 5, 63, 64, 65, 127, 128, 130} {
+			world := randomWorld(random, width, 7)
+			board := PackWorld(world)
+			for turn := 0; turn < 4; turn++ {
+				world = stepBytes(world, rule)
+				board = stepSlice(board, rule, Torus)
+			}
+			if fmt.Sprint(board.Unpack()) != fmt.Sprint(world) {
+				t.Errorf("%v with width %d: expected %v, got %v", ruleString, width, world, board.Unpack())
+			}
+		}
+	}
+}
+
+// TestBitboardStepBand checks that bands of a world stepped on their own from the rows around them give the same
+// world as stepping it whole, and that their edge cells match their rows.
+func TestBitboardStepBand(t *testing.T) {
+	random := rand.New(rand.NewSource(6))
+	for _, ruleString := range []string{ConwayRule, "B2/S/C3", "R2,C0,M0,S3..6,B4..5,NM"} {
+		rule, err := ParseRule(ruleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		depth := rule.Range
+		for _, topology := range []Topology{Torus, ProjectivePlane} {
+			board := PackWorldStates(randomWorld(random, 70, 20), rule)
+			bands := []int{0, 1, 3, 12, 20}
+			for turn := 0; turn < 4; turn++ {
+				next := Bitboard{Width: board.Width}
+				for i := 0; i+1 < len(bands); i++ {
+					band := Bitboard{Width: board.Width, Rows: board.Rows[bands[i]:bands[i+1]]}
+					if board.Decay != nil {
+						band.Decay = board.Decay[bands[i]:bands[i+1]]
+					}
+					slice, edges := topology.Slice(board, bands[i], bands[i+1], depth)
+					nextBand := band.StepBand(rule, slice.Rows[:depth], slice.Rows[len(slice.Rows)-depth:], edges)
+					next.Rows = append(next.Rows, nextBand.Rows...)
+					next.Decay = append(next.Decay, nextBand.Decay...)
+
+					west, east := nextBand.EdgeCells(depth)
+					for y := range nextBand.Rows {
+						for x := 0; x < depth; x++ {
+							if west[y]&(1<<uint(x)) != 0 != nextBand.Alive(x, y) ||
+								east[y]&(1<<uint(x)) != 0 != nextBand.Alive(board.Width-1-x, y) {
+								t.Errorf("%v: expected the edge cells of row %d to match it", ruleString, bands[i]+y)
+							}
+						}
+					}
+				}
+				board = stepSlice(board, rule, topology)
+				if fmt.Sprint(next.Unpack()) != fmt.Sprint(board.Unpack()) {
+					t.Fatalf("%v on a %v after %d turns: expected the bands to give %v, got %v",
+						ruleString, topology, turn+1, board.Unpack(), next.Unpack())
+				}
+			}
+		}
+	}
+}
+
+// TestBitboardStepBandTurns checks that bands of a world run for several turns at once from wide enough halos give the
+// same world as stepping it whole a turn at a time, including bands thinner than their halos.
+func TestBitboardStepBandTurns(t *testing.T) {
+	random := rand.New(rand.NewSource(9))
+	for _, ruleString := range []string{ConwayRule, "B2/S34H", "R2,C0,M0,S3..6,B4..5,NM"} {
+		rule, err := ParseRule(ruleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, topology := range []Topology{Torus, DeadBorder} {
+			if !topology.CanStepTurns(rule) {
+				t.Fatalf("expected %v to be able to run several turns at once on a %v", ruleString, topology)
+			}
+			world := PackWorld(randomWorld(random, 70, 20))
+			bands := []int{0, 1, 3, 12, 20}
+			for _, turns := range []int{1, 2, 5} {
+				depth := turns * rule.Range
+				next := Bitboard{Width: world.Width}
+				for i := 0; i+1 < len(bands); i++ {
+					band := Bitboard{Width: world.Width, Rows: world.Rows[bands[i]:bands[i+1]]}
+					slice, _ := topology.Slice(world, bands[i], bands[i+1], depth)
+					nextBand := band.StepBandTurns(rule, topology, slice.Rows[:depth], slice.Rows[len(slice.Rows)-depth:],
+						bands[i], world.Height(), turns)
+					next.Rows = append(next.Rows, nextBand.Rows...)
+				}
+				expected := world
+				for turn := 0; turn < turns; turn++ {
+					expected = stepSlice(expected, rule, topology)
+				}
+				if fmt.Sprint(next.Unpack()) != fmt.Sprint(expected.Unpack()) {
+					t.Errorf("%v on a %v after %d turns at once: expected the bands to give %v, got %v",
+						ruleString, topology, turns, expected.Unpack(), next.Unpack())
+				}
+			}
+		}
+	}
+
+	//twisted and reflecting edges, and decaying cells, can only be run a turn at a time
+	conway, _ := ParseRule(ConwayRule)
+	generations, _ := ParseRule("B2/S/C3")
+	for _, topology := range []Topology{Reflecting, KleinBottle, ProjectivePlane} {
+		if topology.CanStepTurns(conway) {
+			t.Errorf("expected a %v not to be able to run several turns at once", topology)
+		}
+	}
+	if Torus.CanStepTurns(generations) {
+		t.Error("expected B2/S/C3 not to be able to run several turns at once")
+	}
+}
+
+// TestSetBandEdges checks that a world with only the edges of each of its bands set in it gives every band the same
+// halos as the whole world.
+func TestSetBandEdges(t *testing.T) {
+	random := rand.New(rand.NewSource(8))
+	world := PackWorld(randomWorld(random, 70, 20))
+	bands := []int{0, 1, 3, 12, 20}
+	for _, topology := range []Topology{Torus, DeadBorder, Reflecting, KleinBottle, ProjectivePlane} {
+		for depth := 1; depth <= 3; depth++ {
+			frame := NewBitboard(world.Width, world.Height())
+			for i := 0; i+1 < len(bands); i++ {
+				band := Bitboard{Width: world.Width, Rows: world.Rows[bands[i]:bands[i+1]]}
+				rows := depth
+				if rows > band.Height() {
+					rows = band.Height()
+				}
+				west, east := band.EdgeCells(depth)
+				frame.SetBandEdges(bands[i], band.Rows[:rows], band.Rows[band.Height()-rows:], west, east, depth)
+			}
+			for i := 0; i+1 < len(bands); i++ {
+				expected := sliceHalos(world, topology, bands[i], bands[i+1], depth)
+				if given := sliceHalos(frame, topology, bands[i], bands[i+1], depth); given != expected {
+					t.Errorf("%v, depth %d, rows %d to %d: expected halos %v, got %v",
+						topology, depth, bands[i], bands[i+1], expected, given)
+				}
+			}
+		}
+	}
+}
+
+// TestBitboardStepRows checks that stepping a world in bands gives the same result as counting each cell's neighbours,
+// and that it doesn't allocate.
+func TestBitboardStepRows(t *testing.T) {
+	random := rand.New(rand.NewSource(5))
+	rule, _ := ParseRule(ConwayRule)
+	for _, width := range []int{1, 63, 64, 65, 130} {
+		for _, height := range []int{1, 2, 7} {
+			world := randomWorld(random, width, height)
+			current, next := PackWorld(world), NewBitboard(width, height)
+			stepper := NewStepper(rule, Torus, width)
+			for turn := 0; turn < 4; turn++ {
+				world = stepBytes(world, rule)
+				for y := 0; y < height; y += 2 {
+					end := y + 2
+					if end > height {
+						end = height
+					}
+					stepper.StepRows(current, next, y, end)
+				}
+				current, next = next, current
+			}
+			if fmt.Sprint(current.Unpack()) != fmt.Sprint(world) {
+				t.Errorf("%dx%d: expected %v, got %v", width, height, world, current.Unpack())
+			}
+		}
+	}
+
+	current, next := PackWorld(randomWorld(random, 512, 512)), NewBitboard(512, 512)
+	for topology := range topologyNames {
+		stepper := NewStepper(rule, Topology(topology), 512)
+		allocs := testing.AllocsPerRun(10, func() {
+			stepper.StepRows(current, next, 0, 512)
+		})
+		if allocs != 0 {
+			t.Errorf("%v: expected no allocations, got %v", Topology(topology), allocs)
+		}
+	}
+}
+
+//works out the next grey level of every cell of a world on a torus under a Generations rule a cell at a time
+func stepStates(world [][]byte, rule Rule) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			neighbours := 0
+			for dy := -rule.Range; dy <= rule.Range; dy++ {
+				for dx := -rule.Range; dx <= rule.Range; dx++ {
+					if (dx != 0 || dy != 0 || rule.Middle) && inNeighbourhood(rule.Neighbourhood, rule.Range, dx, dy) &&
+						world[wrap(y+dy, height)][wrap(x+dx, width)] == 0xFF {
+						neighbours++
+					}
+				}
+			}
+			state := rule.State(world[y][x])
+			switch {
+			case state == 0 && rule.Birth[neighbours], state == 1 && rule.Survive[neighbours]:
+				state = 1
+			case state == 0:
+			case state+1 < rule.States:
+				state++
+			default:
+				state = 0
+			}
+			next[y][x] = rule.Grey(state)
+		}
+	}
+	return next
+}
+
+// TestBitboardGenerations checks Generations rules against working out each cell's next state,
+// both in bands and in slices, and that decaying cells are reported.
+func TestBitboardGenerations(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	for _, ruleString := range []string{"B2/S/C3", "B2/S345/C4", "B278/S3456/C6", "B3/S23/C2",
+		"B2/S34/C5H", "R2,C4,M1,S2..4,B3..4,NN"} {
+		rule, err := ParseRule(ruleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, width := range []int{5, 64, 70} {
+			world := randomWorld(random, width, 9)
+			current := PackWorldStates(world, rule)
+			next := NewBitboard(width, 9)
+			if current.Decay != nil {
+				next = next.WithDecay()
+			}
+			sliced := current
+			stepper := NewStepper(rule, Torus, width)
+			for turn := 0; turn < 6; turn++ {
+				world = stepStates(world, rule)
+				stepper.StepRows(current, next, 0, 4)
+				stepper.StepRows(current, next, 4, 9)
+				current, next = next, current
+				sliced = stepSlice(sliced, rule, Torus)
+			}
+			expected := fmt.Sprint(world)
+			if fmt.Sprint(current.Unpack()) != expected || fmt.Sprint(sliced.Unpack()) != expected {
+				t.Errorf("%v with width %d: expected %v, got %v in bands and %v in a slice",
+					ruleString, width, world, current.Unpack(), sliced.Unpack())
+			}
+
+			after := PackWorldStates(stepStates(world, rule), rule)
+			decayed := 0
+			current.DecayedCells(after, func(cell Cell, grey uint8) {
+				decayed++
+				if after.Grey(cell.X, cell.Y) != grey || grey == 0xFF {
+					t.Errorf("%v: %v decayed to %v", ruleString, cell, grey)
+				}
+			})
+			if rule.States > 2 && decayed == 0 {
+				t.Errorf("%v: expected some cells to decay", ruleString)
+			}
+		}
+	}
+}
+
+// TestGrey checks that every state of a rule has its own grey level, and that grey levels go back to the same state.
+func TestGrey(t *testing.T) {
+	for _, states := range []int{2, 3, 25, 256} {
+		rule := Rule{States: states}
+		seen := make(map[uint8]bool)
+		for state := 0; state < states; state++ {
+			grey := rule.Grey(state)
+			if seen[grey] || rule.State(grey) != state {
+				t.Errorf("%d states: state %d has grey level %d, which is state %d", states, state, grey, rule.State(grey))
+			}
+			seen[grey] = true
+		}
+	}
+	if grey := (Rule{States: 3}).Grey(2); grey != 0x80 {
+		t.Errorf("expected the decay state of a 3 state rule to be 0x80, got %#x", grey)
+	}
+}
+
+// TestBitboardFlipped checks that flipped cells are exactly the ones that changed.
+func TestBitboardFlipped(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	world := randomWorld(random, 70, 5)
+	rule, _ := ParseRule(ConwayRule)
+	next := stepBytes(world, rule)
+	var flipped []Cell
+	PackWorld(world).FlippedCells(PackWorld(next), func(cell Cell) {
+		flipped = append(flipped, cell)
+	})
+	var expected []Cell
+	for y := range world {
+		for x := range world[y] {
+			if world[y][x] != next[y][x] {
+				expected = append(expected, Cell{X: x, Y: y})
+			}
+		}
+	}
+	if fmt.Sprint(flipped) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, flipped)
+	}
+}
+
+// BenchmarkStep compares a turn of a cell at a time with a turn of the bitboard at 512x512 and 4096x4096.
+func BenchmarkStep(b *testing.B) {
+	rule, _ := ParseRule(ConwayRule)
+	for _, size := range []int{512, 4096} {
+		world := randomWorld(rand.New(rand.NewSource(4)), size, size)
+		b.Run(fmt.Sprintf("%dx%d-bytes", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				stepBytes(world, rule)
+			}
+		})
+		board, edges := Torus.Slice(PackWorld(world), 0, size, 1)
+		b.Run(fmt.Sprintf("%dx%d-bitboard", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				board.Step(rule, edges)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestCycleDetector checks that a glider is found to return to its starting place on a 16x16 torus after 64 turns,
+// but not with a history shorter than that, and that a blinker is found with a period of 2 once a lone cell next to
+// it has died.
+func TestCycleDetector(t *testing.T) {
+	rule, _ := ParseRule(ConwayRule)
+	glider := make([][]byte, 16)
+	for y := range glider {
+		glider[y] = make([]byte, 16)
+	}
+	glider[3][4], glider[4][5], glider[5][3], glider[5][4], glider[5][5] = 0xFF, 0xFF, 0xFF, 0xFF, 0xFF
+
+	tests := []struct {
+		history int
+		found   bool
+	}{
+		{100, true},
+		{64, true},
+		{63, false},
+		{0, false},
+	}
+	for _, test := range tests {
+		detector := NewCycleDetector(test.history)
+		world := glider
+		found := false
+		for turn := 0; turn <= 200 && !found; turn++ {
+			var start, period int
+			if start, period, found = detector.Observe(PackWorld(world).Hash(), turn); found {
+				if start != 0 || period != 64 || turn != 64 {
+					t.Errorf("history %d: expected a period of 64 from turn 0 found at turn 64, got %d from turn %d at turn %d",
+						test.history, period, start, turn)
+				}
+			}
+			world = stepBytes(world, rule)
+		}
+		if found != test.found {
+			t.Errorf("history %d: expected found to be %v", test.history, test.found)
+		}
+	}
+
+	//the unbounded glider moves away instead, but a blinker stays put
+	u := NewUnbounded(PackWorld(glider))
+	detector := NewCycleDetector(100)
+	for turn := 0; turn < 200; turn++ {
+		if _, _, found := detector.Observe(u.Hash(), turn); found {
+			t.Fatalf("turn %d: expected an unbounded glider not to repeat", turn)
+		}
+		u = u.Step(rule)
+	}
+	//the lone cell dies, so the cycle starts after the first turn
+	u = NewUnbounded(Bitboard{})
+	u.Set(0, 0, true)
+	u.Set(1, 0, true)
+	u.Set(2, 0, true)
+	u.Set(6, 6, true)
+	detector = NewCycleDetector(10)
+	for turn := 0; turn < 10; turn++ {
+		if start, period, found := detector.Observe(u.Hash(), turn); found {
+			if period != 2 || start != 1 || turn != 3 {
+				t.Errorf("expected a period of 2 from turn 1 found at turn 3, got %d from turn %d at turn %d",
+					period, start, turn)
+			}
+			return
+		}
+		u = u.Step(rule)
+	}
+	t.Error("expected the blinker to be found")
+}
+
+// TestRowsHash checks that the hashes of bands of a world add up to its hash, whichever rows the bands are split at,
+// and that moving a row changes the hash.
+func TestRowsHash(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	world := PackWorld(randomWorld(random, 70, 40))
+	for _, bands := range [][]int{{0, 40}, {0, 1, 40}, {0, 13, 13, 27, 40}} {
+		var hash uint64
+		for i := 0; i+1 < len(bands); i++ {
+			band := Bitboard{Width: world.Width, Rows: world.Rows[bands[i]:bands[i+1]]}
+			hash += band.RowsHash(bands[i], world.Height())
+		}
+		if hash != world.Hash() {
+			t.Errorf("bands split at %v: expected their hashes to add up to %x, got %x", bands, world.Hash(), hash)
+		}
+	}
+	swapped := Bitboard{Width: world.Width, Rows: append([][]uint64{world.Rows[1], world.Rows[0]}, world.Rows[2:]...)}
+	if swapped.Hash() == world.Hash() {
+		t.Error("expected swapping two rows to change the hash")
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestHashLife checks HashLife against counting each cell's neighbours for random worlds, rules and numbers of turns,
+// and that it refuses rules with a larger range.
+func TestHashLife(t *testing.T) {
+	random := rand.New(rand.NewSource(6))
+	for _, ruleString := range []string{ConwayRule, "B36/S23", "B2/S", "B012345678/S", "B2/S34H", "B1/S012V",
+		"R1,C0,M1,S3..4,B3,NM"} {
+		rule, err := ParseRule(ruleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range [][2]int{{1, 1}, {4, 4}, {8, 2}, {16, 16}, {64, 16}, {16, 64}} {
+			width, height := size[0], size[1]
+			world := randomWorld(random, width, height)
+			life, err := NewHashLife(PackWorld(world), rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, turns := range []int{1, 2, 3, 8, 37} {
+				for turn := 0; turn < turns; turn++ {
+					world = stepBytesAround(world, rule, Torus)
+				}
+				life.Step(turns)
+				if fmt.Sprint(life.World().Unpack()) != fmt.Sprint(world) {
+					t.Fatalf("%v on %dx%d after %d turns: expected %v, got %v",
+						ruleString, width, height, turns, world, life.World().Unpack())
+				}
+			}
+		}
+	}
+
+	rule, _ := ParseRule("R2,C0,M0,S2..3,B3,NM")
+	if _, err := NewHashLife(NewBitboard(16, 16), rule); err == nil {
+		t.Error("expected an error for a rule with a range of 2")
+	}
+}
+
+// TestHashLifeJump checks that a world of blinkers and blocks can jump ten billion turns.
+func TestHashLifeJump(t *testing.T) {
+	rule, _ := ParseRule(ConwayRule)
+	world := NewBitboard(512, 512)
+	for i := 0; i < 32; i++ {
+		x, y := i*16, i*16
+		world.Set(x, y+1, true)
+		world.Set(x+1, y+1, true)
+		world.Set(x+2, y+1, true)
+		world.Set(x+8, y+8, true)
+		world.Set(x+9, y+8, true)
+		world.Set(x+8, y+9, true)
+		world.Set(x+9, y+9, true)
+	}
+	life, err := NewHashLife(world, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	life.Step(10000000000)
+	if fmt.Sprint(life.World().AliveCells()) != fmt.Sprint(world.AliveCells()) {
+		t.Error("expected the blinkers to be back where they started after an even number of turns")
+	}
+	life.Step(1)
+	if life.World().AliveCount() != world.AliveCount() || life.World().Alive(0, 1) {
+		t.Error("expected the blinkers to have turned after an odd number of turns")
+	}
+}
+
+// TestCheckHashLifeSize checks that only powers of two are accepted.
+func TestCheckHashLifeSize(t *testing.T) {
+	for _, size := range [][2]int{{1, 1}, {512, 512}, {64, 16}} {
+		if err := CheckHashLifeSize(size[0], size[1]); err != nil {
+			t.Errorf("%dx%d: %v", size[0], size[1], err)
+		}
+	}
+	for _, size := range [][2]int{{0, 0}, {100, 100}, {64, 12}} {
+		if err := CheckHashLifeSize(size[0], size[1]); err == nil {
+			t.Errorf("%dx%d should have returned an error", size[0], size[1])
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package util
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+//whether the cell dx, dy from a cell is in its neighbourhood, worked out from the shape rather than row by row
+func inNeighbourhood(neighbourhood Neighbourhood, radius, dx, dy int) bool {
+	abs := func(i int) int {
+		if i < 0 {
+			return -i
+		}
+		return i
+	}
+	switch neighbourhood {
+	case VonNeumann:
+		return abs(dx)+abs(dy) <= radius
+	case Hexagonal:
+		return abs(dx) <= radius && abs(dy) <= radius && abs(dx-dy) <= radius
+	}
+	return abs(dx) <= radius && abs(dy) <= radius
+}
+
+//works out the next state of a whole world a cell at a time, counting every cell in the neighbourhood of the rule
+func stepBytesAround(world [][]byte, rule Rule, topology Topology) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			neighbours := 0
+			for dy := -rule.Range; dy <= rule.Range; dy++ {
+				for dx := -rule.Range; dx <= rule.Range; dx++ {
+					if !inNeighbourhood(rule.Neighbourhood, rule.Range, dx, dy) || dx == 0 && dy == 0 && !rule.Middle {
+						continue
+					}
+					neighbourX, neighbourY, ok := topology.mapCell(x+dx, y+dy, width, height)
+					if ok && world[neighbourY][neighbourX] == 0xFF {
+						neighbours++
+					}
+				}
+			}
+			if rule.Next(world[y][x] == 0xFF, neighbours) {
+				next[y][x] = 0xFF
+			}
+		}
+	}
+	return next
+}
+
+// TestParseNeighbourhood checks every neighbourhood name and that unknown ones are rejected.
+func TestParseNeighbourhood(t *testing.T) {
+	for _, name := range []string{"moore", "vonneumann", "hex"} {
+		neighbourhood, err := ParseNeighbourhood(name)
+		if err != nil || neighbourhood.String() != name {
+			t.Errorf("ParseNeighbourhood(%q) = %v, %v", name, neighbourhood, err)
+		}
+	}
+	if neighbourhood, err := ParseNeighbourhood(""); err != nil || neighbourhood != Moore {
+		t.Errorf("expected an empty neighbourhood to be Moore, got %v, %v", neighbourhood, err)
+	}
+	if _, err := ParseNeighbourhood("triangle"); err == nil {
+		t.Error("expected an error for triangle")
+	}
+}
+
+// TestNeighbourhoodSize checks the number of neighbours of each neighbourhood against counting its shape.
+func TestNeighbourhoodSize(t *testing.T) {
+	for neighbourhood := range neighbourhoodNames {
+		neighbourhood := Neighbourhood(neighbourhood)
+		for _, radius := range []int{1, 2, 5} {
+			expected := -1
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					if inNeighbourhood(neighbourhood, radius, dx, dy) {
+						expected++
+					}
+				}
+			}
+			if size := neighbourhood.size(radius); size != expected {
+				t.Errorf("%v range %d: expected %d neighbours, got %d", neighbourhood, radius, expected, size)
+			}
+		}
+	}
+	if size := Moore.size(5); size != 120 {
+		t.Errorf("expected the range 5 Moore neighbourhood to have 120 cells, got %d", size)
+	}
+}
+
+// TestNeighbourhoodStep checks stepping in bands and in slices against counting each cell's neighbours
+// for every neighbourhood and topology, including ranges larger than the world.
+func TestNeighbourhoodStep(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	rules := []string{"B2/S34H", "B1/S012V", "B36/S125H", "R2,C0,M0,S3..6,B4..5,NM", "R3,C0,M1,S5..12,B6..9,NN",
+		"R2,C0,M0,S2..5,B3..4,NH", "R1,C0,M1,S3..4,B3,NM", "R5,C0,M1,S33..57,B34..45,NM"}
+	for _, ruleString := range rules {
+		rule, err := ParseRule(ruleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for topology := range topologyNames {
+			topology := Topology(topology)
+			for _, width := range []int{1, 3, 64, 70} {
+				for _, height := range []int{1, 2, 9} {
+					world := randomWorld(random, width, height)
+					current, next := PackWorld(world), NewBitboard(width, height)
+					sliced := PackWorld(world)
+					stepper := NewStepper(rule, topology, width)
+					for turn := 0; turn < 3; turn++ {
+						world = stepBytesAround(world, rule, topology)
+						for y := 0; y < height; y += 4 {
+							end := y + 4
+							if end > height {
+								end = height
+							}
+							stepper.StepRows(current, next, y, end)
+						}
+						current, next = next, current
+						sliced = stepSlice(sliced, rule, topology)
+					}
+					expected := fmt.Sprint(world)
+					if fmt.Sprint(current.Unpack()) != expected || fmt.Sprint(sliced.Unpack()) != expected {
+						t.Errorf("%v %v %dx%d: expected %v, got %v in bands and %v in a slice",
+							ruleString, topology, width, height, world, current.Unpack(), sliced.Unpack())
+					}
+				}
+			}
+		}
+	}
+
+	rule, _ := ParseRule("R5,C0,M1,S33..57,B34..45,NM")
+	current, next := PackWorld(randomWorld(random, 128, 128)), NewBitboard(128, 128)
+	stepper := NewStepper(rule, KleinBottle, 128)
+	allocs := testing.AllocsPerRun(10, func() {
+		stepper.StepRows(current, next, 0, 128)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}
+
+// TestWithNeighbourhood checks that changing the neighbourhood keeps the counts, unless they no longer fit.
+func TestWithNeighbourhood(t *testing.T) {
+	rule, _ := ParseRule(ConwayRule)
+	tests := map[string]struct {
+		neighbourhood Neighbourhood
+		radius        int
+	}{
+		"B3/S23V":                 {VonNeumann, 1},
+		"B3/S23H":                 {Hexagonal, 1},
+		"R2,C0,M0,S2..3,B3..3,NM": {Moore, 2},
+	}
+	for expected, test := range tests {
+		changed, err := rule.WithNeighbourhood(test.neighbourhood, test.radius)
+		if err != nil || changed.String() != expected {
+			t.Errorf("expected %v, got %v, %v", expected, changed, err)
+		}
+	}
+	if _, err := rule.WithNeighbourhood(Moore, maxRange+1); err == nil {
+		t.Error("expected an error for a range larger than the maximum")
+	}
+	crowded, _ := ParseRule("B3/S2345678")
+	if _, err := crowded.WithNeighbourhood(VonNeumann, 1); err == nil {
+		t.Error("expected an error for survival counts larger than the von Neumann neighbourhood")
+	}
+}
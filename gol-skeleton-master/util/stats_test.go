@@ -0,0 +1,95 @@
+package util
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+//works out the statistics of a world a cell at a time, to check the bitboard against
+func statsBytes(previous, current [][]byte, slices int) Stats {
+	var stats Stats
+	for y := range current {
+		for x := range current[y] {
+			alive, before := current[y][x] == 0xFF, previous[y][x] == 0xFF
+			if alive && !before {
+				stats.Births++
+			} else if before && !alive {
+				stats.Deaths++
+			}
+			if !alive {
+				continue
+			}
+			if stats.Alive == 0 {
+				stats.TopLeft, stats.BottomRight = Cell{X: x, Y: y}, Cell{X: x, Y: y}
+			}
+			if x < stats.TopLeft.X {
+				stats.TopLeft.X = x
+			}
+			if x > stats.BottomRight.X {
+				stats.BottomRight.X = x
+			}
+			stats.BottomRight.Y = y
+			stats.Alive++
+		}
+	}
+	height, width := len(current), len(current[0])
+	start := 0
+	for i := 0; i < slices; i++ {
+		end := start + height/slices
+		if i < height%slices {
+			end++
+		}
+		alive := 0
+		for _, row := range current[start:end] {
+			for _, cell := range row {
+				if cell == 0xFF {
+					alive++
+				}
+			}
+		}
+		density := 0.0
+		if end > start {
+			density = float64(alive) / float64((end-start)*width)
+		}
+		stats.SliceDensity = append(stats.SliceDensity, density)
+		start = end
+	}
+	return stats
+}
+
+// TestStats checks the statistics of soups a turn apart against ones worked out a cell at a time,
+// including widths that don't fill the last word and more slices than rows, and that an unbounded world
+// away from the origin gives the same statistics apart from its slices.
+func TestStats(t *testing.T) {
+	rule, _ := ParseRule(ConwayRule)
+	random := rand.New(rand.NewSource(3))
+	for _, size := range [][2]int{{16, 16}, {70, 9}, {130, 40}} {
+		for _, slices := range []int{1, 3, 12} {
+			previous := randomWorld(random, size[0], size[1])
+			current := stepBytes(previous, rule)
+			expected := statsBytes(previous, current, slices)
+			stats := NewStats(PackWorld(previous), PackWorld(current), slices)
+			if fmt.Sprint(stats) != fmt.Sprint(expected) {
+				t.Errorf("%v with %d slices: expected %+v, got %+v", size, slices, expected, stats)
+			}
+		}
+	}
+
+	before, after := NewUnbounded(Bitboard{}), NewUnbounded(Bitboard{})
+	for _, cell := range []Cell{{X: -70, Y: -3}, {X: -69, Y: -3}, {X: 5, Y: 100}} {
+		before.Set(cell.X, cell.Y, true)
+	}
+	for _, cell := range []Cell{{X: -70, Y: -3}, {X: 5, Y: 100}, {X: 200, Y: 7}} {
+		after.Set(cell.X, cell.Y, true)
+	}
+	stats := NewUnboundedStats(before, after, after.Window(0, 0, 10, 10), 2)
+	expected := Stats{Alive: 3, Births: 1, Deaths: 1, TopLeft: Cell{X: -70, Y: -3}, BottomRight: Cell{X: 200, Y: 100},
+		SliceDensity: []float64{0, 0}}
+	if fmt.Sprint(stats) != fmt.Sprint(expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+	if empty := NewStats(NewBitboard(8, 8), NewBitboard(8, 8), 1); empty.Alive != 0 || empty.TopLeft != (Cell{}) {
+		t.Errorf("expected an empty world to have no bounding box, got %+v", empty)
+	}
+}
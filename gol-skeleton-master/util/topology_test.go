@@ -0,0 +1,145 @@
+package util
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+//works out the next state of a whole world a cell at a time, finding the neighbours past the edges with the topology
+func stepBytesOn(world [][]byte, rule Rule, topology Topology) [][]byte {
+	height, width := len(world), len(world[0])
+	next := make([][]byte, height)
+	for y := range world {
+		next[y] = make([]byte, width)
+		for x := range world[y] {
+			neighbours := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					neighbourX, neighbourY, ok := topology.mapCell(x+dx, y+dy, width, height)
+					if (dx != 0 || dy != 0) && ok && world[neighbourY][neighbourX] == 0xFF {
+						neighbours++
+					}
+				}
+			}
+			if rule.Next(world[y][x] == 0xFF, neighbours) {
+				next[y][x] = 0xFF
+			}
+		}
+	}
+	return next
+}
+
+// TestParseTopology checks every topology name and that unknown ones are rejected.
+func TestParseTopology(t *testing.T) {
+	for _, name := range []string{"torus", "dead", "reflect", "klein", "projective"} {
+		topology, err := ParseTopology(name)
+		if err != nil || topology.String() != name {
+			t.Errorf("ParseTopology(%q) = %v, %v", name, topology, err)
+		}
+	}
+	if topology, err := ParseTopology(""); err != nil || topology != Torus {
+		t.Errorf("expected an empty topology to be a torus, got %v, %v", topology, err)
+	}
+	if _, err := ParseTopology("sphere"); err == nil {
+		t.Error("expected an error for sphere")
+	}
+}
+
+// TestMapCell checks the cells just past the edges and corners of a 4x3 world.
+func TestMapCell(t *testing.T) {
+	tests := []struct {
+		topology Topology
+		x, y     int
+		expected string
+	}{
+		{Torus, -1, 1, "3 1 true"},
+		{Torus, 4, -1, "0 2 true"},
+		{DeadBorder, -1, 1, "-1 1 false"},
+		{DeadBorder, 2, 3, "2 3 false"},
+		{DeadBorder, 2, 2, "2 2 true"},
+		{Reflecting, -1, 1, "0 1 true"},
+		{Reflecting, 4, 3, "3 2 true"},
+		{KleinBottle, -1, 1, "3 1 true"},
+		{KleinBottle, 1, 3, "2 0 true"},
+		{KleinBottle, 0, -1, "3 2 true"},
+		{KleinBottle, -1, -1, "0 2 true"},
+		{ProjectivePlane, -1, 0, "3 2 true"},
+		{ProjectivePlane, 4, 1, "0 1 true"},
+		{ProjectivePlane, 1, 3, "2 0 true"},
+		{ProjectivePlane, -1, -1, "0 0 true"},
+	}
+	for _, test := range tests {
+		x, y, ok := test.topology.mapCell(test.x, test.y, 4, 3)
+		if given := fmt.Sprint(x, y, ok); given != test.expected {
+			t.Errorf("%v %d,%d: expected %v, got %v", test.topology, test.x, test.y, test.expected, given)
+		}
+	}
+}
+
+// TestTopologyStep checks stepping in bands and in slices against counting each cell's neighbours
+// for every topology, including worlds a single cell wide or high.
+func TestTopologyStep(t *testing.T) {
+	random := rand.New(rand.NewSource(6))
+	rule, _ := ParseRule(ConwayRule)
+	for topology := range topologyNames {
+		topology := Topology(topology)
+		for _, width := range []int{1, 5, 64, 65, 130} {
+			for _, height := range []int{1, 2, 7} {
+				world := randomWorld(random, width, height)
+				current, next := PackWorld(world), NewBitboard(width, height)
+				sliced := PackWorld(world)
+				stepper := NewStepper(rule, topology, width)
+				for turn := 0; turn < 4; turn++ {
+					world = stepBytesOn(world, rule, topology)
+					for y := 0; y < height; y += 3 {
+						end := y + 3
+						if end > height {
+							end = height
+						}
+						stepper.StepRows(current, next, y, end)
+					}
+					current, next = next, current
+					sliced = stepSlice(sliced, rule, topology)
+				}
+				expected := fmt.Sprint(world)
+				if fmt.Sprint(current.Unpack()) != expected || fmt.Sprint(sliced.Unpack()) != expected {
+					t.Errorf("%v %dx%d: expected %v, got %v in bands and %v in a slice",
+						topology, width, height, world, current.Unpack(), sliced.Unpack())
+				}
+			}
+		}
+	}
+}
+
+//the halo rows either side of rows start to end-1 of a world and the cells past the ends of all of its rows,
+//to compare the halos sliced from different worlds by
+func sliceHalos(world Bitboard, topology Topology, start, end, depth int) string {
+	slice, edges := topology.Slice(world, start, end, depth)
+	return fmt.Sprint(slice.Rows[:depth], slice.Rows[len(slice.Rows)-depth:], edges)
+}
+
+// TestHaloRows checks that the halos of bands of a world, including bands thinner than the range of the rule,
+// can be sliced from a world with nothing in it but the rows that HaloRows gives.
+func TestHaloRows(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	world := PackWorld(randomWorld(random, 70, 20))
+	bands := []int{0, 1, 3, 12, 20}
+	for _, topology := range []Topology{Torus, DeadBorder, Reflecting, KleinBottle, ProjectivePlane} {
+		for depth := 1; depth <= 3; depth++ {
+			for i := 0; i+1 < len(bands); i++ {
+				start, end := bands[i], bands[i+1]
+				rows := topology.HaloRows(world.Width, world.Height(), start, end, depth)
+				partial := NewBitboard(world.Width, world.Height())
+				for _, y := range rows {
+					partial.Rows[y] = world.Rows[y]
+				}
+				expected := sliceHalos(world, topology, start, end, depth)
+				if given := sliceHalos(partial, topology, start, end, depth); given != expected {
+					t.Errorf("%v, depth %d, rows %d to %d: expected the halos from rows %v to be %v, got %v",
+						topology, depth, start, end, rows, expected, given)
+				}
+			}
+		}
+	}
+}
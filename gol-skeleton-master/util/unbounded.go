@@ -0,0 +1,262 @@
+package util
+
+import (
+	"errors"
+	"math/bits"
+	"sort"
+)
+
+// chunkSize is the width and height of each chunk of an unbounded world, so that a row of a chunk is a single word.
+const chunkSize = wordSize
+
+// chunkKey is the position of a chunk, which holds the cells from chunkSize*X to chunkSize*X+chunkSize-1 across
+// and from chunkSize*Y to chunkSize*Y+chunkSize-1 down.
+type chunkKey struct {
+	X, Y int
+}
+
+// chunk holds the cells of a chunk, where bit x of row y is the cell x, y from the chunk's top left.
+type chunk [chunkSize]uint64
+
+// Unbounded is a world without edges, stored as chunks of cells that are only kept while they have alive cells in them.
+// Cells can have any coordinates, including negative ones.
+type Unbounded struct {
+	chunks map[chunkKey]*chunk
+}
+
+// NewUnbounded returns an unbounded world holding the alive cells of the bitboard at the same coordinates.
+func NewUnbounded(world Bitboard) *Unbounded {
+	u := &Unbounded{chunks: make(map[chunkKey]*chunk)}
+	for _, cell := range world.AliveCells() {
+		u.Set(cell.X, cell.Y, true)
+	}
+	return u
+}
+
+// CheckUnboundedRule returns an error if the rule would make infinitely many cells alive in an unbounded world.
+func CheckUnboundedRule(rule Rule) error {
+	if rule.Birth[0] {
+		return errors.New("rules where cells with no alive neighbours are born can't be run without edges")
+	}
+	if rule.States > 2 {
+		return errors.New("generations rules can't be run without edges")
+	}
+	if rule.Range > 1 || rule.Middle {
+		return errors.New("larger than life rules can't be run without edges")
+	}
+	return nil
+}
+
+//the chunk holding the cell and the cell's position in it
+func chunkOf(x, y int) (chunkKey, uint, int) {
+	key := chunkKey{floorDiv(x, chunkSize), floorDiv(y, chunkSize)}
+	return key, uint(x - key.X*chunkSize), y - key.Y*chunkSize
+}
+
+//division rounding down rather than towards zero, so negative cells end up in the chunk to their left
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+// Alive returns whether the cell at x, y is alive.
+func (u *Unbounded) Alive(x, y int) bool {
+	key, bit, row := chunkOf(x, y)
+	c, ok := u.chunks[key]
+	return ok && c[row]>>bit&1 == 1
+}
+
+// Set makes the cell at x, y alive or dead.
+func (u *Unbounded) Set(x, y int, alive bool) {
+	key, bit, row := chunkOf(x, y)
+	c, ok := u.chunks[key]
+	if !ok {
+		if !alive {
+			return
+		}
+		c = new(chunk)
+		u.chunks[key] = c
+	}
+	if alive {
+		c[row] |= 1 << bit
+	} else {
+		c[row] &^= 1 << bit
+	}
+}
+
+// AliveCount returns the number of alive cells.
+func (u *Unbounded) AliveCount() int {
+	count := 0
+	for _, c := range u.chunks {
+		for _, word := range c {
+			count += bits.OnesCount64(word)
+		}
+	}
+	return count
+}
+
+// AliveCells returns the alive cells, ordered by row then column.
+func (u *Unbounded) AliveCells() []Cell {
+	cells := make([]Cell, 0, u.AliveCount())
+	for key, c := range u.chunks {
+		for y, word := range c {
+			for ; word != 0; word &= word - 1 {
+				cells = append(cells, Cell{X: key.X*chunkSize + bits.TrailingZeros64(word), Y: key.Y*chunkSize + y})
+			}
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
+// Bounds returns the top left and bottom right alive cells of the smallest rectangle holding every alive cell,
+// and false if there are no alive cells.
+func (u *Unbounded) Bounds() (Cell, Cell, bool) {
+	var topLeft, bottomRight Cell
+	found := false
+	for key, c := range u.chunks {
+		for y, word := range c {
+			if word == 0 {
+				continue
+			}
+			first := Cell{X: key.X*chunkSize + bits.TrailingZeros64(word), Y: key.Y*chunkSize + y}
+			last := Cell{X: key.X*chunkSize + wordSize - 1 - bits.LeadingZeros64(word), Y: first.Y}
+			if !found {
+				topLeft, bottomRight, found = first, last, true
+				continue
+			}
+			if first.X < topLeft.X {
+				topLeft.X = first.X
+			}
+			if first.Y < topLeft.Y {
+				topLeft.Y = first.Y
+			}
+			if last.X > bottomRight.X {
+				bottomRight.X = last.X
+			}
+			if last.Y > bottomRight.Y {
+				bottomRight.Y = last.Y
+			}
+		}
+	}
+	return topLeft, bottomRight, found
+}
+
+// Window returns the cells in the rectangle with its top left cell at x, y as a bitboard.
+func (u *Unbounded) Window(x, y, width, height int) Bitboard {
+	window := NewBitboard(width, height)
+	for key, c := range u.chunks {
+		for row, word := range c {
+			for ; word != 0; word &= word - 1 {
+				cellX, cellY := key.X*chunkSize+bits.TrailingZeros64(word), key.Y*chunkSize+row
+				if cellX >= x && cellX < x+width && cellY >= y && cellY < y+height {
+					window.Set(cellX-x, cellY-y, true)
+				}
+			}
+		}
+	}
+	return window
+}
+
+// FlippedCells calls flipped with every cell that differs between the two worlds.
+func (u *Unbounded) FlippedCells(other *Unbounded, flipped func(cell Cell)) {
+	var empty chunk
+	keys := make(map[chunkKey]bool, len(u.chunks)+len(other.chunks))
+	for key := range u.chunks {
+		keys[key] = true
+	}
+	for key := range other.chunks {
+		keys[key] = true
+	}
+	for key := range keys {
+		before, after := u.chunks[key], other.chunks[key]
+		if before == nil {
+			before = &empty
+		}
+		if after == nil {
+			after = &empty
+		}
+		for y := range before {
+			for diff := before[y] ^ after[y]; diff != 0; diff &= diff - 1 {
+				flipped(Cell{X: key.X*chunkSize + bits.TrailingZeros64(diff), Y: key.Y*chunkSize + y})
+			}
+		}
+	}
+}
+
+// Step returns the next state of the world. Every chunk next to one with alive cells is worked out,
+// so the world grows by a chunk whenever cells reach the edge of the ones it has, and chunks that die out are dropped.
+func (u *Unbounded) Step(rule Rule) *Unbounded {
+	next := &Unbounded{chunks: make(map[chunkKey]*chunk, len(u.chunks))}
+	done := make(map[chunkKey]bool, len(u.chunks)*4)
+	for key := range u.chunks {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				neighbour := chunkKey{key.X + dx, key.Y + dy}
+				if done[neighbour] {
+					continue
+				}
+				done[neighbour] = true
+				if c := u.stepChunk(neighbour, rule); c != nil {
+					next.chunks[neighbour] = c
+				}
+			}
+		}
+	}
+	return next
+}
+
+//works out the next state of a chunk from it and the chunks around it, returning nil if none of its cells are alive
+func (u *Unbounded) stepChunk(key chunkKey, rule Rule) *chunk {
+	var empty chunk
+	var around [3][3]*chunk
+	for dy := range around {
+		for dx := range around[dy] {
+			around[dy][dx] = u.chunks[chunkKey{key.X + dx - 1, key.Y + dy - 1}]
+			if around[dy][dx] == nil {
+				around[dy][dx] = &empty
+			}
+		}
+	}
+	//the row of cells at the given row of the chunk, along with the cells either side of it in the chunks to the west and east
+	row := func(y int) (uint64, uint64, uint64) {
+		band := 1
+		if y < 0 {
+			band, y = 0, y+chunkSize
+		} else if y >= chunkSize {
+			band, y = 2, y-chunkSize
+		}
+		return around[band][0][y], around[band][1][y], around[band][2][y]
+	}
+	masks := rule.Neighbourhood.masks()
+	var next chunk
+	alive := false
+	for y := range next {
+		var neighbours [8]uint64
+		i := 0
+		for dy := -1; dy <= 1; dy++ {
+			west, centre, east := row(y + dy)
+			//each cell holding its west neighbour, then itself, then its east neighbour
+			shifted := [3]uint64{centre<<1 | west>>(wordSize-1), centre, centre>>1 | east<<(wordSize-1)}
+			for dx, word := range shifted {
+				if dx != 1 || dy != 0 {
+					neighbours[i] = word & masks[i]
+					i++
+				}
+			}
+		}
+		next[y] = nextWord(around[1][1][y], 0, neighbours, rule)
+		alive = alive || next[y] != 0
+	}
+	if !alive {
+		return nil
+	}
+	return &next
+}
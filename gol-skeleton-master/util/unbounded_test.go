@@ -0,0 +1,96 @@
+package util
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestUnbounded checks an unbounded world against a torus big enough that nothing reaches its edges,
+// with the soups starting across the origin so that they cover negative coordinates.
+func TestUnbounded(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	for _, ruleString := range []string{ConwayRule, "B36/S23", "B2/S", "B2/S34H", "B2/S1V"} {
+		rule, err := ParseRule(ruleString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		soup := randomWorld(random, 20, 20)
+		//the torus holds the soup in its centre, at cell 100, 100 rather than -10, -10
+		torus := make([][]byte, 200)
+		for y := range torus {
+			torus[y] = make([]byte, 200)
+		}
+		u := NewUnbounded(Bitboard{})
+		for y := range soup {
+			for x := range soup[y] {
+				torus[y+90][x+90] = soup[y][x]
+				u.Set(x-10, y-10, soup[y][x] == 0xFF)
+			}
+		}
+		for turn := 0; turn < 30; turn++ {
+			torus = stepBytesAround(torus, rule, Torus)
+			u = u.Step(rule)
+		}
+		var expected []Cell
+		for _, cell := range PackWorld(torus).AliveCells() {
+			expected = append(expected, Cell{X: cell.X - 100, Y: cell.Y - 100})
+		}
+		if fmt.Sprint(u.AliveCells()) != fmt.Sprint(expected) {
+			t.Errorf("%v: expected %v, got %v", ruleString, expected, u.AliveCells())
+		}
+	}
+}
+
+// TestUnboundedGlider checks that a glider keeps going instead of wrapping, and that the bounds and flipped cells follow it.
+func TestUnboundedGlider(t *testing.T) {
+	rule, _ := ParseRule(ConwayRule)
+	u := NewUnbounded(PackWorld([][]byte{
+		{0x00, 0xFF, 0x00},
+		{0x00, 0x00, 0xFF},
+		{0xFF, 0xFF, 0xFF},
+	}))
+	start := u.AliveCells()
+	for turn := 0; turn < 400; turn++ {
+		previous := u
+		u = u.Step(rule)
+		flipped := 0
+		previous.FlippedCells(u, func(cell Cell) {
+			flipped++
+			if previous.Alive(cell.X, cell.Y) == u.Alive(cell.X, cell.Y) {
+				t.Fatalf("turn %d: %v was reported as flipped but didn't change", turn+1, cell)
+			}
+		})
+		if flipped == 0 {
+			t.Fatalf("turn %d: expected some cells to flip", turn+1)
+		}
+	}
+	var expected []Cell
+	for _, cell := range start {
+		expected = append(expected, Cell{X: cell.X + 100, Y: cell.Y + 100})
+	}
+	if fmt.Sprint(u.AliveCells()) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, u.AliveCells())
+	}
+	topLeft, bottomRight, ok := u.Bounds()
+	if !ok || topLeft != (Cell{X: 100, Y: 100}) || bottomRight != (Cell{X: 102, Y: 102}) {
+		t.Errorf("expected bounds from (100, 100) to (102, 102), got %v to %v", topLeft, bottomRight)
+	}
+	if window := u.Window(100, 101, 2, 2); fmt.Sprint(window.AliveCells()) != fmt.Sprint([]Cell{{X: 0, Y: 1}, {X: 1, Y: 1}}) {
+		t.Errorf("expected the bottom row of the glider in the window, got %v", window.AliveCells())
+	}
+	if _, _, ok := NewUnbounded(Bitboard{}).Bounds(); ok {
+		t.Error("expected an empty world to have no bounds")
+	}
+}
+
+// TestCheckUnboundedRule checks that rules where empty space comes alive, and Larger than Life rules, are refused.
+func TestCheckUnboundedRule(t *testing.T) {
+	for ruleString, valid := range map[string]bool{ConwayRule: true, "B2/S": true, "B012345678/S": false,
+		"B2/S34H": true, "R2,C0,M0,S2..3,B3,NM": false, "R1,C0,M1,S3..4,B3,NM": false} {
+		rule, _ := ParseRule(ruleString)
+		if err := CheckUnboundedRule(rule); (err == nil) != valid {
+			t.Errorf("%v: unexpected result %v", ruleString, err)
+		}
+	}
+}